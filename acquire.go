@@ -0,0 +1,63 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+// ErrPoolExhausted is error triggered when the pool stays full for DedicatedConnAcquireTimeout. The
+// timeout bounds the dedicated connections acquired by the registry only, the statements of the
+// Master and Slave handles wait for a pooled connection until their context is done.
+var ErrPoolExhausted = errors.New("connection pool exhausted")
+
+// Conn returns a single master node connection of the named connection, the caller must close it.
+// When the pool is exhausted, ErrPoolExhausted is returned once DedicatedConnAcquireTimeout elapses.
+func (r *Registry) Conn(ctx context.Context, name string) (_ *sql.Conn, err error) {
+	var c *connection
+	if c, err = r.connection(ctx, name); err != nil {
+		return nil, err
+	}
+
+	return c.acquire(ctx, c.db.Master())
+}
+
+// SlaveConn returns a single connection of the slave node chosen like SlaveWithNameContext does, the
+// caller must close it. When the pool is exhausted, ErrPoolExhausted is returned once
+// DedicatedConnAcquireTimeout elapses.
+func (r *Registry) SlaveConn(ctx context.Context, name string) (_ *sql.Conn, err error) {
+	var c *connection
+	if c, err = r.connection(ctx, name); err != nil {
+		return nil, err
+	}
+
+	return c.acquire(ctx, c.read(ctx))
+}
+
+// acquire returns connection of the node pool waiting no longer than DedicatedConnAcquireTimeout, zero
+// timeout means waiting until the context is done. The context is used for acquisition only, so the
+// returned connection outlives the timeout. The timeout is reported as ErrPoolExhausted only when the
+// acquisition waited for a connection released to the full pool, a slow dial keeps its own error.
+func (c *connection) acquire(ctx context.Context, node *sql.DB) (*sql.Conn, error) {
+	if c.conf.DedicatedConnAcquireTimeout <= 0 {
+		return node.Conn(ctx)
+	}
+
+	var acquireCtx, cancel = context.WithTimeout(ctx, c.conf.DedicatedConnAcquireTimeout)
+	defer cancel()
+
+	var (
+		waits     = node.Stats().WaitCount
+		conn, err = node.Conn(acquireCtx)
+	)
+
+	if err != nil && ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) && node.Stats().WaitCount > waits {
+		return nil, connectionError(c.name, c.nodeIndex(node), OpAcquire, ErrPoolExhausted)
+	}
+
+	return conn, err
+}
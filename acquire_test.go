@@ -0,0 +1,61 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRegistryConnPoolExhausted(t *testing.T) {
+	var db, _, err = sqlmock.NewWithDSN("gozix_acquire_master")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	var registry *Registry
+	if registry, err = NewRegistry(Configs{"main": {
+		Driver:                      "sqlmock",
+		Nodes:                       []string{"gozix_acquire_master"},
+		MaxOpenConns:                1,
+		DedicatedConnAcquireTimeout: 10 * time.Millisecond,
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	defer registry.Close()
+
+	var held *sql.Conn
+	if held, err = registry.Conn(context.Background(), "main"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = registry.Conn(context.Background(), "main"); !errors.Is(err, ErrPoolExhausted) {
+		t.Errorf("error is %v, want %v", err, ErrPoolExhausted)
+	}
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	if _, err = registry.Conn(ctx, "main"); !errors.Is(err, context.Canceled) || errors.Is(err, ErrPoolExhausted) {
+		t.Errorf("error is %v, want %v", err, context.Canceled)
+	}
+
+	_ = held.Close()
+
+	var conn *sql.Conn
+	if conn, err = registry.Conn(context.Background(), "main"); err != nil {
+		t.Fatalf("released connection is not acquired : %v", err)
+	}
+
+	_ = conn.Close()
+}
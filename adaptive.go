@@ -0,0 +1,143 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"database/sql"
+	"time"
+)
+
+// DefaultAdaptivePoolInterval is default interval of the adaptive pool adjustments.
+const DefaultAdaptivePoolInterval = 10 * time.Second
+
+type (
+	// AdaptivePool is adaptive pool sizing configuration. Every interval the node pool limit grows by
+	// a quarter when callers waited for connections longer than TargetWait on average, and shrinks by
+	// an eighth when nobody waited and less than half of the limit was in use. The controller owns
+	// the node MaxOpenConns, limits set by other means are overridden by the next adjustment.
+	AdaptivePool struct {
+		// MinOpenConns is the lower bound of the pool limit, zero means 1.
+		MinOpenConns int `json:"min_open_conns"`
+
+		// MaxOpenConns is the upper bound of the pool limit, zero disables the adaptive sizing.
+		MaxOpenConns int `json:"max_open_conns"`
+
+		// Interval is interval of the adjustments, zero means DefaultAdaptivePoolInterval.
+		Interval time.Duration `json:"interval"`
+
+		// TargetWait is the acceptable average wait for a connection, zero means any wait grows the pool.
+		TargetWait time.Duration `json:"target_wait"`
+	}
+
+	// adaptivePool adjusts the node pool limits in background.
+	adaptivePool struct {
+		conf   AdaptivePool
+		nodes  []*sql.DB
+		limits []int
+		last   []sql.DBStats
+		name   string
+		logger Logger
+	}
+)
+
+// newAdaptivePool returns controller of the connection nodes or nil if the adaptive sizing is disabled.
+// The initial limits are clamped into the bounds.
+func newAdaptivePool(name string, conf AdaptivePool, nodes []*sql.DB, logger Logger) *adaptivePool {
+	if conf.MaxOpenConns <= 0 {
+		return nil
+	}
+
+	if conf.MinOpenConns <= 0 {
+		conf.MinOpenConns = 1
+	}
+
+	if conf.Interval <= 0 {
+		conf.Interval = DefaultAdaptivePoolInterval
+	}
+
+	var p = adaptivePool{
+		conf:   conf,
+		nodes:  nodes,
+		limits: make([]int, len(nodes)),
+		last:   make([]sql.DBStats, len(nodes)),
+		name:   name,
+		logger: logger,
+	}
+
+	for i, node := range nodes {
+		p.last[i] = node.Stats()
+		p.limits[i] = p.clamp(p.last[i].MaxOpenConnections)
+		node.SetMaxOpenConns(p.limits[i])
+	}
+
+	return &p
+}
+
+// run adjusts the limits every interval until done is closed.
+func (p *adaptivePool) run(done <-chan struct{}) {
+	var ticker = time.NewTicker(p.conf.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		for i := range p.nodes {
+			p.adjust(i)
+		}
+	}
+}
+
+// adjust resizes the node pool by the statistics observed since the previous adjustment.
+func (p *adaptivePool) adjust(idx int) {
+	var (
+		stats = p.nodes[idx].Stats()
+		last  = p.last[idx]
+		limit = p.limits[idx]
+		waits = stats.WaitCount - last.WaitCount
+		wait  = stats.WaitDuration - last.WaitDuration
+		next  = limit
+	)
+
+	p.last[idx] = stats
+
+	switch {
+	case waits > 0 && wait/time.Duration(waits) > p.conf.TargetWait:
+		next = p.clamp(limit + maxInt(1, limit/4))
+	case waits == 0 && stats.InUse < limit/2:
+		next = p.clamp(limit - maxInt(1, limit/8))
+	}
+
+	if next == limit {
+		return
+	}
+
+	p.limits[idx] = next
+	p.nodes[idx].SetMaxOpenConns(next)
+	p.logger.Info("pool limit adjusted", "connection", p.name, "node", idx, "from", limit, "to", next)
+}
+
+// clamp returns the limit within the bounds, zero limit means no limit and is clamped to the maximum.
+func (p *adaptivePool) clamp(limit int) int {
+	switch {
+	case limit <= 0 || limit > p.conf.MaxOpenConns:
+		return p.conf.MaxOpenConns
+	case limit < p.conf.MinOpenConns:
+		return p.conf.MinOpenConns
+	default:
+		return limit
+	}
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+
+	return b
+}
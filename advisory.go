@@ -0,0 +1,177 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+)
+
+type (
+	// ReleaseFunc releases the acquired lock.
+	ReleaseFunc func() error
+
+	// advisoryLock is advisory lock held by the dedicated master node connection.
+	advisoryLock struct {
+		conn   *sql.Conn
+		driver string
+		key    string
+	}
+)
+
+// ErrAdvisoryLockUnsupported is error triggered when advisory lock is requested on a connection of a driver
+// other than Postgres and MySQL ones.
+var ErrAdvisoryLockUnsupported = errors.New("advisory lock is unsupported by driver")
+
+// AdvisoryLock acquires the session advisory lock of the key on the connection master node, waiting
+// until it is released by other sessions or the context is done, and returns its release func. The
+// lock is held by a dedicated pooled connection until released: pg_advisory_lock of the key FNV-1a
+// hash on Postgres and GET_LOCK on MySQL, whose lock names are limited to 64 characters. The lock is
+// lost when its connection breaks, so it isn't supported in pooler mode.
+func (r *Registry) AdvisoryLock(ctx context.Context, name, key string) (_ ReleaseFunc, err error) {
+	var (
+		lock     *advisoryLock
+		acquired bool
+	)
+
+	if lock, acquired, err = r.advisoryLock(ctx, name, key, false); err != nil {
+		return nil, err
+	}
+
+	if !acquired {
+		return nil, fmt.Errorf("unable acquire advisory lock %s", key)
+	}
+
+	return func() error {
+		return lock.release(context.Background())
+	}, nil
+}
+
+// advisoryLock acquires the advisory lock of the key, try doesn't wait for the lock and reports
+// whether it is acquired.
+func (r *Registry) advisoryLock(ctx context.Context, name, key string, try bool) (_ *advisoryLock, _ bool, err error) {
+	var conf Config
+	if conf, err = r.Config(name); err != nil {
+		return nil, false, err
+	}
+
+	if err = conf.advisoryLockProblem(); err != nil {
+		return nil, false, err
+	}
+
+	var lock = advisoryLock{driver: conf.Driver, key: key}
+	if lock.conn, err = r.Conn(ctx, name); err != nil {
+		return nil, false, err
+	}
+
+	var acquired bool
+	if acquired, err = lock.acquire(ctx, try); err != nil {
+		lock.discard()
+		return nil, false, fmt.Errorf("unable acquire advisory lock %s : %w", key, err)
+	}
+
+	if !acquired {
+		_ = lock.conn.Close()
+		return nil, false, nil
+	}
+
+	return &lock, true, nil
+}
+
+// advisoryLockProblem returns problem of the connection configuration advisory locks.
+func (c Config) advisoryLockProblem() error {
+	switch c.Driver {
+	case "postgres", "pgx", "cloudsqlpostgres", "mysql":
+	default:
+		return fmt.Errorf("%w %s", ErrAdvisoryLockUnsupported, c.Driver)
+	}
+
+	if c.PoolerMode {
+		return errors.New("advisory lock is not supported in pooler mode")
+	}
+
+	return nil
+}
+
+// acquire acquires the lock on its connection, try doesn't wait for the lock.
+func (l *advisoryLock) acquire(ctx context.Context, try bool) (_ bool, err error) {
+	var query string
+	switch {
+	case l.driver == "mysql" && try:
+		query = "SELECT GET_LOCK(?, 0)"
+	case l.driver == "mysql":
+		query = "SELECT GET_LOCK(?, -1)"
+	case try:
+		query = "SELECT pg_try_advisory_lock($1)::int"
+	default:
+		// pg_advisory_lock returns void once the lock is acquired
+		_, err = l.conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", l.arg())
+		return err == nil, err
+	}
+
+	// GET_LOCK returns NULL on error
+	var acquired sql.NullInt64
+	if err = l.conn.QueryRowContext(ctx, query, l.arg()).Scan(&acquired); err != nil {
+		return false, err
+	}
+
+	return acquired.Int64 == 1, nil
+}
+
+// release releases the lock and returns its connection to the pool, the connection is discarded when
+// the lock can't be released.
+func (l *advisoryLock) release(ctx context.Context) (err error) {
+	var query = "SELECT pg_advisory_unlock($1)"
+	if l.driver == "mysql" {
+		query = "SELECT RELEASE_LOCK(?)"
+	}
+
+	if _, err = l.conn.ExecContext(ctx, query, l.arg()); err != nil {
+		l.discard()
+		return fmt.Errorf("unable release advisory lock %s : %w", l.key, err)
+	}
+
+	return l.conn.Close()
+}
+
+// held reports whether the lock is still held by its connection. Postgres session locks live as
+// long as the session, so its connection is pinged only.
+func (l *advisoryLock) held(ctx context.Context) (_ bool, err error) {
+	if l.driver != "mysql" {
+		return true, l.conn.PingContext(ctx)
+	}
+
+	var held sql.NullInt64
+	if err = l.conn.QueryRowContext(ctx, "SELECT IS_USED_LOCK(?) = CONNECTION_ID()", l.key).Scan(&held); err != nil {
+		return false, err
+	}
+
+	return held.Int64 == 1, nil
+}
+
+// discard closes the lock connection removing it from the pool, so its session locks are released.
+func (l *advisoryLock) discard() {
+	_ = l.conn.Raw(func(interface{}) error {
+		return driver.ErrBadConn
+	})
+
+	_ = l.conn.Close()
+}
+
+// arg returns the lock key argument of the driver.
+func (l *advisoryLock) arg() interface{} {
+	if l.driver == "mysql" {
+		return l.key
+	}
+
+	var hash = fnv.New64a()
+	_, _ = hash.Write([]byte(l.key))
+
+	return int64(hash.Sum64())
+}
@@ -0,0 +1,176 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	// AuditRecord describes the write statement executed on the connection. Arguments are not
+	// recorded, only their number, so they never leak to the audit trail.
+	AuditRecord struct {
+		Time          time.Time         `json:"time"`
+		Connection    string            `json:"connection"`
+		Node          int               `json:"node"`
+		Role          string            `json:"role"`
+		Statement     string            `json:"statement"`
+		NumArgs       int               `json:"num_args"`
+		Duration      time.Duration     `json:"duration"`
+		RowsAffected  int64             `json:"rows_affected"`
+		Error         string            `json:"error,omitempty"`
+		CorrelationID string            `json:"correlation_id,omitempty"`
+		Metadata      map[string]string `json:"metadata,omitempty"`
+	}
+
+	// AuditSink stores the audit records, it is invoked synchronously after every write statement.
+	AuditSink interface {
+		Audit(ctx context.Context, record AuditRecord)
+	}
+
+	// AuditSinkFunc is a function implementing the AuditSink interface.
+	AuditSinkFunc func(ctx context.Context, record AuditRecord)
+
+	// auditInterceptor records the write statements to the sink.
+	auditInterceptor struct {
+		sink AuditSink
+	}
+
+	// auditWriter writes the audit records as JSON lines.
+	auditWriter struct {
+		mux sync.Mutex
+		enc *json.Encoder
+	}
+
+	// auditKey is context key of the audit metadata.
+	auditKey struct{}
+)
+
+// auditKeywords matches the leading keyword of the write and DDL statements.
+var auditKeywords = regexp.MustCompile(
+	`(?i)^(INSERT|UPDATE|DELETE|MERGE|REPLACE|UPSERT|TRUNCATE|CREATE|ALTER|DROP|RENAME|GRANT|REVOKE|COMMENT)\b`,
+)
+
+// auditCTE matches the data modifying statement of the WITH query.
+var auditCTE = regexp.MustCompile(`(?i)\b(INSERT|UPDATE|DELETE|MERGE)\b`)
+
+// AuditSinkFunc implements AuditSink interface.
+var _ AuditSink = AuditSinkFunc(nil)
+
+// WithAudit option records every INSERT, UPDATE, DELETE and DDL statement executed by the registry
+// connections to the sink, together with its duration, rows affected and the context metadata set
+// by ContextWithAuditMetadata. Statements returning rows report -1 rows affected.
+func WithAudit(sink AuditSink) Option {
+	return optionFunc(func(r *Registry) {
+		r.chain = append(r.chain, &auditInterceptor{sink: sink})
+	})
+}
+
+// NewAuditWriter returns AuditSink writing the records to the writer as JSON lines, e.g. to an append
+// only file. Write errors are ignored, the writer is expected to report them by itself.
+func NewAuditWriter(w io.Writer) AuditSink {
+	return &auditWriter{enc: json.NewEncoder(w)}
+}
+
+// ContextWithAuditMetadata returns context whose audit records carry the metadata, e.g. user ID.
+func ContextWithAuditMetadata(ctx context.Context, key, value string) context.Context {
+	var parent, _ = ctx.Value(auditKey{}).(map[string]string)
+
+	var metadata = make(map[string]string, len(parent)+1)
+	for k, v := range parent {
+		metadata[k] = v
+	}
+
+	metadata[key] = value
+
+	return context.WithValue(ctx, auditKey{}, metadata)
+}
+
+// Audit implements the AuditSink interface.
+func (f AuditSinkFunc) Audit(ctx context.Context, record AuditRecord) {
+	f(ctx, record)
+}
+
+// Audit implements the AuditSink interface.
+func (w *auditWriter) Audit(_ context.Context, record AuditRecord) {
+	w.mux.Lock()
+	defer w.mux.Unlock()
+
+	_ = w.enc.Encode(record)
+}
+
+func (i *auditInterceptor) before(ctx context.Context, _ *QueryEvent) (context.Context, error) {
+	return ctx, nil
+}
+
+func (i *auditInterceptor) after(ctx context.Context, e *QueryEvent) {
+	if e.Op != OpExec && e.Op != OpQuery || !isAuditable(e.Query) {
+		return
+	}
+
+	var record = AuditRecord{
+		Time:          e.Start,
+		Connection:    e.Connection,
+		Node:          e.Node,
+		Role:          e.Role,
+		Statement:     e.Query,
+		NumArgs:       len(e.Args),
+		Duration:      e.Duration,
+		RowsAffected:  e.RowsAffected,
+		CorrelationID: e.CorrelationID,
+	}
+
+	if e.Err != nil {
+		record.Error = e.Err.Error()
+	}
+
+	if metadata, ok := ctx.Value(auditKey{}).(map[string]string); ok {
+		record.Metadata = metadata
+	}
+
+	i.sink.Audit(ctx, record)
+}
+
+// isAuditable reports whether the statement writes data or changes the schema.
+func isAuditable(query string) bool {
+	query = strings.TrimLeft(stripLeadingComments(query), "( \t\r\n")
+	if auditKeywords.MatchString(query) {
+		return true
+	}
+
+	return len(query) >= 4 && strings.EqualFold(query[:4], "WITH") && auditCTE.MatchString(query)
+}
+
+// stripLeadingComments returns the query without the leading whitespaces and comments.
+func stripLeadingComments(query string) string {
+	for {
+		query = strings.TrimLeft(query, " \t\r\n")
+
+		switch {
+		case strings.HasPrefix(query, "--"):
+			var end = strings.IndexByte(query, '\n')
+			if end < 0 {
+				return ""
+			}
+
+			query = query[end+1:]
+		case strings.HasPrefix(query, "/*"):
+			var end = strings.Index(query, "*/")
+			if end < 0 {
+				return ""
+			}
+
+			query = query[end+2:]
+		default:
+			return query
+		}
+	}
+}
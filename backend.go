@@ -0,0 +1,60 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"errors"
+)
+
+// Connection backends.
+const (
+	// BackendDatabaseSQL is default backend, the connection is opened by the registry via database/sql.
+	BackendDatabaseSQL = "database/sql"
+
+	// BackendPgxPool is backend of the connections driven by pgxpool, see the pgxpool package.
+	BackendPgxPool = "pgxpool"
+)
+
+// ErrUnsupportedBackend is error triggered when registry is asked for the connection of the backend
+// it does not drive.
+var ErrUnsupportedBackend = errors.New("unsupported connection backend")
+
+// Config returns configuration of the named connection.
+func (r *Registry) Config(name string) (Config, error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if conf, ok := r.conf[name]; ok {
+		return conf, nil
+	}
+
+	return Config{}, ErrUnknownConnection
+}
+
+// ResolveDSNs returns DSN of every node ready to be passed to the driver, the DSNs are taken from
+// the DSN provider if it is set, ${VAR} placeholders are expanded and TLS settings are applied.
+// It is intended for backends opening the connection outside the registry.
+func (c Config) ResolveDSNs(ctx context.Context, name string) (dsn []string, err error) {
+	dsn = c.nodes().DSNs()
+	if c.DSNProvider != nil {
+		if dsn, err = c.DSNProvider(ctx, name); err != nil {
+			return nil, connectionError(name, -1, OpResolve, err)
+		}
+	}
+
+	for i := range dsn {
+		if dsn[i], err = nodeDSN(name, i, c, dsn[i]); err != nil {
+			return nil, connectionError(name, i, OpResolve, err)
+		}
+	}
+
+	return dsn, nil
+}
+
+// external reports whether the connection is driven by a backend other than the registry.
+func (c Config) external() bool {
+	return c.Backend != "" && c.Backend != BackendDatabaseSQL
+}
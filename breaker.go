@@ -0,0 +1,149 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultCircuitBreakerTimeout is default interval of the removed node probing.
+const DefaultCircuitBreakerTimeout = 5 * time.Second
+
+type (
+	// CircuitBreaker is slave node circuit breaker configuration.
+	CircuitBreaker struct {
+		// Threshold is number of consecutive node failures removing it from the read rotation,
+		// zero disables the breaker.
+		Threshold int `json:"threshold"`
+
+		// Timeout is interval of the removed node probing, zero means DefaultCircuitBreakerTimeout.
+		Timeout time.Duration `json:"timeout"`
+	}
+
+	// breaker tracks failures of the slave node and probes it while it is removed from rotation.
+	breaker struct {
+		mux      sync.Mutex
+		conf     CircuitBreaker
+		idx      int
+		node     *sql.DB
+		done     <-chan struct{}
+		evict    evictFunc
+		failures int
+		open     bool
+	}
+
+	// breakerInterceptor reports operation results to the node breakers.
+	breakerInterceptor struct {
+		breakers []*breaker
+	}
+)
+
+// newBreakers returns breakers of the connection slave nodes, the master node never has a breaker.
+func newBreakers(conf CircuitBreaker, n int, done <-chan struct{}, evict evictFunc) []*breaker {
+	var breakers = make([]*breaker, n)
+	if conf.Threshold <= 0 {
+		return breakers
+	}
+
+	if conf.Timeout <= 0 {
+		conf.Timeout = DefaultCircuitBreakerTimeout
+	}
+
+	for i := 1; i < n; i++ {
+		breakers[i] = &breaker{conf: conf, idx: i, done: done, evict: evict}
+	}
+
+	return breakers
+}
+
+// available reports whether the node is in the read rotation.
+func (b *breaker) available() bool {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	return !b.open
+}
+
+// report accounts the operation result.
+func (b *breaker) report(err error) {
+	b.mux.Lock()
+	defer b.mux.Unlock()
+
+	if err == nil {
+		b.failures = 0
+		return
+	}
+
+	if !isNodeFailure(err) {
+		return
+	}
+
+	b.failures++
+	if b.open || b.failures < b.conf.Threshold {
+		return
+	}
+
+	b.open = true
+	b.evict(b.idx, true, err)
+	go b.probe()
+}
+
+// probe pings the node every timeout until it responds, then returns the node to the rotation.
+func (b *breaker) probe() {
+	var ticker = time.NewTicker(b.conf.Timeout)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-b.done:
+			return
+		case <-ticker.C:
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), b.conf.Timeout)
+		var err = b.node.PingContext(ctx)
+		cancel()
+
+		if err != nil {
+			continue
+		}
+
+		b.mux.Lock()
+		b.failures = 0
+		b.open = false
+		b.mux.Unlock()
+
+		b.evict(b.idx, false, nil)
+
+		return
+	}
+}
+
+func (i *breakerInterceptor) before(ctx context.Context, _ *QueryEvent) (context.Context, error) {
+	return ctx, nil
+}
+
+func (i *breakerInterceptor) after(_ context.Context, e *QueryEvent) {
+	if b := i.breakers[e.Node]; b != nil {
+		b.report(e.Err)
+	}
+}
+
+// isNodeFailure reports whether the error means the node is unreachable rather than the statement failed.
+func isNodeFailure(err error) bool {
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
@@ -0,0 +1,105 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestBreakerReport(t *testing.T) {
+	var (
+		errStatement = errors.New("syntax error")
+		errNetwork   = &net.OpError{Op: "read", Err: errors.New("connection reset")}
+	)
+
+	var cases = []struct {
+		name      string
+		threshold int
+		errs      []error
+		open      bool
+		evicted   int
+	}{
+		{name: "success", threshold: 2, errs: []error{nil, nil}},
+		{name: "below threshold", threshold: 3, errs: []error{driver.ErrBadConn, io.EOF}},
+		{name: "threshold reached", threshold: 2, errs: []error{driver.ErrBadConn, errNetwork}, open: true, evicted: 1},
+		{name: "success resets failures", threshold: 2, errs: []error{driver.ErrBadConn, nil, io.EOF}},
+		{name: "statement errors ignored", threshold: 1, errs: []error{errStatement, errStatement}},
+		{name: "evicted once", threshold: 1, errs: []error{io.EOF, io.EOF, io.EOF}, open: true, evicted: 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				done    = make(chan struct{})
+				evicted int
+			)
+
+			defer close(done)
+
+			var breakers = newBreakers(
+				CircuitBreaker{Threshold: tc.threshold, Timeout: time.Hour}, 2, done,
+				func(idx int, removed bool, err error) {
+					if idx != 1 || !removed || err == nil {
+						t.Errorf("unexpected eviction of node %d removed %t : %v", idx, removed, err)
+					}
+
+					evicted++
+				},
+			)
+
+			if breakers[0] != nil {
+				t.Fatal("master node has breaker")
+			}
+
+			for _, err := range tc.errs {
+				breakers[1].report(err)
+			}
+
+			if open := !breakers[1].available(); open != tc.open {
+				t.Errorf("open is %t, want %t", open, tc.open)
+			}
+
+			if evicted != tc.evicted {
+				t.Errorf("evicted %d times, want %d", evicted, tc.evicted)
+			}
+		})
+	}
+}
+
+func TestNewBreakersDisabled(t *testing.T) {
+	for _, b := range newBreakers(CircuitBreaker{}, 3, nil, nil) {
+		if b != nil {
+			t.Fatal("breaker with zero threshold")
+		}
+	}
+}
+
+func TestIsNodeFailure(t *testing.T) {
+	var cases = []struct {
+		err  error
+		want bool
+	}{
+		{err: driver.ErrBadConn, want: true},
+		{err: fmt.Errorf("query : %w", driver.ErrBadConn), want: true},
+		{err: io.EOF, want: true},
+		{err: io.ErrUnexpectedEOF, want: true},
+		{err: &net.OpError{Op: "dial", Err: errors.New("refused")}, want: true},
+		{err: context.Canceled, want: false},
+		{err: errors.New("duplicate key"), want: false},
+	}
+
+	for _, tc := range cases {
+		if got := isNodeFailure(tc.err); got != tc.want {
+			t.Errorf("isNodeFailure(%v) is %t, want %t", tc.err, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,221 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// DefaultBulkChunkSize is number of rows inserted by a single statement used when the chunk size is not set.
+const DefaultBulkChunkSize = 1000
+
+type (
+	// BulkInsert describes rows inserted by Registry.BulkInsert. The table and the columns are
+	// written to the statement as is, they must be quoted by the caller when needed.
+	BulkInsert struct {
+		Table   string
+		Columns []string
+		Rows    [][]interface{}
+
+		// ChunkSize limits rows of a single statement, DefaultBulkChunkSize by default. The chunks
+		// are further limited by the driver bind parameters limit.
+		ChunkSize int
+
+		// Independent inserts every chunk in its own implicit transaction, the failed chunks are
+		// reported while the rest is inserted. Otherwise all chunks are inserted in a transaction.
+		Independent bool
+	}
+
+	// ChunkError is error of the bulk insert chunk.
+	ChunkError struct {
+		// Chunk is index of the chunk, Offset is index of its first row and Rows is its rows count.
+		Chunk  int
+		Offset int
+		Rows   int
+		Err    error
+	}
+
+	// ChunksError is combined error of every failed chunk of independent bulk insert.
+	ChunksError []*ChunkError
+)
+
+// Error implements the error interface.
+func (e *ChunkError) Error() string {
+	return fmt.Sprintf("bulk insert chunk %d of rows %d-%d : %s", e.Chunk, e.Offset, e.Offset+e.Rows-1, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ChunkError) Unwrap() error {
+	return e.Err
+}
+
+// Error implements the error interface.
+func (e ChunksError) Error() string {
+	var parts = make([]string, 0, len(e))
+	for _, err := range e {
+		parts = append(parts, err.Error())
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// BulkInsert inserts the rows into the connection master node by multi-row INSERT statements with
+// the driver placeholders and returns number of the inserted rows. All chunks are inserted by WithTx,
+// so the first failed one rolls back the rest and is returned as ChunkError, unless the insert is
+// independent, then ChunksError of every failed chunk is returned.
+func (r *Registry) BulkInsert(ctx context.Context, name string, insert BulkInsert) (inserted int64, err error) {
+	if len(insert.Columns) == 0 {
+		return 0, errors.New("bulk insert columns are required")
+	}
+
+	for i, row := range insert.Rows {
+		if len(row) != len(insert.Columns) {
+			return 0, fmt.Errorf("bulk insert row %d has %d values of %d columns", i, len(row), len(insert.Columns))
+		}
+	}
+
+	var conf Config
+	if conf, err = r.Config(name); err != nil {
+		return 0, err
+	}
+
+	var size = insert.chunkSize(conf.Driver)
+
+	if insert.Independent {
+		var db Executor
+		if db, err = r.ExecutorFromContext(ctx, name); err != nil {
+			return 0, err
+		}
+
+		var failed ChunksError
+		for chunk, offset := 0, 0; offset < len(insert.Rows); chunk, offset = chunk+1, offset+size {
+			var n, chunkErr = insert.exec(ctx, db, conf.Driver, chunk, offset, size)
+			if chunkErr != nil {
+				failed = append(failed, chunkErr)
+			}
+
+			inserted += n
+		}
+
+		if len(failed) > 0 {
+			return inserted, failed
+		}
+
+		return inserted, nil
+	}
+
+	err = r.WithTx(ctx, name, nil, func(ctx context.Context, tx *sql.Tx) error {
+		inserted = 0
+
+		for chunk, offset := 0, 0; offset < len(insert.Rows); chunk, offset = chunk+1, offset+size {
+			var n, chunkErr = insert.exec(ctx, tx, conf.Driver, chunk, offset, size)
+			if chunkErr != nil {
+				return chunkErr
+			}
+
+			inserted += n
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return inserted, nil
+}
+
+// chunkSize returns rows of a single statement fitting the driver bind parameters limit.
+func (b BulkInsert) chunkSize(driverName string) int {
+	var size = b.ChunkSize
+	if size <= 0 {
+		size = DefaultBulkChunkSize
+	}
+
+	if limit := maxBindParams(driverName) / len(b.Columns); limit < size {
+		size = limit
+	}
+
+	if size < 1 {
+		size = 1
+	}
+
+	return size
+}
+
+// exec inserts the chunk of rows starting at the offset.
+func (b BulkInsert) exec(ctx context.Context, db Executor, driverName string, chunk, offset, size int) (int64, *ChunkError) {
+	var rows = b.Rows[offset:]
+	if len(rows) > size {
+		rows = rows[:size]
+	}
+
+	var query, args = b.statement(driverName, rows)
+
+	var result, err = db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return 0, &ChunkError{Chunk: chunk, Offset: offset, Rows: len(rows), Err: err}
+	}
+
+	var n int64
+	if n, err = result.RowsAffected(); err != nil {
+		n = int64(len(rows))
+	}
+
+	return n, nil
+}
+
+// statement returns multi-row INSERT statement of the rows and its arguments.
+func (b BulkInsert) statement(driverName string, rows [][]interface{}) (string, []interface{}) {
+	var query strings.Builder
+	query.WriteString("INSERT INTO " + b.Table + " (" + strings.Join(b.Columns, ", ") + ") VALUES ")
+
+	var args = writeValues(&query, driverName, rows)
+
+	return query.String(), args
+}
+
+// writeValues writes the rows as VALUES list of the driver placeholders and returns their arguments.
+func writeValues(query *strings.Builder, driverName string, rows [][]interface{}) []interface{} {
+	var args = make([]interface{}, 0, len(rows)*len(rows[0]))
+	for i, row := range rows {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+
+		query.WriteByte('(')
+		for j, value := range row {
+			if j > 0 {
+				query.WriteString(", ")
+			}
+
+			args = append(args, value)
+			query.WriteString(placeholder(driverName, len(args)))
+		}
+
+		query.WriteByte(')')
+	}
+
+	return args
+}
+
+// maxBindParams returns the driver limit of the statement bind parameters.
+func maxBindParams(driverName string) int {
+	switch driverName {
+	case "postgres", "pgx", "cloudsqlpostgres", "mysql", DriverClickHouse:
+		return 65535
+	}
+
+	if isSQLite(driverName) {
+		return 999
+	}
+
+	return 2100
+}
@@ -0,0 +1,399 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"database/sql/driver"
+	"encoding/gob"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheSize is entry limit of the default in-memory query cache store.
+const DefaultCacheSize = 1024
+
+type (
+	// CacheStore stores the cached query results, keys and tags are already prefixed by the connection name.
+	CacheStore interface {
+		// Get returns the value of the key, ok is false when it is missing or expired.
+		Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+
+		// Set stores the value of the key for the ttl and attaches the tags to it.
+		Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags []string) error
+
+		// Invalidate removes every value attached to any of the tags.
+		Invalidate(ctx context.Context, tags ...string) error
+	}
+
+	// MemoryCache is in-memory LRU CacheStore.
+	MemoryCache struct {
+		mux     sync.Mutex
+		size    int
+		entries map[string]*list.Element
+		tags    map[string]map[string]struct{}
+		lru     *list.List
+		now     func() time.Time
+	}
+
+	// memoryEntry is MemoryCache entry.
+	memoryEntry struct {
+		key     string
+		value   []byte
+		expires time.Time
+		tags    []string
+	}
+
+	// cacheKey is context key of the cache tags of the reads.
+	cacheKey struct{}
+
+	// invalidationKey is context key of the cache tags invalidated by the writes.
+	invalidationKey struct{}
+
+	// queryCache caches the connection reads made with the ContextWithCache contexts.
+	queryCache struct {
+		name   string
+		ttl    time.Duration
+		store  CacheStore
+		logger Logger
+	}
+
+	// cachedResult is encoded cached query result.
+	cachedResult struct {
+		Columns []string
+		Rows    [][]driver.Value
+	}
+
+	// cachedRows is driver.Rows replaying the cached query result.
+	cachedRows struct {
+		result cachedResult
+		pos    int
+	}
+
+	// recordingRows is driver.Rows storing the query result to the cache once it is read completely.
+	recordingRows struct {
+		*wrappedRows
+		cache  *queryCache
+		key    string
+		tags   []string
+		result cachedResult
+		done   bool
+		skip   bool
+	}
+
+	// cacheInterceptor invalidates the cache tags of the context after a successful write.
+	cacheInterceptor struct {
+		cache *queryCache
+	}
+)
+
+func init() {
+	gob.Register(time.Time{})
+}
+
+// WithCacheStore sets the store of the query cache, by default it is in-memory LRU of DefaultCacheSize entries.
+func WithCacheStore(store CacheStore) Option {
+	return optionFunc(func(r *Registry) {
+		r.cacheStore = store
+	})
+}
+
+// ContextWithCache returns context whose reads on connections with CacheTTL are served from the query
+// cache, the cached results are attached to the tags for invalidation.
+func ContextWithCache(ctx context.Context, tags ...string) context.Context {
+	return context.WithValue(ctx, cacheKey{}, tags)
+}
+
+// ContextWithInvalidation returns context whose successful writes invalidate the cached results of
+// the tags on the connection the writes are made on.
+func ContextWithInvalidation(ctx context.Context, tags ...string) context.Context {
+	return context.WithValue(ctx, invalidationKey{}, tags)
+}
+
+// InvalidateCache removes the cached results of the connection attached to any of the tags.
+func (r *Registry) InvalidateCache(ctx context.Context, name string, tags ...string) error {
+	if err := r.cacheStore.Invalidate(ctx, cacheTags(name, tags)...); err != nil {
+		return fmt.Errorf("unable invalidate %s connection cache : %w", name, err)
+	}
+
+	return nil
+}
+
+// NewMemoryCache returns in-memory CacheStore evicting the least recently used of more than size entries.
+func NewMemoryCache(size int) *MemoryCache {
+	if size <= 0 {
+		size = DefaultCacheSize
+	}
+
+	return &MemoryCache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		tags:    make(map[string]map[string]struct{}),
+		lru:     list.New(),
+		now:     time.Now,
+	}
+}
+
+// Get implements CacheStore.
+func (m *MemoryCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	var elem, ok = m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	var entry = elem.Value.(*memoryEntry)
+	if !m.now().Before(entry.expires) {
+		m.remove(elem)
+		return nil, false, nil
+	}
+
+	m.lru.MoveToFront(elem)
+
+	return entry.value, true, nil
+}
+
+// Set implements CacheStore.
+func (m *MemoryCache) Set(_ context.Context, key string, value []byte, ttl time.Duration, tags []string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	if elem, ok := m.entries[key]; ok {
+		m.remove(elem)
+	}
+
+	var entry = &memoryEntry{key: key, value: value, expires: m.now().Add(ttl), tags: tags}
+	m.entries[key] = m.lru.PushFront(entry)
+
+	for _, tag := range tags {
+		if m.tags[tag] == nil {
+			m.tags[tag] = make(map[string]struct{})
+		}
+
+		m.tags[tag][key] = struct{}{}
+	}
+
+	for m.lru.Len() > m.size {
+		m.remove(m.lru.Back())
+	}
+
+	return nil
+}
+
+// Invalidate implements CacheStore.
+func (m *MemoryCache) Invalidate(_ context.Context, tags ...string) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	for _, tag := range tags {
+		for key := range m.tags[tag] {
+			if elem, ok := m.entries[key]; ok {
+				m.remove(elem)
+			}
+		}
+
+		delete(m.tags, tag)
+	}
+
+	return nil
+}
+
+// remove drops the entry element. The mutex must be held.
+func (m *MemoryCache) remove(elem *list.Element) {
+	var entry = m.lru.Remove(elem).(*memoryEntry)
+	delete(m.entries, entry.key)
+
+	for _, tag := range entry.tags {
+		if keys, ok := m.tags[tag]; ok {
+			delete(keys, entry.key)
+			if len(keys) == 0 {
+				delete(m.tags, tag)
+			}
+		}
+	}
+}
+
+// newQueryCache returns the connection query cache or nil if the ttl is not set.
+func newQueryCache(name string, ttl time.Duration, store CacheStore, logger Logger) *queryCache {
+	if ttl <= 0 || store == nil {
+		return nil
+	}
+
+	return &queryCache{name: name, ttl: ttl, store: store, logger: logger}
+}
+
+// cacheTags returns the tags prefixed by the connection name.
+func cacheTags(name string, tags []string) []string {
+	var prefixed = make([]string, 0, len(tags))
+	for _, tag := range tags {
+		prefixed = append(prefixed, name+":tag:"+tag)
+	}
+
+	return prefixed
+}
+
+// lookup returns the key of the query made with ContextWithCache context and the cached rows on hit,
+// the key is empty when the query is not cached.
+func (c *queryCache) lookup(ctx context.Context, query string, args []driver.NamedValue) (string, driver.Rows) {
+	if c == nil {
+		return "", nil
+	}
+
+	if _, ok := ctx.Value(cacheKey{}).([]string); !ok {
+		return "", nil
+	}
+
+	var hash = sha256.New()
+	_, _ = io.WriteString(hash, strings.Join(strings.Fields(query), " "))
+	for _, arg := range args {
+		_, _ = fmt.Fprintf(hash, "\x00%s:%d:%T:%v", arg.Name, arg.Ordinal, arg.Value, arg.Value)
+	}
+
+	var (
+		key            = c.name + ":query:" + hex.EncodeToString(hash.Sum(nil))
+		value, ok, err = c.store.Get(ctx, key)
+	)
+
+	if err != nil {
+		c.logger.Error("query cache get failed", err, "connection", c.name)
+		return key, nil
+	}
+
+	if !ok {
+		return key, nil
+	}
+
+	var rows cachedRows
+	if err = gob.NewDecoder(bytes.NewReader(value)).Decode(&rows.result); err != nil {
+		c.logger.Error("query cache decode failed", err, "connection", c.name)
+		return key, nil
+	}
+
+	return key, &rows
+}
+
+// record returns rows storing the result under the key once they are read completely.
+func (c *queryCache) record(ctx context.Context, key string, rows driver.Rows, err error) (driver.Rows, error) {
+	if key == "" || err != nil {
+		return rows, err
+	}
+
+	var wrapped, ok = rows.(*wrappedRows)
+	if !ok {
+		wrapped = &wrappedRows{Rows: rows}
+	}
+
+	var tags, _ = ctx.Value(cacheKey{}).([]string)
+
+	return &recordingRows{
+		wrappedRows: wrapped,
+		cache:       c,
+		key:         key,
+		tags:        cacheTags(c.name, tags),
+		result:      cachedResult{Columns: wrapped.Columns()},
+	}, nil
+}
+
+// save stores the result under the key.
+func (c *queryCache) save(key string, result cachedResult, tags []string) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(result); err != nil {
+		c.logger.Error("query cache encode failed", err, "connection", c.name)
+		return
+	}
+
+	if err := c.store.Set(context.Background(), key, buf.Bytes(), c.ttl, tags); err != nil {
+		c.logger.Error("query cache set failed", err, "connection", c.name)
+	}
+}
+
+// Columns implements driver.Rows.
+func (r *cachedRows) Columns() []string {
+	return r.result.Columns
+}
+
+// Close implements driver.Rows.
+func (r *cachedRows) Close() error {
+	return nil
+}
+
+// Next implements driver.Rows.
+func (r *cachedRows) Next(dest []driver.Value) error {
+	if r.pos >= len(r.result.Rows) {
+		return io.EOF
+	}
+
+	copy(dest, r.result.Rows[r.pos])
+	r.pos++
+
+	return nil
+}
+
+// Next implements driver.Rows.
+func (r *recordingRows) Next(dest []driver.Value) error {
+	var err = r.wrappedRows.Next(dest)
+	switch {
+	case err == io.EOF:
+		r.done = true
+	case err != nil:
+		r.skip = true
+	case !r.skip:
+		var row = make([]driver.Value, len(dest))
+		for i, value := range dest {
+			if b, ok := value.([]byte); ok {
+				value = append([]byte(nil), b...)
+			}
+
+			row[i] = value
+		}
+
+		r.result.Rows = append(r.result.Rows, row)
+	}
+
+	return err
+}
+
+// NextResultSet implements driver.RowsNextResultSet, results of several sets are not cached.
+func (r *recordingRows) NextResultSet() error {
+	r.skip = true
+	return r.wrappedRows.NextResultSet()
+}
+
+// Close implements driver.Rows.
+func (r *recordingRows) Close() error {
+	if r.done && !r.skip {
+		r.cache.save(r.key, r.result, r.tags)
+	}
+
+	return r.wrappedRows.Close()
+}
+
+func (i cacheInterceptor) before(ctx context.Context, _ *QueryEvent) (context.Context, error) {
+	return ctx, nil
+}
+
+func (i cacheInterceptor) after(ctx context.Context, e *QueryEvent) {
+	if e.Err != nil || e.Op != OpExec && e.Op != OpQuery && e.Op != OpCommit {
+		return
+	}
+
+	var tags, ok = ctx.Value(invalidationKey{}).([]string)
+	if !ok || len(tags) == 0 {
+		return
+	}
+
+	if err := i.cache.store.Invalidate(ctx, cacheTags(i.cache.name, tags)...); err != nil {
+		i.cache.logger.Error("query cache invalidation failed", err, "connection", i.cache.name)
+	}
+}
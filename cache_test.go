@@ -0,0 +1,141 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMemoryCache(t *testing.T) {
+	var (
+		ctx   = context.Background()
+		now   = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		cache = NewMemoryCache(2)
+	)
+
+	cache.now = func() time.Time { return now }
+
+	var set = func(key string, ttl time.Duration, tags ...string) {
+		if err := cache.Set(ctx, key, []byte(key), ttl, tags); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var cached = func(keys ...string) {
+		t.Helper()
+
+		for _, key := range []string{"a", "b", "c"} {
+			var want bool
+			for _, k := range keys {
+				want = want || k == key
+			}
+
+			if _, ok, _ := cache.Get(ctx, key); ok != want {
+				t.Errorf("%s is cached %t, want %t", key, ok, want)
+			}
+		}
+	}
+
+	set("a", time.Minute, "users")
+	set("b", time.Hour, "orders")
+	cached("a", "b")
+
+	set("c", time.Hour, "users", "orders")
+	cached("b", "c")
+
+	if err := cache.Invalidate(ctx, "users"); err != nil {
+		t.Fatal(err)
+	}
+
+	cached("b")
+
+	set("a", time.Minute, "users")
+	now = now.Add(time.Minute)
+	cached("b")
+
+	if len(cache.entries) != 1 || len(cache.tags) != 1 || cache.lru.Len() != 1 {
+		t.Errorf("%d entries, %d tags and %d lru elements are left, want 1", len(cache.entries), len(cache.tags), cache.lru.Len())
+	}
+}
+
+func TestRegistryQueryCache(t *testing.T) {
+	var db, mock, err = sqlmock.NewWithDSN("gozix_cache_master")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	var registry *Registry
+	if registry, err = NewRegistry(Configs{"main": {
+		Driver:   "sqlmock",
+		Nodes:    []string{"gozix_cache_master"},
+		CacheTTL: time.Minute,
+	}}); err != nil {
+		t.Fatal(err)
+	}
+
+	defer registry.Close()
+
+	var conn, _ = registry.ConnectionWithName("main")
+
+	var query = func(ctx context.Context) (names []string) {
+		t.Helper()
+
+		var rows, err = conn.QueryContext(ctx, "SELECT name FROM users WHERE id > ?", 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var name string
+			if err = rows.Scan(&name); err != nil {
+				t.Fatal(err)
+			}
+
+			names = append(names, name)
+		}
+
+		if err = rows.Err(); err != nil {
+			t.Fatal(err)
+		}
+
+		return names
+	}
+
+	var (
+		users   = sqlmock.NewRows([]string{"name"}).AddRow("alice").AddRow("bob")
+		renamed = sqlmock.NewRows([]string{"name"}).AddRow("carol")
+		ctx     = ContextWithCache(context.Background(), "users")
+	)
+
+	mock.ExpectQuery("SELECT name FROM users").WithArgs(1).WillReturnRows(users)
+	mock.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery("SELECT name FROM users").WithArgs(1).WillReturnRows(renamed)
+
+	for i := 0; i < 2; i++ {
+		if got := query(ctx); len(got) != 2 || got[0] != "alice" || got[1] != "bob" {
+			t.Fatalf("names are %v, want alice and bob", got)
+		}
+	}
+
+	if _, err = conn.ExecContext(ContextWithInvalidation(context.Background(), "users"), "UPDATE users SET name = 'carol'"); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := query(ctx); len(got) != 1 || got[0] != "carol" {
+		t.Errorf("names are %v, want invalidated result", got)
+	}
+
+	if err = mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,115 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// DriverClickHouse is name of the ClickHouse database/sql driver.
+const DriverClickHouse = "clickhouse"
+
+// clickhouseSchemes are DSN schemes accepted by the ClickHouse driver.
+var clickhouseSchemes = []string{"clickhouse", "tcp", "http", "https"}
+
+// clickhouseHosts returns hosts of the ClickHouse DSN, a single node DSN may list several comma separated
+// hosts the driver balances the physical connections over.
+func clickhouseHosts(dsn string) ([]string, error) {
+	var scheme, rest, ok = strings.Cut(dsn, "://")
+	if !ok {
+		return nil, errors.New("clickhouse dsn must be an url")
+	}
+
+	var known bool
+	for _, s := range clickhouseSchemes {
+		known = known || s == scheme
+	}
+
+	if !known {
+		return nil, fmt.Errorf("unknown clickhouse dsn scheme %q", scheme)
+	}
+
+	if i := strings.IndexAny(rest, "/?"); i >= 0 {
+		rest = rest[:i]
+	}
+
+	if i := strings.LastIndex(rest, "@"); i >= 0 {
+		rest = rest[i+1:]
+	}
+
+	var hosts = strings.Split(rest, ",")
+	for _, host := range hosts {
+		if host == "" {
+			return nil, errors.New("empty host in clickhouse dsn")
+		}
+	}
+
+	return hosts, nil
+}
+
+// clickhouseProblems returns problems of the ClickHouse connection configuration. ClickHouse has no
+// transactions, sessions or server side prepared statements, the settings relying on them are rejected.
+func (c Config) clickhouseProblems() (problems []error) {
+	for i, node := range c.NodeConfigs {
+		if node.DSN == "" {
+			continue
+		}
+
+		if _, err := clickhouseHosts(node.DSN); err != nil {
+			problems = append(problems, fmt.Errorf("node %d : %w", i, err))
+		}
+
+		if _, query, ok := strings.Cut(node.DSN, "?"); ok {
+			var values, _ = url.ParseQuery(query)
+			switch strategy := values.Get("connection_open_strategy"); strategy {
+			case "", "in_order", "round_robin", "random":
+			default:
+				problems = append(problems, fmt.Errorf("node %d : unknown connection_open_strategy %q", i, strategy))
+			}
+		}
+	}
+
+	var unsupported = []struct {
+		key string
+		set bool
+	}{
+		{key: "tx_isolation", set: c.TxIsolation != ""},
+		{key: "tx_read_only", set: c.TxReadOnly},
+		{key: "read_only_slaves", set: c.ReadOnlySlaves},
+		{key: "stmt_cache_size", set: c.StmtCacheSize > 0},
+	}
+
+	for _, setting := range unsupported {
+		if setting.set {
+			problems = append(problems, fmt.Errorf("%s is not supported by %s driver", setting.key, DriverClickHouse))
+		}
+	}
+
+	return problems
+}
+
+// applyClickHouse returns the DSN switched to the secure native protocol, the driver loads no certificate files.
+func (t *TLS) applyClickHouse(dsn string) (string, error) {
+	if len(t.CAFile) > 0 || len(t.CertFile) > 0 || len(t.KeyFile) > 0 || len(t.ServerName) > 0 {
+		return "", fmt.Errorf("%w %s : only insecure_skip_verify can be set", ErrTLSUnsupported, DriverClickHouse)
+	}
+
+	var base, query, _ = strings.Cut(dsn, "?")
+
+	var values, err = url.ParseQuery(query)
+	if err != nil {
+		return "", err
+	}
+
+	values.Set("secure", "true")
+	if t.InsecureSkipVerify {
+		values.Set("skip_verify", "true")
+	}
+
+	return base + "?" + values.Encode(), nil
+}
@@ -0,0 +1,38 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import "time"
+
+type (
+	// Clock is the registry time source.
+	Clock interface {
+		Now() time.Time
+	}
+
+	// ClockFunc is function adapter of the Clock interface.
+	ClockFunc func() time.Time
+
+	// systemClock is Clock returning the system time.
+	systemClock struct{}
+)
+
+// WithClock option sets the registry time source used to track read-your-writes windows, the
+// system clock is used by default.
+func WithClock(clock Clock) Option {
+	return optionFunc(func(r *Registry) {
+		r.clock = clock
+	})
+}
+
+// Now implements Clock.
+func (f ClockFunc) Now() time.Time {
+	return f()
+}
+
+// Now implements Clock.
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
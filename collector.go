@@ -6,77 +6,91 @@ package sql
 
 import (
 	"database/sql"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-type prometheusCollector struct {
-	db *sql.DB
-
-	maxOpenConnections *prometheus.Desc
-	openConnections    *prometheus.Desc
-	inUse              *prometheus.Desc
-	idle               *prometheus.Desc
-	waitCount          *prometheus.Desc
-	waitDuration       *prometheus.Desc
-	maxIdleClosed      *prometheus.Desc
-	maxIdleTimeClosed  *prometheus.Desc
-	maxLifetimeClosed  *prometheus.Desc
-}
+// DefaultMetricsInterval is default pool statistics sampling interval.
+const DefaultMetricsInterval = 15 * time.Second
+
+type (
+	// prometheusCollector exports the last sampled pool statistics of every opened node.
+	prometheusCollector struct {
+		mux     sync.RWMutex
+		samples []poolSample
 
-// newPrometheusCollector returns a collector that exports metrics about the db.
-func newPrometheusCollector(name string, db *sql.DB) prometheus.Collector {
-	var labels = prometheus.Labels{
-		"name": name,
+		maxOpenConnections *prometheus.Desc
+		openConnections    *prometheus.Desc
+		inUse              *prometheus.Desc
+		idle               *prometheus.Desc
+		waitCount          *prometheus.Desc
+		waitDuration       *prometheus.Desc
+		maxIdleClosed      *prometheus.Desc
+		maxIdleTimeClosed  *prometheus.Desc
+		maxLifetimeClosed  *prometheus.Desc
 	}
 
-	return &prometheusCollector{
-		db: db,
+	// poolSample is pool statistics of the node at sampling moment.
+	poolSample struct {
+		connection string
+		node       string
+		role       string
+		stats      sql.DBStats
+	}
+)
 
+// newPrometheusCollector returns a collector that exports metrics about the registry pools.
+func newPrometheusCollector() *prometheusCollector {
+	var labels = []string{"connection", "node", "role"}
+
+	return &prometheusCollector{
 		maxOpenConnections: prometheus.NewDesc(
 			"max_open_connections",
 			"Maximum number of open connections to the database",
-			nil, labels,
+			labels, nil,
 		),
 		openConnections: prometheus.NewDesc(
 			"open_connections",
 			"The number of established connections both in use and idle",
-			nil, labels,
+			labels, nil,
 		),
 		inUse: prometheus.NewDesc(
 			"in_use_connections",
 			"The number of connections currently in use",
-			nil, labels,
+			labels, nil,
 		),
 		idle: prometheus.NewDesc(
 			"idle_connections",
 			"The number of idle connections",
-			nil, labels,
+			labels, nil,
 		),
 		waitCount: prometheus.NewDesc(
 			"wait_connections",
 			"The total number of connections waited for",
-			nil, labels,
+			labels, nil,
 		),
 		waitDuration: prometheus.NewDesc(
 			"wait_duration_connections",
 			"The total time blocked waiting for a new connection",
-			nil, labels,
+			labels, nil,
 		),
 		maxIdleClosed: prometheus.NewDesc(
 			"max_idle_closed_connections",
 			"The total number of connections closed due to SetMaxIdleConns",
-			nil, labels,
+			labels, nil,
 		),
 		maxIdleTimeClosed: prometheus.NewDesc(
 			"max_idle_time_closed_connections",
 			"The total number of connections closed due to SetConnMaxIdleTime",
-			nil, labels,
+			labels, nil,
 		),
 		maxLifetimeClosed: prometheus.NewDesc(
 			"max_lifetime_closed_connections",
 			"The total number of connections closed due to SetConnMaxLifetime",
-			nil, labels,
+			labels, nil,
 		),
 	}
 }
@@ -94,17 +108,61 @@ func (c *prometheusCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- c.maxLifetimeClosed
 }
 
-// Collect returns the current state of all metrics of the collector.
+// Collect returns the last sampled state of all metrics of the collector.
 func (c *prometheusCollector) Collect(ch chan<- prometheus.Metric) {
-	var stats = c.db.Stats()
-
-	ch <- prometheus.MustNewConstMetric(c.maxOpenConnections, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
-	ch <- prometheus.MustNewConstMetric(c.openConnections, prometheus.GaugeValue, float64(stats.OpenConnections))
-	ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse))
-	ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle))
-	ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
-	ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, float64(stats.WaitDuration))
-	ch <- prometheus.MustNewConstMetric(c.maxIdleClosed, prometheus.CounterValue, float64(stats.MaxIdleClosed))
-	ch <- prometheus.MustNewConstMetric(c.maxIdleTimeClosed, prometheus.CounterValue, float64(stats.MaxIdleTimeClosed))
-	ch <- prometheus.MustNewConstMetric(c.maxLifetimeClosed, prometheus.CounterValue, float64(stats.MaxLifetimeClosed))
+	c.mux.RLock()
+	defer c.mux.RUnlock()
+
+	for _, sample := range c.samples {
+		var (
+			stats  = sample.stats
+			labels = []string{sample.connection, sample.node, sample.role}
+		)
+
+		ch <- prometheus.MustNewConstMetric(c.maxOpenConnections, prometheus.GaugeValue, float64(stats.MaxOpenConnections), labels...)
+		ch <- prometheus.MustNewConstMetric(c.openConnections, prometheus.GaugeValue, float64(stats.OpenConnections), labels...)
+		ch <- prometheus.MustNewConstMetric(c.inUse, prometheus.GaugeValue, float64(stats.InUse), labels...)
+		ch <- prometheus.MustNewConstMetric(c.idle, prometheus.GaugeValue, float64(stats.Idle), labels...)
+		ch <- prometheus.MustNewConstMetric(c.waitCount, prometheus.CounterValue, float64(stats.WaitCount), labels...)
+		ch <- prometheus.MustNewConstMetric(c.waitDuration, prometheus.CounterValue, float64(stats.WaitDuration), labels...)
+		ch <- prometheus.MustNewConstMetric(c.maxIdleClosed, prometheus.CounterValue, float64(stats.MaxIdleClosed), labels...)
+		ch <- prometheus.MustNewConstMetric(c.maxIdleTimeClosed, prometheus.CounterValue, float64(stats.MaxIdleTimeClosed), labels...)
+		ch <- prometheus.MustNewConstMetric(c.maxLifetimeClosed, prometheus.CounterValue, float64(stats.MaxLifetimeClosed), labels...)
+	}
+}
+
+// sample replaces exported statistics with the current state of the registry pools.
+func (c *prometheusCollector) sample(r *Registry) {
+	var samples = make([]poolSample, 0, len(c.samples))
+
+	for name, stats := range r.Stats() {
+		for i, node := range stats.Nodes {
+			samples = append(samples, poolSample{
+				connection: name,
+				node:       strconv.Itoa(i),
+				role:       node.Role,
+				stats:      node.DBStats,
+			})
+		}
+	}
+
+	c.mux.Lock()
+	c.samples = samples
+	c.mux.Unlock()
+}
+
+// run samples the registry pools every interval until done is closed.
+func (c *prometheusCollector) run(r *Registry, interval time.Duration, done <-chan struct{}) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		c.sample(r)
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
 }
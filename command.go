@@ -0,0 +1,160 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// errChecksFailed is error of the command reporting failed connections.
+var errChecksFailed = errors.New("some connections failed")
+
+// provideConnectionsCmd returns command listing configured connections.
+func provideConnectionsCmd(registry *Registry) *cobra.Command {
+	return &cobra.Command{
+		Use:           "sql:connections",
+		Short:         "List configured database connections",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				stats = registry.Stats()
+				w     = newTabWriter(cmd.OutOrStdout())
+			)
+
+			_, _ = fmt.Fprintln(w, "NAME\tDRIVER\tNODES\tOPENED")
+			for _, name := range registry.Names() {
+				var conf, err = registry.Config(name)
+				if err != nil {
+					continue
+				}
+
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%d\t%t\n", name, conf.Driver, len(conf.NodeConfigs), stats[name].Open)
+			}
+
+			return w.Flush()
+		},
+	}
+}
+
+// providePingCmd returns command opening and pinging every configured connection.
+func providePingCmd(registry *Registry) *cobra.Command {
+	var cmd = cobra.Command{
+		Use:           "sql:ping [name...]",
+		Short:         "Open and ping database connections",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	var timeout = cmd.Flags().Duration("timeout", DefaultPingTimeout, "ping timeout")
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) error {
+		var names = args
+		if len(names) == 0 {
+			names = registry.Names()
+		}
+
+		var (
+			ctx, cancel = context.WithTimeout(cmd.Context(), *timeout)
+			w           = newTabWriter(cmd.OutOrStdout())
+			failed      bool
+		)
+
+		defer cancel()
+
+		_, _ = fmt.Fprintln(w, "NAME\tSTATUS\tTIME")
+		for _, name := range names {
+			var (
+				start = time.Now()
+				err   = ping(ctx, registry, name)
+				took  = time.Since(start).Round(time.Millisecond)
+			)
+
+			if err != nil {
+				failed = true
+				_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", name, err, took)
+				continue
+			}
+
+			_, _ = fmt.Fprintf(w, "%s\tok\t%s\n", name, took)
+		}
+
+		if err := w.Flush(); err != nil {
+			return err
+		}
+
+		if failed {
+			return errChecksFailed
+		}
+
+		return nil
+	}
+
+	return &cmd
+}
+
+// provideStatusCmd returns command printing pool statistics of the opened connections.
+func provideStatusCmd(registry *Registry) *cobra.Command {
+	return &cobra.Command{
+		Use:           "sql:status",
+		Short:         "Print pool statistics of database connections",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var (
+				stats = registry.Stats()
+				names = make([]string, 0, len(stats))
+				w     = newTabWriter(cmd.OutOrStdout())
+			)
+
+			for name := range stats {
+				names = append(names, name)
+			}
+
+			sort.Strings(names)
+
+			_, _ = fmt.Fprintln(w, "NAME\tNODE\tROLE\tAVAILABLE\tOPEN\tIN USE\tIDLE\tMAX OPEN\tWAIT COUNT\tWAIT DURATION")
+			for _, name := range names {
+				if !stats[name].Open {
+					_, _ = fmt.Fprintf(w, "%s\t-\t-\t-\t-\t-\t-\t-\t-\t-\n", name)
+					continue
+				}
+
+				for i, node := range stats[name].Nodes {
+					_, _ = fmt.Fprintf(
+						w, "%s\t%d\t%s\t%t\t%d\t%d\t%d\t%d\t%d\t%s\n",
+						name, i, node.Role, node.Available, node.OpenConnections, node.InUse, node.Idle,
+						node.MaxOpenConnections, node.WaitCount, node.WaitDuration,
+					)
+				}
+			}
+
+			return w.Flush()
+		},
+	}
+}
+
+// ping opens the named connection if needed and pings every its node.
+func ping(ctx context.Context, registry *Registry, name string) error {
+	var c, err = registry.connection(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	return c.ping(ctx)
+}
+
+// newTabWriter returns writer aligning the command output columns.
+func newTabWriter(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+}
@@ -0,0 +1,115 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type (
+	// commentInterceptor appends SQLCommenter comment to the statements.
+	commentInterceptor struct {
+		application string
+	}
+
+	// commentKey is context key of the statement comment tags.
+	commentKey struct{}
+)
+
+// WithQueryComments option appends SQLCommenter comment to every statement, so the server logs can
+// be correlated with the application traces. The comment carries the application name, the driver,
+// the W3C traceparent of the context span, the correlation ID and the tags set by
+// ContextWithQueryComment. Statements already containing a comment are left untouched.
+func WithQueryComments(application string) Option {
+	return optionFunc(func(r *Registry) {
+		r.chain = append(r.chain, &commentInterceptor{application: application})
+	})
+}
+
+// ContextWithQueryComment returns context whose statements are commented with the tag, e.g. route.
+func ContextWithQueryComment(ctx context.Context, key, value string) context.Context {
+	var parent, _ = ctx.Value(commentKey{}).(map[string]string)
+
+	var tags = make(map[string]string, len(parent)+1)
+	for k, v := range parent {
+		tags[k] = v
+	}
+
+	tags[key] = value
+
+	return context.WithValue(ctx, commentKey{}, tags)
+}
+
+func (i *commentInterceptor) before(ctx context.Context, e *QueryEvent) (context.Context, error) {
+	if e.Op != OpExec && e.Op != OpQuery && e.Op != OpPrepare || e.Query == "" {
+		return ctx, nil
+	}
+
+	if strings.Contains(e.Query, "/*") || strings.Contains(e.Query, "--") {
+		return ctx, nil
+	}
+
+	var tags = map[string]string{"db_driver": e.Driver}
+	if len(i.application) > 0 {
+		tags["application"] = i.application
+	}
+
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		tags["traceparent"] = fmt.Sprintf("00-%s-%s-%02x", span.TraceID(), span.SpanID(), byte(span.TraceFlags()))
+		if state := span.TraceState().String(); len(state) > 0 {
+			tags["tracestate"] = state
+		}
+	}
+
+	if len(e.CorrelationID) > 0 {
+		tags["correlation_id"] = e.CorrelationID
+	}
+
+	if custom, ok := ctx.Value(commentKey{}).(map[string]string); ok {
+		for key, value := range custom {
+			tags[key] = value
+		}
+	}
+
+	var query = strings.TrimRight(e.Query, " \t\r\n")
+	if trimmed := strings.TrimSuffix(query, ";"); trimmed != query {
+		e.Query = trimmed + " " + sqlComment(tags) + ";"
+	} else {
+		e.Query = query + " " + sqlComment(tags)
+	}
+
+	return ctx, nil
+}
+
+func (i *commentInterceptor) after(context.Context, *QueryEvent) {}
+
+// sqlComment returns the SQLCommenter comment of the tags, they are sorted by key, keys and values
+// are URL encoded and values are quoted.
+func sqlComment(tags map[string]string) string {
+	var keys = make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var pairs = make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, commentEscape(key)+"='"+commentEscape(tags[key])+"'")
+	}
+
+	return "/*" + strings.Join(pairs, ",") + "*/"
+}
+
+// commentEscape URL encodes the value, single quotes included.
+func commentEscape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
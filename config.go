@@ -0,0 +1,289 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+type (
+	// ConfigSource is a source of the connections configurations.
+	ConfigSource interface {
+		Configs() (Configs, error)
+	}
+
+	// ConfigSourceFunc is a function implementing the ConfigSource interface.
+	ConfigSourceFunc func() (Configs, error)
+
+	// ValidationError is combined error of the invalid configurations, it holds every problem found
+	// keyed by the connection name.
+	ValidationError map[string][]error
+)
+
+// ConfigSourceFunc implements ConfigSource interface.
+var _ ConfigSource = ConfigSourceFunc(nil)
+
+// Configs implements the ConfigSource interface.
+func (f ConfigSourceFunc) Configs() (Configs, error) {
+	return f()
+}
+
+// FileSource returns source reading configurations from the JSON or YAML file.
+func FileSource(path string) ConfigSource {
+	return ConfigSourceFunc(func() (Configs, error) {
+		return ConfigsFromFile(path)
+	})
+}
+
+// NewRegistryFromSource is registry constructor reading configurations from the source.
+func NewRegistryFromSource(source ConfigSource, options ...Option) (*Registry, error) {
+	var conf, err = source.Configs()
+	if err != nil {
+		return nil, err
+	}
+
+	return NewRegistry(conf, options...)
+}
+
+// ConfigsFromFile reads and validates configurations from the JSON or YAML file, files of the .toml
+// extension are read as TOML.
+func ConfigsFromFile(path string) (_ Configs, err error) {
+	var file *os.File
+	if file, err = os.Open(path); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if closeErr := file.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		return ConfigsFromTOML(file)
+	}
+
+	return ConfigsFromReader(file)
+}
+
+// ConfigsFromReader reads and validates configurations from JSON or YAML. The document is a map
+// of the connection name to the configuration.
+func ConfigsFromReader(r io.Reader) (Configs, error) {
+	var data, err = io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var conf Configs
+	if data = bytes.TrimSpace(data); bytes.HasPrefix(data, []byte("{")) {
+		err = json.Unmarshal(data, &conf)
+	} else {
+		err = yaml.Unmarshal(data, &conf)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("unable decode configs : %w", err)
+	}
+
+	if err = conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	return conf, nil
+}
+
+// ConfigsFromTOML reads and validates configurations from TOML, keys and values are the same as in JSON.
+// The document is a table of the connection name to the configuration.
+func ConfigsFromTOML(r io.Reader) (_ Configs, err error) {
+	var tables map[string]interface{}
+	if err = toml.NewDecoder(r).Decode(&tables); err != nil {
+		return nil, fmt.Errorf("unable decode configs : %w", err)
+	}
+
+	var conf = make(Configs, len(tables))
+	for name, table := range tables {
+		var c Config
+		if err = c.UnmarshalTOML(table); err != nil {
+			return nil, fmt.Errorf("unable decode %s config : %w", name, err)
+		}
+
+		conf[name] = c
+	}
+
+	if err = conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	return conf, nil
+}
+
+// Validate checks every configuration merged with the DefaultsName entry can be opened, the returned
+// error is ValidationError listing all problems found.
+func (c Configs) Validate() error {
+	var (
+		configs, _ = c.withDefaults(nil, nil)
+		e          = make(ValidationError)
+	)
+
+	for name, conf := range configs {
+		if problems := conf.problems(); len(problems) > 0 {
+			e[name] = problems
+		}
+	}
+
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// Error implements the error interface.
+func (e ValidationError) Error() string {
+	var names = make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var parts = make([]string, 0, len(names))
+	for _, name := range names {
+		var problems = make([]string, 0, len(e[name]))
+		for _, err := range e[name] {
+			problems = append(problems, err.Error())
+		}
+
+		parts = append(parts, fmt.Sprintf("invalid %s connection : %s", name, strings.Join(problems, ", ")))
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// problems returns every reason the configuration can not be opened.
+func (c Config) problems() (problems []error) {
+	if len(c.NodeConfigs) == 0 && c.DSNProvider == nil && c.Discovery.Topology == nil {
+		problems = append(problems, errors.New("no nodes"))
+	}
+
+	if err := c.NodeConfigs.validateRoles(); err != nil {
+		problems = append(problems, err)
+	}
+
+	var seen = make(map[string]int, len(c.NodeConfigs))
+	for i, node := range c.NodeConfigs {
+		if node.Weight < 0 {
+			problems = append(problems, fmt.Errorf("negative weight of node %d", i))
+		}
+
+		if node.DSN == "" {
+			problems = append(problems, fmt.Errorf("empty dsn of node %d", i))
+			continue
+		}
+
+		if j, ok := seen[node.DSN]; ok {
+			problems = append(problems, fmt.Errorf("node %d duplicates dsn of node %d", i, j))
+			continue
+		}
+
+		seen[node.DSN] = i
+	}
+
+	for i, weight := range c.ReadWeights {
+		if weight < 0 {
+			problems = append(problems, fmt.Errorf("negative read weight %d", i))
+		}
+	}
+
+	switch {
+	case c.Driver == "":
+		problems = append(problems, errors.New("no driver"))
+	case !c.external() && !isDriverRegistered(c.Driver):
+		problems = append(problems, fmt.Errorf("unknown driver %q", c.Driver))
+	}
+
+	if c.Dialer != nil && !c.external() && c.Driver != "mysql" && c.Driver != "pgx" {
+		problems = append(problems, fmt.Errorf("%w %s", ErrDialerUnsupported, c.Driver))
+	}
+
+	problems = append(problems, c.Discovery.problems(c)...)
+	problems = append(problems, c.RoleProbe.problems(c)...)
+	problems = append(problems, c.poolerProblems()...)
+
+	if c.Driver == DriverClickHouse {
+		problems = append(problems, c.clickhouseProblems()...)
+	}
+
+	if c.StrictOpen && c.ToleratePartialFailure {
+		problems = append(problems, errors.New("strict_open and tolerate_partial_failure are mutually exclusive"))
+	}
+
+	if c.AdaptivePool.MaxOpenConns > 0 && c.AdaptivePool.MinOpenConns > c.AdaptivePool.MaxOpenConns {
+		problems = append(problems, errors.New("adaptive_pool min_open_conns exceeds max_open_conns"))
+	}
+
+	if c.MaxOpenConns < 0 {
+		problems = append(problems, errors.New("negative max_open_conns"))
+	}
+
+	if c.MaxIdleConns < 0 {
+		problems = append(problems, errors.New("negative max_idle_conns"))
+	}
+
+	if c.MasterReadPercent < 0 || c.MasterReadPercent > 100 {
+		problems = append(problems, fmt.Errorf("master_read_percent %d is out of range [0, 100]", c.MasterReadPercent))
+	}
+
+	for i, statement := range c.InitStatements {
+		if strings.TrimSpace(statement) == "" {
+			problems = append(problems, fmt.Errorf("empty init statement %d", i))
+		}
+	}
+
+	if c.Concurrency.Limit < 0 || c.Concurrency.BatchLimit < 0 {
+		problems = append(problems, errors.New("negative concurrency limit"))
+	}
+
+	if c.StmtCacheSize < 0 {
+		problems = append(problems, errors.New("negative stmt_cache_size"))
+	}
+
+	if c.RecentQueries < 0 {
+		problems = append(problems, errors.New("negative recent_queries"))
+	}
+
+	if _, err := parseIsolation(c.TxIsolation); err != nil {
+		problems = append(problems, err)
+	}
+
+	if err := c.ReadPolicy.validate(); err != nil {
+		problems = append(problems, err)
+	}
+
+	return problems
+}
+
+// isDriverRegistered reports whether the database/sql driver is registered.
+func isDriverRegistered(name string) bool {
+	for _, driver := range sql.Drivers() {
+		if driver == name {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,307 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+
+	"github.com/iqoption/nap"
+)
+
+type (
+	// connection is opened registry connection.
+	connection struct {
+		name     string
+		conf     Config
+		db       *nap.DB
+		breakers []*breaker
+		monitor  *monitor
+		lag      *lagMonitor
+		stmts    *stmtCache
+		leaks    *leakTracker
+		cache    *queryCache
+		recent   *queryRing
+		profiles map[string]*connection
+		counter  uint64
+		opened   bool
+		clock    Clock
+		logger   Logger
+
+		mux       sync.Mutex
+		done      chan struct{}
+		closeOnce sync.Once
+	}
+
+	// evictFunc is notified when the slave node is removed from or returned to the read rotation.
+	evictFunc func(idx int, evicted bool, err error)
+)
+
+// newConnection returns connection of n nodes prepared for dialing.
+func newConnection(name string, conf Config, n int, clock Clock, logger Logger) *connection {
+	var c = connection{
+		name:   name,
+		conf:   conf,
+		clock:  clock,
+		logger: logger,
+		done:   make(chan struct{}),
+	}
+
+	c.breakers = newBreakers(conf.CircuitBreaker, n, c.done, c.evictions("circuit breaker"))
+	c.leaks = newLeakTracker(conf.LeakThreshold, clock)
+	c.recent = newQueryRing(conf.RecentQueries)
+
+	// transaction pooling proxies don't keep prepared statements between transactions
+	if !conf.PoolerMode {
+		c.stmts = newStmtCache(conf.StmtCacheSize)
+	}
+
+	return &c
+}
+
+// nodeDSN returns DSN of the node ready to be passed to the driver.
+func (c *connection) nodeDSN(node int, dsn string) (string, error) {
+	return nodeDSN(c.name, node, c.conf, dsn)
+}
+
+// resolver returns function resolving the node DSN via the connection DSN provider.
+func (c *connection) resolver(i int) dsnFunc {
+	if c.conf.DSNProvider == nil {
+		return nil
+	}
+
+	return func(ctx context.Context) (_ string, err error) {
+		var dsn []string
+		if dsn, err = c.conf.DSNProvider(ctx, c.name); err != nil {
+			return "", err
+		}
+
+		if i >= len(dsn) {
+			return "", fmt.Errorf("dsn provider returned %d nodes, node %d is missing", len(dsn), i)
+		}
+
+		return c.nodeDSN(i, dsn[i])
+	}
+}
+
+// interceptors returns the connection interceptor chain based on the registry one.
+func (c *connection) interceptors(chain interceptors, logger SlowQueryLogger) interceptors {
+	chain = chain.with(c.conf)
+
+	if logger == nil {
+		logger = registrySlowQueryLogger{logger: c.logger}
+	}
+
+	if i := newSlowQueryInterceptor(c.conf.SlowQueryThreshold, logger); i != nil {
+		chain = append(chain[:len(chain):len(chain)], i)
+	}
+
+	if c.conf.ReadYourWrites {
+		chain = append(chain[:len(chain):len(chain)], sessionInterceptor{clock: c.clock})
+	}
+
+	if c.cache != nil {
+		chain = append(chain[:len(chain):len(chain)], cacheInterceptor{cache: c.cache})
+	}
+
+	if c.recent != nil {
+		chain = append(chain[:len(chain):len(chain)], c.recent)
+	}
+
+	if i := newPriorityInterceptor(c.conf.Concurrency); i != nil {
+		chain = append(chain[:len(chain):len(chain)], i)
+	}
+
+	for _, b := range c.breakers {
+		if b != nil {
+			return append(chain[:len(chain):len(chain)], &breakerInterceptor{breakers: c.breakers})
+		}
+	}
+
+	return chain
+}
+
+// evictions returns function logging the slave node rotation changes made by the source.
+func (c *connection) evictions(source string) evictFunc {
+	return func(idx int, evicted bool, err error) {
+		if evicted {
+			c.logger.Error("node removed from read rotation", err, "connection", c.name, "node", idx, "by", source)
+			return
+		}
+
+		c.logger.Info("node returned to read rotation", "connection", c.name, "node", idx, "by", source)
+	}
+}
+
+// available reports whether the slave node is in the read rotation.
+func (c *connection) available(idx int) bool {
+	if b := c.breakers[idx]; b != nil && !b.available() {
+		return false
+	}
+
+	if c.monitor != nil && !c.monitor.available(idx) {
+		return false
+	}
+
+	return c.lag == nil || c.lag.available(idx)
+}
+
+// ping pings every node of the connection, the error identifies the first failed node.
+func (c *connection) ping(ctx context.Context) error {
+	for i, node := range c.db.Databases() {
+		if err := node.PingContext(ctx); err != nil {
+			return connectionError(c.name, i, OpPing, err)
+		}
+	}
+
+	return nil
+}
+
+// pingAll pings every node of the connection concurrently, the error is NodesError of every failed node.
+func (c *connection) pingAll(ctx context.Context) error {
+	var (
+		nodes  = c.db.Databases()
+		failed = make([]*ConnectionError, len(nodes))
+		wg     sync.WaitGroup
+	)
+
+	for i, node := range nodes {
+		wg.Add(1)
+		go func(i int, node *sql.DB) {
+			defer wg.Done()
+
+			if err := node.PingContext(ctx); err != nil {
+				failed[i] = &ConnectionError{Name: c.name, Node: i, Op: OpPing, Err: err}
+			}
+		}(i, node)
+	}
+
+	wg.Wait()
+
+	var e NodesError
+	for _, err := range failed {
+		if err != nil {
+			e = append(e, err)
+		}
+	}
+
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// pingMaster pings every node of the connection, only the master node failure is returned as error.
+// The slave nodes failures are returned separately.
+func (c *connection) pingMaster(ctx context.Context) (failed []*ConnectionError, err error) {
+	for i, node := range c.db.Databases() {
+		if err = node.PingContext(ctx); err == nil {
+			continue
+		}
+
+		var e = &ConnectionError{Name: c.name, Node: i, Op: OpPing, Err: err}
+		if i == 0 {
+			return nil, e
+		}
+
+		failed = append(failed, e)
+	}
+
+	return failed, nil
+}
+
+// read returns node for reads of the context, it is the master node when the master is forced or the
+// context session made a write recently.
+func (c *connection) read(ctx context.Context) *sql.DB {
+	if IsMasterForced(ctx) {
+		return c.db.Master()
+	}
+
+	if c.conf.ReadYourWrites {
+		if s := sessionFromContext(ctx); s != nil && s.pinned(c.name, c.conf.ReadYourWritesWindow, c.clock.Now()) {
+			return c.db.Master()
+		}
+	}
+
+	return c.slave()
+}
+
+// nodeIndex returns index of the connection node or -1 if the node does not belong to the connection.
+func (c *connection) nodeIndex(node *sql.DB) int {
+	for i, db := range c.db.Databases() {
+		if db == node {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// nodeDSN returns DSN of the connection node ready to be passed to the driver, ${VAR} placeholders
+// are expanded from the environment, then the pooler mode, TLS and dialer settings are applied.
+func nodeDSN(name string, node int, conf Config, dsn string) (_ string, err error) {
+	if dsn, err = expandEnv(dsn); err != nil {
+		return "", err
+	}
+
+	if conf.PoolerMode {
+		if dsn, err = poolerDSN(conf.Driver, dsn); err != nil {
+			return "", err
+		}
+	}
+
+	if conf.TLS != nil {
+		if dsn, err = conf.TLS.apply(name, conf.Driver, dsn); err != nil {
+			return "", err
+		}
+	}
+
+	if conf.Dialer != nil {
+		return applyDialer(name, node, conf.Driver, dsn, conf.Dialer)
+	}
+
+	return dsn, nil
+}
+
+// close stops the connection background routines and closes the pools. The AfterClose hook is
+// invoked once for the connection that was completely opened.
+func (c *connection) close() (err error) {
+	c.closeOnce.Do(func() {
+		close(c.done)
+
+		if c.stmts != nil {
+			c.stmts.close()
+		}
+
+		c.mux.Lock()
+		for _, p := range c.profiles {
+			_ = p.close()
+		}
+		c.mux.Unlock()
+
+		if c.db != nil {
+			err = c.db.Close()
+		}
+
+		if !c.opened {
+			return
+		}
+
+		if err != nil {
+			c.logger.Error("unable close connection", err, "connection", c.name)
+		} else {
+			c.logger.Info("connection closed", "connection", c.name)
+		}
+
+		if c.conf.AfterClose != nil {
+			c.conf.AfterClose(c.name)
+		}
+	})
+
+	return err
+}
@@ -0,0 +1,198 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+// Package consul provide Consul catalog discovery of the sql registry slave nodes.
+package consul
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gozix/sql/v3"
+)
+
+// DefaultWait is wait time of the Consul blocking query used when the wait is not set.
+const DefaultWait = 5 * time.Minute
+
+type (
+	// Config is Consul discovery configuration.
+	Config struct {
+		// Address is Consul agent address, e.g. http://127.0.0.1:8500.
+		Address string
+
+		// Token is Consul ACL token, optional.
+		Token string
+
+		// Datacenter is datacenter of the service, the agent one by default.
+		Datacenter string
+
+		// Service is name of the replicas service.
+		Service string
+
+		// Tag filters the service instances by tag, optional.
+		Tag string
+
+		// Wait is wait time of the blocking queries made by Run, DefaultWait by default.
+		Wait time.Duration
+
+		// Client is HTTP client, http.DefaultClient by default.
+		Client *http.Client
+	}
+
+	// Discoverer returns endpoints of the passing service instances. While Run is watching the service,
+	// the endpoints are served from the last catalog state.
+	Discoverer struct {
+		conf Config
+
+		mux       sync.RWMutex
+		endpoints []string
+		watching  bool
+	}
+
+	// entry is Consul health service entry.
+	entry struct {
+		Node struct {
+			Address string `json:"Address"`
+		} `json:"Node"`
+		Service struct {
+			Address string `json:"Address"`
+			Port    int    `json:"Port"`
+		} `json:"Service"`
+	}
+)
+
+// Discoverer implements sql.Discoverer.
+var _ sql.Discoverer = (*Discoverer)(nil)
+
+// NewDiscoverer is discoverer constructor.
+func NewDiscoverer(conf Config) *Discoverer {
+	if conf.Client == nil {
+		conf.Client = http.DefaultClient
+	}
+
+	if conf.Wait <= 0 {
+		conf.Wait = DefaultWait
+	}
+
+	return &Discoverer{conf: conf}
+}
+
+// Discover implements sql.Discoverer.
+func (d *Discoverer) Discover(ctx context.Context) ([]string, error) {
+	d.mux.RLock()
+	var cached, watching = append([]string(nil), d.endpoints...), d.watching
+	d.mux.RUnlock()
+
+	if watching {
+		return cached, nil
+	}
+
+	var endpoints, _, err = d.query(ctx, 0)
+
+	return endpoints, err
+}
+
+// Run watches the service by blocking queries until the context is done, instances leaving the catalog
+// or failing health checks disappear from the discovered endpoints.
+func (d *Discoverer) Run(ctx context.Context) error {
+	var endpoints, index, err = d.query(ctx, 0)
+	if err != nil {
+		return err
+	}
+
+	for {
+		d.mux.Lock()
+		d.endpoints, d.watching = endpoints, true
+		d.mux.Unlock()
+
+		var next uint64
+		if endpoints, next, err = d.query(ctx, index); err != nil {
+			d.mux.Lock()
+			d.watching = false
+			d.mux.Unlock()
+
+			if ctx.Err() != nil {
+				return nil
+			}
+
+			return err
+		}
+
+		// the index going backwards means the catalog was reset, the watch starts over
+		if next < index {
+			next = 0
+		}
+
+		index = next
+	}
+}
+
+// query returns endpoints of the passing instances, the query blocks until the catalog index exceeds
+// the index when it is not zero.
+func (d *Discoverer) query(ctx context.Context, index uint64) (_ []string, _ uint64, err error) {
+	var params = url.Values{"passing": {"true"}}
+	if len(d.conf.Datacenter) > 0 {
+		params.Set("dc", d.conf.Datacenter)
+	}
+
+	if len(d.conf.Tag) > 0 {
+		params.Set("tag", d.conf.Tag)
+	}
+
+	if index > 0 {
+		params.Set("index", strconv.FormatUint(index, 10))
+		params.Set("wait", d.conf.Wait.String())
+	}
+
+	var req *http.Request
+	req, err = http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(
+		"%s/v1/health/service/%s?%s", strings.TrimRight(d.conf.Address, "/"), url.PathEscape(d.conf.Service), params.Encode(),
+	), nil)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if len(d.conf.Token) > 0 {
+		req.Header.Set("X-Consul-Token", d.conf.Token)
+	}
+
+	var resp *http.Response
+	if resp, err = d.conf.Client.Do(req); err != nil {
+		return nil, 0, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, 0, fmt.Errorf("consul responded %d", resp.StatusCode)
+	}
+
+	var entries []entry
+	if err = json.NewDecoder(resp.Body).Decode(&entries); err != nil {
+		return nil, 0, fmt.Errorf("unable decode consul response : %w", err)
+	}
+
+	var endpoints = make([]string, 0, len(entries))
+	for _, e := range entries {
+		var host = e.Service.Address
+		if len(host) == 0 {
+			host = e.Node.Address
+		}
+
+		endpoints = append(endpoints, net.JoinHostPort(host, strconv.Itoa(e.Service.Port)))
+	}
+
+	index, _ = strconv.ParseUint(resp.Header.Get("X-Consul-Index"), 10, 64)
+
+	return endpoints, index, nil
+}
@@ -0,0 +1,184 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+type (
+	// CopySource is source of the rows copied by Registry.CopyFrom, pgx.CopyFromSource implementations,
+	// e.g. pgx.CopyFromRows, satisfy it.
+	CopySource interface {
+		Next() bool
+		Values() ([]interface{}, error)
+		Err() error
+	}
+
+	// copyRows is source of the rows slice.
+	copyRows struct {
+		rows [][]interface{}
+		idx  int
+	}
+)
+
+// ErrCopyUnsupported is error triggered when COPY is requested on a connection of a driver other than Postgres.
+var ErrCopyUnsupported = errors.New("copy is unsupported by driver")
+
+// CopyFromRows returns source of the rows slice.
+func CopyFromRows(rows [][]interface{}) CopySource {
+	return &copyRows{rows: rows, idx: -1}
+}
+
+// CopyFrom loads the rows into the table of the connection master node by COPY FROM STDIN and returns
+// number of the copied rows. The pgx connections copy by the native protocol on a dedicated pooled
+// connection, bypassing the query hooks, and the lib/pq ones by the CopyIn statement executed by
+// WithTx, which isn't retried. The table may be qualified by schema, the table and the columns are
+// quoted.
+func (r *Registry) CopyFrom(ctx context.Context, name, table string, columns []string, src CopySource) (
+	_ int64, err error,
+) {
+	if len(columns) == 0 {
+		return 0, errors.New("copy columns are required")
+	}
+
+	var conf Config
+	if conf, err = r.Config(name); err != nil {
+		return 0, err
+	}
+
+	var identifier = pgx.Identifier(strings.Split(table, "."))
+
+	switch conf.Driver {
+	case "pgx":
+		return r.copyNative(ctx, name, identifier, columns, src)
+	case "postgres", "cloudsqlpostgres":
+		return r.copyIn(ctx, name, identifier, columns, src)
+	default:
+		return 0, fmt.Errorf("%w %s", ErrCopyUnsupported, conf.Driver)
+	}
+}
+
+// copyNative copies the rows by pgx connection of the master node.
+func (r *Registry) copyNative(ctx context.Context, name string, table pgx.Identifier, columns []string, src CopySource) (
+	copied int64, err error,
+) {
+	if _, ok := TxFromContext(ctx, name); ok {
+		return 0, errors.New("copy is unable to join the ambient transaction of pgx connection")
+	}
+
+	var conn *sql.Conn
+	if conn, err = r.Conn(ctx, name); err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if closeErr := conn.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	err = conn.Raw(func(driverConn interface{}) (err error) {
+		var native, ok = unwrapConn(driverConn).(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("unable copy by %T connection : %w", driverConn, ErrCopyUnsupported)
+		}
+
+		copied, err = native.Conn().CopyFrom(ctx, table, columns, src)
+
+		return err
+	})
+
+	return copied, err
+}
+
+// copyIn copies the rows by lib/pq CopyIn statement within the transaction.
+func (r *Registry) copyIn(ctx context.Context, name string, table pgx.Identifier, columns []string, src CopySource) (
+	copied int64, err error,
+) {
+	var quoted = make([]string, 0, len(columns))
+	for _, column := range columns {
+		quoted = append(quoted, pgx.Identifier{column}.Sanitize())
+	}
+
+	var query = "COPY " + table.Sanitize() + " (" + strings.Join(quoted, ", ") + ") FROM STDIN"
+
+	// the source can't be replayed, so the transaction is not retried
+	ctx = ContextWithRetryPolicy(ctx, name, RetryPolicy{})
+
+	err = r.WithTx(ctx, name, nil, func(ctx context.Context, tx *sql.Tx) (err error) {
+		var stmt *sql.Stmt
+		if stmt, err = tx.PrepareContext(ctx, query); err != nil {
+			return err
+		}
+
+		defer func() {
+			if closeErr := stmt.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+
+		copied = 0
+		for src.Next() {
+			var values []interface{}
+			if values, err = src.Values(); err != nil {
+				return err
+			}
+
+			if _, err = stmt.ExecContext(ctx, values...); err != nil {
+				return err
+			}
+
+			copied++
+		}
+
+		if err = src.Err(); err != nil {
+			return err
+		}
+
+		// exec without arguments flushes the buffered rows
+		_, err = stmt.ExecContext(ctx)
+
+		return err
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return copied, nil
+}
+
+// unwrapConn returns the driver connection wrapped by the registry.
+func unwrapConn(driverConn interface{}) interface{} {
+	if wrapped, ok := driverConn.(*wrappedConn); ok {
+		return wrapped.parent
+	}
+
+	return driverConn
+}
+
+// Next implements CopySource.
+func (c *copyRows) Next() bool {
+	c.idx++
+	return c.idx < len(c.rows)
+}
+
+// Values implements CopySource.
+func (c *copyRows) Values() ([]interface{}, error) {
+	return c.rows[c.idx], nil
+}
+
+// Err implements CopySource.
+func (c *copyRows) Err() error {
+	return nil
+}
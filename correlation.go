@@ -0,0 +1,68 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"unicode/utf8"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+type (
+	// CorrelationIDFunc returns the request correlation ID carried by the context, empty if there is none.
+	CorrelationIDFunc func(ctx context.Context) string
+
+	// correlationInterceptor sets the correlation ID of the statements by the extractor.
+	correlationInterceptor struct {
+		extract CorrelationIDFunc
+	}
+
+	// correlationKey is context key of the correlation ID.
+	correlationKey struct{}
+)
+
+// WithCorrelationID option sets extractor of the correlation ID, e.g. reading the request ID stored by
+// the application HTTP middleware. The ID set by ContextWithCorrelationID is used by default.
+func WithCorrelationID(extract CorrelationIDFunc) Option {
+	return optionFunc(func(r *Registry) {
+		// goes first, so every interceptor of the chain sees the correlation ID
+		r.chain = append(interceptors{&correlationInterceptor{extract: extract}}, r.chain...)
+	})
+}
+
+// ContextWithCorrelationID returns context whose statements are correlated by the ID, it is reported
+// in the slow query logs, the query metrics exemplars and the query comments.
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+// CorrelationIDFromContext returns the correlation ID set by ContextWithCorrelationID.
+func CorrelationIDFromContext(ctx context.Context) string {
+	var id, _ = ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+func (i *correlationInterceptor) before(ctx context.Context, e *QueryEvent) (context.Context, error) {
+	if id := i.extract(ctx); len(id) > 0 {
+		e.CorrelationID = id
+	}
+
+	return ctx, nil
+}
+
+func (i *correlationInterceptor) after(context.Context, *QueryEvent) {}
+
+// exemplar returns the exemplar labels of the correlation ID or nil if the ID is not set or exceeds
+// the exemplar size limit.
+func exemplar(id string) prometheus.Labels {
+	const label = "correlation_id"
+
+	if len(id) == 0 || !utf8.ValidString(id) || utf8.RuneCountInString(label+id) > prometheus.ExemplarMaxRunes {
+		return nil
+	}
+
+	return prometheus.Labels{label: id}
+}
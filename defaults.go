@@ -0,0 +1,126 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"reflect"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultsName is name of the configuration entry that is not a connection, its values are used
+	// for every connection field left zero.
+	DefaultsName = "defaults"
+
+	// DefaultConnMaxLifetime is connection lifetime used by the registry created with WithPoolDefaults
+	// when neither the connection nor the defaults set it, negative value disables the limit.
+	DefaultConnMaxLifetime = time.Hour
+
+	// DefaultConnMaxIdleTime is connection idle time used by the registry created with WithPoolDefaults
+	// when neither the connection nor the defaults set it, negative value disables the limit.
+	DefaultConnMaxIdleTime = 5 * time.Minute
+)
+
+// poolDefaults are the pool settings of WithPoolDefaults applied after every other default.
+var poolDefaults = Config{
+	ConnMaxLifetime: DefaultConnMaxLifetime,
+	ConnMaxIdleTime: DefaultConnMaxIdleTime,
+}
+
+// WithDefaultConfig option sets configuration whose values are used for every connection field left
+// zero, nested structures are merged field by field. Nodes are never inherited, neither are the fields
+// set explicitly by the JSON, YAML or viper configuration, so false and zero values override the
+// defaults there. The DefaultsName configuration entry takes precedence over it.
+func WithDefaultConfig(conf Config) Option {
+	return optionFunc(func(r *Registry) {
+		r.defaults = &conf
+	})
+}
+
+// WithPoolDefaults option limits connection lifetime by DefaultConnMaxLifetime and idle time by
+// DefaultConnMaxIdleTime when neither the connection nor the defaults set them. Without it the zero
+// settings keep the database/sql behaviour, connections are reused forever.
+func WithPoolDefaults() Option {
+	return optionFunc(func(r *Registry) {
+		r.pool = &poolDefaults
+	})
+}
+
+// withDefaults returns copy of the configurations without the DefaultsName entry, every configuration
+// is merged with the entry, the defaults and the pool defaults in that order, its master node is
+// moved first and the pool is constrained to the driver limits. The merged defaults are returned as well.
+func (c Configs) withDefaults(defaults, pool *Config) (Configs, Config) {
+	var shared = c[DefaultsName].withDefaults(defaults).withDefaults(pool)
+	shared.Nodes, shared.NodeConfigs = nil, nil
+
+	var conf = make(Configs, len(c))
+	for name, value := range c {
+		if name != DefaultsName {
+			conf[name] = value.withDefaults(&shared).ordered().constrained()
+		}
+	}
+
+	return conf, shared
+}
+
+// withDefaults returns the configuration whose zero fields not set explicitly are taken from the defaults.
+func (c Config) withDefaults(defaults *Config) Config {
+	if defaults == nil {
+		return c
+	}
+
+	var dsn, nodes = c.Nodes, c.NodeConfigs
+	mergeZero(reflect.ValueOf(&c).Elem(), reflect.ValueOf(defaults).Elem(), c.explicit)
+	c.Nodes, c.NodeConfigs = dsn, nodes
+
+	return c
+}
+
+// mergeZero sets zero fields of the dst structure to the src values, the explicit scalar fields keyed
+// by json name are kept.
+func mergeZero(dst, src reflect.Value, explicit map[string]bool) {
+	for i := 0; i < dst.NumField(); i++ {
+		var field = dst.Field(i)
+		if !field.CanSet() {
+			continue
+		}
+
+		if field.Kind() == reflect.Struct {
+			mergeZero(field, src.Field(i), nil)
+			continue
+		}
+
+		if explicit[jsonName(dst.Type().Field(i))] {
+			continue
+		}
+
+		if field.IsZero() {
+			field.Set(src.Field(i))
+		}
+	}
+}
+
+// explicitFields returns json names of the configuration fields reported set.
+func explicitFields(set func(name string) bool) map[string]bool {
+	var (
+		typ      = reflect.TypeOf(Config{})
+		explicit = make(map[string]bool)
+	)
+
+	for i := 0; i < typ.NumField(); i++ {
+		if name := jsonName(typ.Field(i)); name != "" && name != "-" && set(name) {
+			explicit[name] = true
+		}
+	}
+
+	return explicit
+}
+
+// jsonName returns json name of the structure field.
+func jsonName(field reflect.StructField) string {
+	var name, _, _ = strings.Cut(field.Tag.Get("json"), ",")
+	return name
+}
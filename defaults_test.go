@@ -0,0 +1,51 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRegistryPoolDefaults(t *testing.T) {
+	var configs = Configs{
+		DefaultsName: {ConnMaxIdleTime: time.Minute},
+		"main":       {Driver: "sqlmock", Nodes: []string{"gozix_defaults_master"}},
+		"unlimited":  {Driver: "sqlmock", Nodes: []string{"gozix_defaults_master"}, ConnMaxLifetime: -1},
+	}
+
+	var cases = []struct {
+		name     string
+		options  []Option
+		conn     string
+		lifetime time.Duration
+		idle     time.Duration
+	}{
+		{name: "disabled", conn: "main", idle: time.Minute},
+		{name: "enabled", options: []Option{WithPoolDefaults()}, conn: "main", lifetime: DefaultConnMaxLifetime, idle: time.Minute},
+		{name: "negative kept", options: []Option{WithPoolDefaults()}, conn: "unlimited", lifetime: -1, idle: time.Minute},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var registry, err = NewRegistry(configs, tc.options...)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer registry.Close()
+
+			var conf Config
+			if conf, err = registry.Config(tc.conn); err != nil {
+				t.Fatal(err)
+			}
+
+			if conf.ConnMaxLifetime != tc.lifetime || conf.ConnMaxIdleTime != tc.idle {
+				t.Errorf("lifetime is %s and idle time is %s, want %s and %s",
+					conf.ConnMaxLifetime, conf.ConnMaxIdleTime, tc.lifetime, tc.idle)
+			}
+		})
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+type (
+	// DialFunc establishes the network connection to the database server, e.g. via SSH tunnel or
+	// SOCKS proxy. The signature matches net.Dialer.DialContext.
+	DialFunc func(ctx context.Context, network, addr string) (net.Conn, error)
+
+	// pgxDialConfigs are pgx connection configurations registered for the connections with dialer.
+	pgxDialConfigs struct {
+		mux     sync.Mutex
+		dialers map[string]DialFunc
+		configs map[pgxDialKey]pgxDialConfig
+	}
+
+	// pgxDialKey is key of the connection node pgx configuration.
+	pgxDialKey struct {
+		name string
+		node int
+	}
+
+	// pgxDialConfig is pgx configuration registered for the node DSN.
+	pgxDialConfig struct {
+		dsn     string
+		connStr string
+	}
+)
+
+// ErrDialerUnsupported is error triggered when dialer is configured for a driver the registry can't configure.
+var ErrDialerUnsupported = errors.New("dialer is not supported by driver")
+
+// pgxDialers are pgx configurations of every connection with dialer, a single one per connection node
+// is registered in the pgx driver, so the rotated DSN replaces it, and they dial through the current
+// dialer of the connection.
+var pgxDialers = pgxDialConfigs{
+	dialers: make(map[string]DialFunc),
+	configs: make(map[pgxDialKey]pgxDialConfig),
+}
+
+// applyDialer returns the node DSN making the driver dial through the dialer.
+func applyDialer(name string, node int, driverName, dsn string, dial DialFunc) (string, error) {
+	switch driverName {
+	case "mysql":
+		return applyMySQLDialer(name, dsn, dial)
+	case "pgx":
+		return pgxDialers.register(name, node, dsn, dial)
+	default:
+		return "", fmt.Errorf("%w %s", ErrDialerUnsupported, driverName)
+	}
+}
+
+// applyMySQLDialer registers the dialer in the mysql driver as network named after the connection.
+func applyMySQLDialer(name, dsn string, dial DialFunc) (_ string, err error) {
+	var cfg *mysql.Config
+	if cfg, err = mysql.ParseDSN(dsn); err != nil {
+		return "", err
+	}
+
+	var network = cfg.Net
+	if network == "" {
+		network = "tcp"
+	}
+
+	cfg.Net = "gozix_sql_" + name + "_" + network
+	mysql.RegisterDialContext(cfg.Net, func(ctx context.Context, addr string) (net.Conn, error) {
+		return dial(ctx, network, addr)
+	})
+
+	return cfg.FormatDSN(), nil
+}
+
+// register returns pgx connection string of the node DSN dialing through the connection dialer, the
+// configuration registered for the previous DSN of the node is unregistered.
+func (c *pgxDialConfigs) register(name string, node int, dsn string, dial DialFunc) (string, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	c.dialers[name] = dial
+
+	var key = pgxDialKey{name: name, node: node}
+	var registered, ok = c.configs[key]
+	if ok && registered.dsn == dsn {
+		return registered.connStr, nil
+	}
+
+	var cfg, err = pgx.ParseConfig(dsn)
+	if err != nil {
+		return "", err
+	}
+
+	cfg.LookupFunc = lookupHost
+	cfg.DialFunc = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return c.dialer(name)(ctx, network, addr)
+	}
+
+	var connStr = stdlib.RegisterConnConfig(cfg)
+	if ok {
+		stdlib.UnregisterConnConfig(registered.connStr)
+	}
+
+	c.configs[key] = pgxDialConfig{dsn: dsn, connStr: connStr}
+
+	return connStr, nil
+}
+
+// lookupHost returns the host itself, so it is resolved by the dialer on the far side of a tunnel or proxy.
+func lookupHost(_ context.Context, host string) ([]string, error) {
+	return []string{host}, nil
+}
+
+// dialer returns the current dialer of the connection.
+func (c *pgxDialConfigs) dialer(name string) DialFunc {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	return c.dialers[name]
+}
@@ -0,0 +1,287 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultDiscoveryInterval is interval of the slave nodes discovery refresh used when the interval is not set.
+const DefaultDiscoveryInterval = 30 * time.Second
+
+// ErrNoPrimary is error triggered when the discovered topology has no primary.
+var ErrNoPrimary = errors.New("no primary in discovered topology")
+
+type (
+	// Discovery is configuration of the slave nodes discovered at runtime in addition to the configured
+	// nodes, or of every node when the cluster topology is discovered. The connection is reopened when
+	// the discovered set changes, so the master node follows the primary after a failover.
+	Discovery struct {
+		// SRV is DNS SRV name of the slave nodes, e.g. _postgresql._tcp.replicas.db.svc.cluster.local.
+		SRV string `json:"srv"`
+
+		// DSN is DSN template of the discovered node, {host} and {port} are replaced by the node endpoint.
+		DSN string `json:"dsn"`
+
+		// Interval is refresh interval, DefaultDiscoveryInterval by default.
+		Interval time.Duration `json:"interval"`
+
+		// Discoverer is used instead of the SRV lookup when it is set.
+		Discoverer Discoverer `json:"-"`
+
+		// Topology discovers the primary and the replicas, the connection nodes must not be configured.
+		Topology TopologyDiscoverer `json:"-"`
+	}
+
+	// Discoverer returns host:port endpoints of the slave nodes.
+	Discoverer interface {
+		Discover(ctx context.Context) ([]string, error)
+	}
+
+	// TopologyDiscoverer returns host:port endpoints of the cluster primary and its replicas, e.g. as
+	// reported by a failover manager.
+	TopologyDiscoverer interface {
+		DiscoverTopology(ctx context.Context) (primary string, replicas []string, err error)
+	}
+
+	// SRVDiscoverer discovers the endpoints by DNS SRV lookup.
+	SRVDiscoverer struct {
+		Name     string
+		Resolver *net.Resolver
+	}
+)
+
+// enabled reports whether the discovery is configured.
+func (d Discovery) enabled() bool {
+	return d.Discoverer != nil || d.SRV != "" || d.Topology != nil
+}
+
+// discoverer returns the configured discoverer.
+func (d Discovery) discoverer() Discoverer {
+	if d.Discoverer != nil {
+		return d.Discoverer
+	}
+
+	return SRVDiscoverer{Name: d.SRV}
+}
+
+// discover returns the sorted endpoints of the slave nodes, the endpoint of the primary goes first
+// when the topology is discovered.
+func (d Discovery) discover(ctx context.Context) (_ []string, err error) {
+	if d.Topology != nil {
+		var (
+			primary  string
+			replicas []string
+		)
+
+		if primary, replicas, err = d.Topology.DiscoverTopology(ctx); err != nil {
+			return nil, err
+		}
+
+		if primary == "" {
+			return nil, ErrNoPrimary
+		}
+
+		sort.Strings(replicas)
+
+		return append([]string{primary}, replicas...), nil
+	}
+
+	var endpoints []string
+	if endpoints, err = d.discoverer().Discover(ctx); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(endpoints)
+
+	return endpoints, nil
+}
+
+// nodes returns nodes of the endpoints, the first one is master when the topology is discovered.
+func (d Discovery) nodes(endpoints []string) (_ Nodes, err error) {
+	var nodes = make(Nodes, 0, len(endpoints))
+	for i, endpoint := range endpoints {
+		var host, port string
+		if host, port, err = net.SplitHostPort(endpoint); err != nil {
+			return nil, err
+		}
+
+		var dsn = strings.NewReplacer("{host}", host, "{port}", port).Replace(d.DSN)
+		var role = RoleSlave
+		if i == 0 && d.Topology != nil {
+			role = RoleMaster
+		}
+
+		nodes = append(nodes, Node{DSN: dsn, Role: role})
+	}
+
+	return nodes, nil
+}
+
+// Discover implements Discoverer.
+func (d SRVDiscoverer) Discover(ctx context.Context) (_ []string, err error) {
+	var resolver = d.Resolver
+	if resolver == nil {
+		resolver = net.DefaultResolver
+	}
+
+	var records []*net.SRV
+	if _, records, err = resolver.LookupSRV(ctx, "", "", d.Name); err != nil {
+		return nil, err
+	}
+
+	var endpoints = make([]string, 0, len(records))
+	for _, record := range records {
+		var host = strings.TrimSuffix(record.Target, ".")
+		endpoints = append(endpoints, net.JoinHostPort(host, strconv.Itoa(int(record.Port))))
+	}
+
+	return endpoints, nil
+}
+
+// withDiscovered returns the configuration whose nodes are extended by the discovered ones, the
+// discovered endpoints are returned as well.
+func (c Config) withDiscovered(ctx context.Context) (_ Config, endpoints []string, err error) {
+	if !c.Discovery.enabled() {
+		return c, nil, nil
+	}
+
+	if endpoints, err = c.Discovery.discover(ctx); err != nil {
+		return c, nil, err
+	}
+
+	var discovered Nodes
+	if discovered, err = c.Discovery.nodes(endpoints); err != nil {
+		return c, nil, err
+	}
+
+	var nodes = make(Nodes, 0, len(c.NodeConfigs)+len(discovered))
+	nodes = append(nodes, c.NodeConfigs...)
+	c.NodeConfigs = append(nodes, discovered...)
+
+	return c, endpoints, nil
+}
+
+// problems returns the discovery configuration problems.
+func (d Discovery) problems(conf Config) (problems []error) {
+	if !d.enabled() {
+		return nil
+	}
+
+	if d.DSN == "" {
+		problems = append(problems, errors.New("discovery dsn template is required"))
+	}
+
+	if conf.DSNProvider != nil {
+		problems = append(problems, errors.New("discovery and dsn provider are mutually exclusive"))
+	}
+
+	if d.Topology != nil && (d.Discoverer != nil || d.SRV != "") {
+		problems = append(problems, errors.New("discovery topology excludes discoverer and srv"))
+	}
+
+	if d.Topology != nil && len(conf.NodeConfigs) > 0 {
+		problems = append(problems, errors.New("nodes are discovered by topology and must not be configured"))
+	}
+
+	return problems
+}
+
+// watch refreshes the discovered endpoints of the connection and reconnects it when they change, it
+// stops once the connection is closed or replaced in the registry.
+func (r *Registry) watch(c *connection, endpoints []string) {
+	var interval = c.conf.Discovery.Interval
+	if interval <= 0 {
+		interval = DefaultDiscoveryInterval
+	}
+
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		var ctx, cancel = context.WithTimeout(context.Background(), interval)
+		var current, err = c.conf.Discovery.discover(ctx)
+		cancel()
+
+		if err != nil {
+			r.logger.Error("unable discover nodes", err, "connection", c.name)
+			continue
+		}
+
+		if equalStrings(current, endpoints) {
+			continue
+		}
+
+		r.logger.Info("discovered nodes changed", "connection", c.name, "nodes", len(current))
+		if !r.rediscover(c) {
+			return
+		}
+	}
+}
+
+// rediscover replaces the connection by a fresh one and drains the old one in background. It reports
+// whether the connection is still the registry one, so the refresh is retried on next tick. The fresh
+// connection is opened with the registry unlocked.
+func (r *Registry) rediscover(c *connection) bool {
+	r.mux.Lock()
+	if r.shutdown || r.conns[c.name] != c {
+		r.mux.Unlock()
+		return false
+	}
+
+	var (
+		conf = r.conf[c.name]
+		gen  = r.gens[c.name]
+	)
+
+	r.mux.Unlock()
+
+	var fresh, err = r.openConfig(context.Background(), c.name, conf)
+	if err != nil {
+		r.logger.Error("unable reopen connection with discovered nodes", err, "connection", c.name)
+		return true
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.shutdown || r.conns[c.name] != c || r.gens[c.name] != gen {
+		_ = fresh.close()
+		return !r.shutdown && r.conns[c.name] == c
+	}
+
+	r.conns[c.name] = fresh
+	r.touch(c.name)
+	go r.drain(c)
+
+	return false
+}
+
+// equalStrings reports whether the slices are equal.
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
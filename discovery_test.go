@@ -0,0 +1,213 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// discovererFunc is Discoverer function.
+type discovererFunc func(ctx context.Context) ([]string, error)
+
+// Discover implements Discoverer.
+func (f discovererFunc) Discover(ctx context.Context) ([]string, error) {
+	return f(ctx)
+}
+
+// topology is TopologyDiscoverer of the changeable cluster topology.
+type topology struct {
+	mux      sync.Mutex
+	primary  string
+	replicas []string
+}
+
+// DiscoverTopology implements TopologyDiscoverer.
+func (t *topology) DiscoverTopology(context.Context) (string, []string, error) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	return t.primary, append([]string(nil), t.replicas...), nil
+}
+
+// failover promotes the replica.
+func (t *topology) failover(primary string, replicas ...string) {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	t.primary, t.replicas = primary, replicas
+}
+
+func TestDiscoveryDiscover(t *testing.T) {
+	var errLookup = errors.New("lookup failed")
+
+	var cases = []struct {
+		name      string
+		discovery Discovery
+		want      []string
+		err       error
+	}{
+		{
+			name: "sorted endpoints",
+			discovery: Discovery{Discoverer: discovererFunc(func(context.Context) ([]string, error) {
+				return []string{"c:5432", "a:5432", "b:5432"}, nil
+			})},
+			want: []string{"a:5432", "b:5432", "c:5432"},
+		},
+		{
+			name:      "primary first",
+			discovery: Discovery{Topology: &topology{primary: "c:5432", replicas: []string{"b:5432", "a:5432"}}},
+			want:      []string{"c:5432", "a:5432", "b:5432"},
+		},
+		{name: "no primary", discovery: Discovery{Topology: &topology{replicas: []string{"a:5432"}}}, err: ErrNoPrimary},
+		{
+			name: "lookup error",
+			discovery: Discovery{Discoverer: discovererFunc(func(context.Context) ([]string, error) {
+				return nil, errLookup
+			})},
+			err: errLookup,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got, err = tc.discovery.discover(context.Background())
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("error is %v, want %v", err, tc.err)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("endpoints are %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDiscoveryNodes(t *testing.T) {
+	var cases = []struct {
+		name      string
+		discovery Discovery
+		endpoints []string
+		want      Nodes
+		fail      bool
+	}{
+		{
+			name:      "slaves",
+			discovery: Discovery{SRV: "_postgresql._tcp.db", DSN: "postgres://{host}:{port}/app"},
+			endpoints: []string{"a:5432", "[::1]:5433"},
+			want:      Nodes{{DSN: "postgres://a:5432/app", Role: RoleSlave}, {DSN: "postgres://::1:5433/app", Role: RoleSlave}},
+		},
+		{
+			name:      "topology",
+			discovery: Discovery{Topology: &topology{}, DSN: "postgres://{host}:{port}/app"},
+			endpoints: []string{"b:5432", "a:5432"},
+			want:      Nodes{{DSN: "postgres://b:5432/app", Role: RoleMaster}, {DSN: "postgres://a:5432/app", Role: RoleSlave}},
+		},
+		{name: "invalid endpoint", discovery: Discovery{SRV: "_postgresql._tcp.db"}, endpoints: []string{"a"}, fail: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var got, err = tc.discovery.nodes(tc.endpoints)
+			if (err != nil) != tc.fail {
+				t.Fatalf("error is %v, want failure %t", err, tc.fail)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("nodes are %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDiscoveryProblems(t *testing.T) {
+	var provider DSNProvider = func(context.Context, string) ([]string, error) { return nil, nil }
+
+	var cases = []struct {
+		name string
+		conf Config
+		want int
+	}{
+		{name: "disabled", conf: Config{}},
+		{name: "srv", conf: Config{Discovery: Discovery{SRV: "_pg._tcp.db", DSN: "{host}:{port}"}}},
+		{name: "dsn required", conf: Config{Discovery: Discovery{SRV: "_pg._tcp.db"}}, want: 1},
+		{name: "dsn provider", conf: Config{DSNProvider: provider, Discovery: Discovery{SRV: "_pg._tcp.db", DSN: "{host}"}}, want: 1},
+		{
+			name: "topology with srv and nodes",
+			conf: Config{NodeConfigs: NewNodes("master"), Discovery: Discovery{SRV: "_pg._tcp.db", DSN: "{host}", Topology: &topology{}}},
+			want: 2,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.conf.Discovery.problems(tc.conf); len(got) != tc.want {
+				t.Errorf("problems are %v, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRegistryRediscover(t *testing.T) {
+	for _, dsn := range []string{"gozix_discovery_a", "gozix_discovery_b"} {
+		var db, _, err = sqlmock.NewWithDSN(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer db.Close()
+	}
+
+	var cluster = topology{primary: "gozix_discovery_a:5432", replicas: []string{"gozix_discovery_b:5432"}}
+
+	var registry, err = NewRegistry(Configs{"main": {
+		Driver:    "sqlmock",
+		Discovery: Discovery{Topology: &cluster, DSN: "{host}", Interval: 10 * time.Millisecond},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer registry.Close()
+
+	var old *connection
+	if old, err = registry.connection(context.Background(), "main"); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster.failover("gozix_discovery_b:5432", "gozix_discovery_a:5432")
+
+	var deadline = time.Now().Add(5 * time.Second)
+	for {
+		registry.mux.Lock()
+		var current = registry.conns["main"]
+		registry.mux.Unlock()
+
+		if current != old {
+			if got := current.conf.NodeConfigs.DSNs(); !reflect.DeepEqual(got, []string{"gozix_discovery_b", "gozix_discovery_a"}) {
+				t.Errorf("nodes are %v, want promoted replica first", got)
+			}
+
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("connection is not reopened after failover")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var db, _ = registry.ConnectionWithName("main")
+	if err = db.Master().Ping(); err != nil {
+		t.Errorf("reopened connection is unusable : %v", err)
+	}
+}
@@ -0,0 +1,874 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+)
+
+// Operations reported to the query hooks.
+const (
+	OpBegin    = "begin"
+	OpCommit   = "commit"
+	OpExec     = "exec"
+	OpPrepare  = "prepare"
+	OpQuery    = "query"
+	OpRollback = "rollback"
+)
+
+type (
+	// interceptor is invoked around every operation executed by the wrapped driver.
+	interceptor interface {
+		before(ctx context.Context, e *QueryEvent) (context.Context, error)
+		after(ctx context.Context, e *QueryEvent)
+	}
+
+	// interceptors is an interceptor chain, after is invoked in the reverse order.
+	interceptors []interceptor
+
+	// BeforeQueryFunc is invoked before the operation is passed to the driver, returned error aborts it.
+	BeforeQueryFunc func(ctx context.Context, e *QueryEvent) (context.Context, error)
+
+	// AfterQueryFunc is invoked after the operation is executed by the driver.
+	AfterQueryFunc func(ctx context.Context, e *QueryEvent)
+
+	// hookInterceptor adapts the connection query hooks to the interceptor chain.
+	hookInterceptor struct {
+		beforeQuery []BeforeQueryFunc
+		afterQuery  []AfterQueryFunc
+	}
+
+	// QueryEvent describes an operation passing through the connection driver. The hooks invoked
+	// before the operation may modify Query and Args, Duration and Err are filled after the operation.
+	QueryEvent struct {
+		Connection string
+		Driver     string
+		Node       int
+		Role       string
+		Op         string
+		Query      string
+		Args       []driver.NamedValue
+		Start      time.Time
+		Duration   time.Duration
+		Err        error
+
+		// CorrelationID is the request correlation ID of the context.
+		CorrelationID string
+
+		// RowsAffected is the number of rows affected by the executed statement, -1 if unknown.
+		RowsAffected int64
+
+		// entered is the number of interceptors whose before was invoked.
+		entered int
+	}
+
+	// nodeInfo identifies the node the wrapped driver belongs to and carries its execution settings.
+	nodeInfo struct {
+		connection   string
+		driver       string
+		node         int
+		role         string
+		queryTimeout time.Duration
+		session      []string
+		leaks        *leakTracker
+		cache        *queryCache
+	}
+
+	// wrappedConnector is driver.Connector that wraps produced connections.
+	wrappedConnector struct {
+		info   nodeInfo
+		chain  interceptors
+		parent driver.Connector
+	}
+
+	// wrappedDriver is driver.Driver that wraps produced connections.
+	wrappedDriver struct {
+		info   nodeInfo
+		chain  interceptors
+		parent driver.Driver
+	}
+
+	// dsnConnector is driver.Connector for drivers that do not implement driver.DriverContext.
+	dsnConnector struct {
+		dsn    string
+		driver driver.Driver
+	}
+
+	// dsnFunc returns the node DSN right before a new physical connection is established.
+	dsnFunc func(ctx context.Context) (string, error)
+
+	// dynamicConnector is driver.Connector resolving the DSN on every new physical connection.
+	dynamicConnector struct {
+		resolve dsnFunc
+		driver  driver.Driver
+	}
+
+	// wrappedConn is driver.Conn that reports operations to the interceptor chain.
+	wrappedConn struct {
+		info   nodeInfo
+		chain  interceptors
+		parent driver.Conn
+	}
+
+	// wrappedStmt is driver.Stmt that reports executions to the interceptor chain.
+	wrappedStmt struct {
+		conn   *wrappedConn
+		query  string
+		parent driver.Stmt
+	}
+
+	// wrappedTx is driver.Tx that reports commit and rollback to the interceptor chain.
+	wrappedTx struct {
+		conn    *wrappedConn
+		ctx     context.Context
+		parent  driver.Tx
+		untrack func()
+	}
+
+	// wrappedRows is driver.Rows that releases attached resources on close.
+	wrappedRows struct {
+		driver.Rows
+		onClose func()
+	}
+)
+
+var (
+	_ driver.Connector          = (*wrappedConnector)(nil)
+	_ driver.Driver             = (*wrappedDriver)(nil)
+	_ driver.Conn               = (*wrappedConn)(nil)
+	_ driver.ConnBeginTx        = (*wrappedConn)(nil)
+	_ driver.ConnPrepareContext = (*wrappedConn)(nil)
+	_ driver.ExecerContext      = (*wrappedConn)(nil)
+	_ driver.QueryerContext     = (*wrappedConn)(nil)
+	_ driver.Pinger             = (*wrappedConn)(nil)
+	_ driver.SessionResetter    = (*wrappedConn)(nil)
+	_ driver.Validator          = (*wrappedConn)(nil)
+	_ driver.NamedValueChecker  = (*wrappedConn)(nil)
+	_ driver.StmtExecContext    = (*wrappedStmt)(nil)
+	_ driver.StmtQueryContext   = (*wrappedStmt)(nil)
+	_ driver.NamedValueChecker  = (*wrappedStmt)(nil)
+
+	_ driver.RowsNextResultSet              = (*wrappedRows)(nil)
+	_ driver.RowsColumnTypeScanType         = (*wrappedRows)(nil)
+	_ driver.RowsColumnTypeDatabaseTypeName = (*wrappedRows)(nil)
+	_ driver.RowsColumnTypeLength           = (*wrappedRows)(nil)
+	_ driver.RowsColumnTypeNullable         = (*wrappedRows)(nil)
+	_ driver.RowsColumnTypePrecisionScale   = (*wrappedRows)(nil)
+)
+
+// openNode opens the node pool, the driver is wrapped only when the interceptor chain is not empty,
+// the query timeout, session statements or leak tracking are set. When resolve is not nil, it is used
+// instead of the DSN to get DSN of every physical connection.
+func openNode(info nodeInfo, dsn string, resolve dsnFunc, chain interceptors) (_ *sql.DB, err error) {
+	var wrap = len(chain) > 0 || info.queryTimeout > 0 || len(info.session) > 0 || info.leaks != nil
+	if !wrap && resolve == nil {
+		return sql.Open(info.driver, dsn)
+	}
+
+	var connector driver.Connector
+	if resolve != nil {
+		connector, err = newDynamicConnector(info.driver, resolve)
+	} else {
+		connector, err = newConnector(info.driver, dsn)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if !wrap {
+		return sql.OpenDB(connector), nil
+	}
+
+	return sql.OpenDB(&wrappedConnector{
+		info:   info,
+		chain:  chain,
+		parent: connector,
+	}), nil
+}
+
+// newConnector returns connector of the registered driver.
+func newConnector(driverName, dsn string) (_ driver.Connector, err error) {
+	var drv driver.Driver
+	if drv, err = lookupDriver(driverName); err != nil {
+		return nil, err
+	}
+
+	return driverConnector(drv, dsn)
+}
+
+// newDynamicConnector returns connector of the registered driver resolving the DSN on connect.
+func newDynamicConnector(driverName string, resolve dsnFunc) (_ driver.Connector, err error) {
+	var drv driver.Driver
+	if drv, err = lookupDriver(driverName); err != nil {
+		return nil, err
+	}
+
+	return &dynamicConnector{resolve: resolve, driver: drv}, nil
+}
+
+// lookupDriver returns the registered driver by name.
+func lookupDriver(driverName string) (_ driver.Driver, err error) {
+	var db *sql.DB
+	if db, err = sql.Open(driverName, ""); err != nil {
+		return nil, err
+	}
+
+	var drv = db.Driver()
+	if err = db.Close(); err != nil {
+		return nil, err
+	}
+
+	return drv, nil
+}
+
+// driverConnector returns connector of the driver for the DSN.
+func driverConnector(drv driver.Driver, dsn string) (driver.Connector, error) {
+	if dc, ok := drv.(driver.DriverContext); ok {
+		return dc.OpenConnector(dsn)
+	}
+
+	return &dsnConnector{dsn: dsn, driver: drv}, nil
+}
+
+func (h *hookInterceptor) before(ctx context.Context, e *QueryEvent) (_ context.Context, err error) {
+	for _, fn := range h.beforeQuery {
+		if ctx, err = fn(ctx, e); err != nil {
+			return ctx, err
+		}
+	}
+
+	return ctx, nil
+}
+
+func (h *hookInterceptor) after(ctx context.Context, e *QueryEvent) {
+	for _, fn := range h.afterQuery {
+		fn(ctx, e)
+	}
+}
+
+// with returns the chain extended by the connection query hooks.
+func (c interceptors) with(conf Config) interceptors {
+	if len(conf.BeforeQuery) == 0 && len(conf.AfterQuery) == 0 {
+		return c
+	}
+
+	var chain = make(interceptors, 0, len(c)+1)
+	chain = append(chain, c...)
+
+	return append(chain, &hookInterceptor{
+		beforeQuery: conf.BeforeQuery,
+		afterQuery:  conf.AfterQuery,
+	})
+}
+
+// affected sets the rows affected of the event by the statement result.
+func (e *QueryEvent) affected(result driver.Result) {
+	if result == nil {
+		return
+	}
+
+	if n, err := result.RowsAffected(); err == nil {
+		e.RowsAffected = n
+	}
+}
+
+// before invokes before of every interceptor, the chain is stopped on the first error.
+func (c interceptors) before(ctx context.Context, e *QueryEvent) (_ context.Context, err error) {
+	e.Start = time.Now()
+	e.CorrelationID = CorrelationIDFromContext(ctx)
+
+	for _, i := range c {
+		e.entered++
+
+		if ctx, err = i.before(ctx, e); err != nil {
+			return ctx, err
+		}
+	}
+
+	return ctx, nil
+}
+
+// after invokes after of every entered interceptor in the reverse order.
+func (c interceptors) after(ctx context.Context, e *QueryEvent, err error) {
+	e.Duration = time.Since(e.Start)
+	e.Err = err
+
+	for i := e.entered - 1; i >= 0; i-- {
+		c[i].after(ctx, e)
+	}
+}
+
+// Connect implements driver.Connector.
+func (c *wrappedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	var conn, err = c.parent.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWrappedConn(ctx, c.info, c.chain, conn)
+}
+
+// Driver implements driver.Connector.
+func (c *wrappedConnector) Driver() driver.Driver {
+	return &wrappedDriver{info: c.info, chain: c.chain, parent: c.parent.Driver()}
+}
+
+// Open implements driver.Driver.
+func (d *wrappedDriver) Open(name string) (driver.Conn, error) {
+	var conn, err = d.parent.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return newWrappedConn(context.Background(), d.info, d.chain, conn)
+}
+
+// newWrappedConn wraps the physical connection and executes the node session statements on it,
+// the connection is closed when any of them fails.
+func newWrappedConn(ctx context.Context, info nodeInfo, chain interceptors, conn driver.Conn) (driver.Conn, error) {
+	var wrapped = wrappedConn{info: info, chain: chain, parent: conn}
+	for _, query := range info.session {
+		if _, err := wrapped.exec(ctx, query, nil); err != nil {
+			_ = conn.Close()
+			return nil, fmt.Errorf("unable execute session statement %q : %w", query, err)
+		}
+	}
+
+	return &wrapped, nil
+}
+
+// Connect implements driver.Connector.
+func (c *dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+// Driver implements driver.Connector.
+func (c *dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Connect implements driver.Connector.
+func (c *dynamicConnector) Connect(ctx context.Context) (_ driver.Conn, err error) {
+	var dsn string
+	if dsn, err = c.resolve(ctx); err != nil {
+		return nil, err
+	}
+
+	var connector driver.Connector
+	if connector, err = driverConnector(c.driver, dsn); err != nil {
+		return nil, err
+	}
+
+	return connector.Connect(ctx)
+}
+
+// Driver implements driver.Connector.
+func (c *dynamicConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+// Prepare implements driver.Conn.
+func (c *wrappedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+// Close implements driver.Conn.
+func (c *wrappedConn) Close() error {
+	return c.parent.Close()
+}
+
+// Begin implements driver.Conn.
+func (c *wrappedConn) Begin() (driver.Tx, error) {
+	return c.BeginTx(context.Background(), driver.TxOptions{})
+}
+
+// BeginTx implements driver.ConnBeginTx.
+func (c *wrappedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (_ driver.Tx, err error) {
+	var (
+		e     = c.event(OpBegin, "", nil)
+		txCtx = ctx
+	)
+
+	if ctx, err = c.chain.before(ctx, e); err != nil {
+		c.chain.after(ctx, e, err)
+		return nil, err
+	}
+
+	var tx driver.Tx
+	if tx, err = c.begin(ctx, opts); err != nil {
+		c.chain.after(ctx, e, err)
+		return nil, err
+	}
+
+	c.chain.after(ctx, e, nil)
+
+	return &wrappedTx{conn: c, ctx: txCtx, parent: tx, untrack: c.info.leaks.track(e)}, nil
+}
+
+// PrepareContext implements driver.ConnPrepareContext.
+func (c *wrappedConn) PrepareContext(ctx context.Context, query string) (_ driver.Stmt, err error) {
+	var e = c.event(OpPrepare, query, nil)
+	if ctx, err = c.chain.before(ctx, e); err != nil {
+		c.chain.after(ctx, e, err)
+		return nil, err
+	}
+
+	var stmt driver.Stmt
+	if stmt, err = c.prepare(ctx, e.Query); err != nil {
+		c.chain.after(ctx, e, err)
+		return nil, err
+	}
+
+	c.chain.after(ctx, e, nil)
+
+	return &wrappedStmt{conn: c, query: e.Query, parent: stmt}, nil
+}
+
+// ExecContext implements driver.ExecerContext.
+func (c *wrappedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (_ driver.Result, err error) {
+	var cancel context.CancelFunc
+	if ctx, cancel = c.withTimeout(ctx); cancel != nil {
+		defer cancel()
+	}
+
+	var e = c.event(OpExec, query, args)
+	if ctx, err = c.chain.before(ctx, e); err != nil {
+		c.chain.after(ctx, e, err)
+		return nil, err
+	}
+
+	var result driver.Result
+	result, err = c.exec(ctx, e.Query, e.Args)
+	e.affected(result)
+	c.chain.after(ctx, e, err)
+
+	return result, err
+}
+
+// QueryContext implements driver.QueryerContext.
+func (c *wrappedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (_ driver.Rows, err error) {
+	var key, cached = c.info.cache.lookup(ctx, query, args)
+	if cached != nil {
+		return cached, nil
+	}
+
+	var cancel context.CancelFunc
+	ctx, cancel = c.withTimeout(ctx)
+
+	var e = c.event(OpQuery, query, args)
+	if ctx, err = c.chain.before(ctx, e); err != nil {
+		c.chain.after(ctx, e, err)
+		return cancelRows(nil, err, cancel)
+	}
+
+	var rows driver.Rows
+	rows, err = c.query(ctx, e.Query, e.Args)
+	c.chain.after(ctx, e, err)
+
+	if rows, err = cancelRows(rows, err, cancel); err != nil {
+		return nil, err
+	}
+
+	rows, err = cancelRows(rows, nil, c.info.leaks.track(e))
+
+	return c.info.cache.record(ctx, key, rows, err)
+}
+
+// Ping implements driver.Pinger.
+func (c *wrappedConn) Ping(ctx context.Context) error {
+	if pinger, ok := c.parent.(driver.Pinger); ok {
+		return pinger.Ping(ctx)
+	}
+
+	return nil
+}
+
+// ResetSession implements driver.SessionResetter.
+func (c *wrappedConn) ResetSession(ctx context.Context) error {
+	if resetter, ok := c.parent.(driver.SessionResetter); ok {
+		return resetter.ResetSession(ctx)
+	}
+
+	return nil
+}
+
+// IsValid implements driver.Validator.
+func (c *wrappedConn) IsValid() bool {
+	if validator, ok := c.parent.(driver.Validator); ok {
+		return validator.IsValid()
+	}
+
+	return true
+}
+
+// CheckNamedValue implements driver.NamedValueChecker.
+func (c *wrappedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := c.parent.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+
+	return driver.ErrSkip
+}
+
+// withTimeout returns context limited by the query timeout if the caller did not set a deadline,
+// the returned cancel is nil when the context is left untouched.
+func (c *wrappedConn) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.info.queryTimeout <= 0 {
+		return ctx, nil
+	}
+
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, nil
+	}
+
+	return context.WithTimeout(ctx, c.info.queryTimeout)
+}
+
+func (c *wrappedConn) event(op, query string, args []driver.NamedValue) *QueryEvent {
+	return &QueryEvent{
+		Connection: c.info.connection,
+		Driver:     c.info.driver,
+		Node:       c.info.node,
+		Role:       c.info.role,
+		Op:         op,
+		Query:      query,
+		Args:       args,
+
+		RowsAffected: -1,
+	}
+}
+
+func (c *wrappedConn) begin(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if beginner, ok := c.parent.(driver.ConnBeginTx); ok {
+		return beginner.BeginTx(ctx, opts)
+	}
+
+	if opts.Isolation != driver.IsolationLevel(sql.LevelDefault) {
+		return nil, errors.New("sql: driver does not support non-default isolation level")
+	}
+
+	if opts.ReadOnly {
+		return nil, errors.New("sql: driver does not support read-only transactions")
+	}
+
+	return c.parent.Begin() //nolint:staticcheck
+}
+
+func (c *wrappedConn) prepare(ctx context.Context, query string) (driver.Stmt, error) {
+	if preparer, ok := c.parent.(driver.ConnPrepareContext); ok {
+		return preparer.PrepareContext(ctx, query)
+	}
+
+	return c.parent.Prepare(query)
+}
+
+// exec executes the query on the parent connection, it falls back to a prepared statement
+// itself instead of returning driver.ErrSkip, so the interceptors see a single operation.
+func (c *wrappedConn) exec(ctx context.Context, query string, args []driver.NamedValue) (_ driver.Result, err error) {
+	if execer, ok := c.parent.(driver.ExecerContext); ok {
+		var result driver.Result
+		if result, err = execer.ExecContext(ctx, query, args); !errors.Is(err, driver.ErrSkip) {
+			return result, err
+		}
+	}
+
+	var stmt driver.Stmt
+	if stmt, err = c.prepare(ctx, query); err != nil {
+		return nil, err
+	}
+
+	defer stmt.Close()
+
+	return stmtExec(ctx, stmt, args)
+}
+
+// query executes the query on the parent connection, it falls back to a prepared statement
+// itself instead of returning driver.ErrSkip, so the interceptors see a single operation.
+func (c *wrappedConn) query(ctx context.Context, query string, args []driver.NamedValue) (_ driver.Rows, err error) {
+	if queryer, ok := c.parent.(driver.QueryerContext); ok {
+		var rows driver.Rows
+		if rows, err = queryer.QueryContext(ctx, query, args); !errors.Is(err, driver.ErrSkip) {
+			return rows, err
+		}
+	}
+
+	var stmt driver.Stmt
+	if stmt, err = c.prepare(ctx, query); err != nil {
+		return nil, err
+	}
+
+	var rows driver.Rows
+	if rows, err = stmtQuery(ctx, stmt, args); err != nil {
+		_ = stmt.Close()
+		return nil, err
+	}
+
+	return &wrappedRows{Rows: rows, onClose: func() { _ = stmt.Close() }}, nil
+}
+
+// Close implements driver.Stmt.
+func (s *wrappedStmt) Close() error {
+	return s.parent.Close()
+}
+
+// NumInput implements driver.Stmt.
+func (s *wrappedStmt) NumInput() int {
+	return s.parent.NumInput()
+}
+
+// Exec implements driver.Stmt.
+func (s *wrappedStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return s.ExecContext(context.Background(), valuesToNamed(args))
+}
+
+// Query implements driver.Stmt.
+func (s *wrappedStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return s.QueryContext(context.Background(), valuesToNamed(args))
+}
+
+// ExecContext implements driver.StmtExecContext.
+func (s *wrappedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (_ driver.Result, err error) {
+	var cancel context.CancelFunc
+	if ctx, cancel = s.conn.withTimeout(ctx); cancel != nil {
+		defer cancel()
+	}
+
+	var e = s.conn.event(OpExec, s.query, args)
+	if ctx, err = s.conn.chain.before(ctx, e); err != nil {
+		s.conn.chain.after(ctx, e, err)
+		return nil, err
+	}
+
+	var result driver.Result
+	result, err = stmtExec(ctx, s.parent, e.Args)
+	e.affected(result)
+	s.conn.chain.after(ctx, e, err)
+
+	return result, err
+}
+
+// QueryContext implements driver.StmtQueryContext.
+func (s *wrappedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (_ driver.Rows, err error) {
+	var key, cached = s.conn.info.cache.lookup(ctx, s.query, args)
+	if cached != nil {
+		return cached, nil
+	}
+
+	var cancel context.CancelFunc
+	ctx, cancel = s.conn.withTimeout(ctx)
+
+	var e = s.conn.event(OpQuery, s.query, args)
+	if ctx, err = s.conn.chain.before(ctx, e); err != nil {
+		s.conn.chain.after(ctx, e, err)
+		return cancelRows(nil, err, cancel)
+	}
+
+	var rows driver.Rows
+	rows, err = stmtQuery(ctx, s.parent, e.Args)
+	s.conn.chain.after(ctx, e, err)
+
+	if rows, err = cancelRows(rows, err, cancel); err != nil {
+		return nil, err
+	}
+
+	rows, err = cancelRows(rows, nil, s.conn.info.leaks.track(e))
+
+	return s.conn.info.cache.record(ctx, key, rows, err)
+}
+
+// CheckNamedValue implements driver.NamedValueChecker.
+func (s *wrappedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if checker, ok := s.parent.(driver.NamedValueChecker); ok {
+		return checker.CheckNamedValue(nv)
+	}
+
+	return s.conn.CheckNamedValue(nv)
+}
+
+// Commit implements driver.Tx.
+func (t *wrappedTx) Commit() error {
+	return t.finish(OpCommit, t.parent.Commit)
+}
+
+// Rollback implements driver.Tx.
+func (t *wrappedTx) Rollback() error {
+	return t.finish(OpRollback, t.parent.Rollback)
+}
+
+func (t *wrappedTx) finish(op string, fn func() error) (err error) {
+	if t.untrack != nil {
+		defer t.untrack()
+	}
+
+	var (
+		e   = t.conn.event(op, "", nil)
+		ctx context.Context
+	)
+
+	if ctx, err = t.conn.chain.before(t.ctx, e); err != nil {
+		t.conn.chain.after(ctx, e, err)
+		return err
+	}
+
+	err = fn()
+	t.conn.chain.after(ctx, e, err)
+
+	return err
+}
+
+// Close implements driver.Rows.
+func (r *wrappedRows) Close() error {
+	var err = r.Rows.Close()
+	if r.onClose != nil {
+		r.onClose()
+		r.onClose = nil
+	}
+
+	return err
+}
+
+// HasNextResultSet implements driver.RowsNextResultSet.
+func (r *wrappedRows) HasNextResultSet() bool {
+	if rows, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return rows.HasNextResultSet()
+	}
+
+	return false
+}
+
+// NextResultSet implements driver.RowsNextResultSet.
+func (r *wrappedRows) NextResultSet() error {
+	if rows, ok := r.Rows.(driver.RowsNextResultSet); ok {
+		return rows.NextResultSet()
+	}
+
+	return io.EOF
+}
+
+// ColumnTypeScanType implements driver.RowsColumnTypeScanType.
+func (r *wrappedRows) ColumnTypeScanType(index int) reflect.Type {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeScanType); ok {
+		return rows.ColumnTypeScanType(index)
+	}
+
+	return reflect.TypeOf(new(interface{})).Elem()
+}
+
+// ColumnTypeDatabaseTypeName implements driver.RowsColumnTypeDatabaseTypeName.
+func (r *wrappedRows) ColumnTypeDatabaseTypeName(index int) string {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeDatabaseTypeName); ok {
+		return rows.ColumnTypeDatabaseTypeName(index)
+	}
+
+	return ""
+}
+
+// ColumnTypeLength implements driver.RowsColumnTypeLength.
+func (r *wrappedRows) ColumnTypeLength(index int) (int64, bool) {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeLength); ok {
+		return rows.ColumnTypeLength(index)
+	}
+
+	return 0, false
+}
+
+// ColumnTypeNullable implements driver.RowsColumnTypeNullable.
+func (r *wrappedRows) ColumnTypeNullable(index int) (bool, bool) {
+	if rows, ok := r.Rows.(driver.RowsColumnTypeNullable); ok {
+		return rows.ColumnTypeNullable(index)
+	}
+
+	return false, false
+}
+
+// ColumnTypePrecisionScale implements driver.RowsColumnTypePrecisionScale.
+func (r *wrappedRows) ColumnTypePrecisionScale(index int) (int64, int64, bool) {
+	if rows, ok := r.Rows.(driver.RowsColumnTypePrecisionScale); ok {
+		return rows.ColumnTypePrecisionScale(index)
+	}
+
+	return 0, 0, false
+}
+
+// cancelRows releases the query context with the rows, the context is released right away when the
+// query failed. Rows are returned as is when cancel is nil.
+func cancelRows(rows driver.Rows, err error, cancel context.CancelFunc) (driver.Rows, error) {
+	if cancel == nil {
+		return rows, err
+	}
+
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	if wrapped, ok := rows.(*wrappedRows); ok {
+		var onClose = wrapped.onClose
+		wrapped.onClose = func() {
+			onClose()
+			cancel()
+		}
+
+		return wrapped, nil
+	}
+
+	return &wrappedRows{Rows: rows, onClose: cancel}, nil
+}
+
+func stmtExec(ctx context.Context, stmt driver.Stmt, args []driver.NamedValue) (driver.Result, error) {
+	if execer, ok := stmt.(driver.StmtExecContext); ok {
+		return execer.ExecContext(ctx, args)
+	}
+
+	var values, err = namedToValues(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt.Exec(values) //nolint:staticcheck
+}
+
+func stmtQuery(ctx context.Context, stmt driver.Stmt, args []driver.NamedValue) (driver.Rows, error) {
+	if queryer, ok := stmt.(driver.StmtQueryContext); ok {
+		return queryer.QueryContext(ctx, args)
+	}
+
+	var values, err = namedToValues(args)
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt.Query(values) //nolint:staticcheck
+}
+
+func valuesToNamed(args []driver.Value) []driver.NamedValue {
+	var named = make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		named[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+
+	return named
+}
+
+func namedToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	var values = make([]driver.Value, len(args))
+	for i, arg := range args {
+		if len(arg.Name) > 0 {
+			return nil, errors.New("sql: driver does not support the use of Named Parameters")
+		}
+
+		values[i] = arg.Value
+	}
+
+	return values, nil
+}
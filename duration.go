@@ -0,0 +1,159 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Duration is time.Duration unmarshalled either from a human string like "5m" or from a number
+// of nanoseconds.
+type Duration time.Duration
+
+// Duration implements encoding.TextUnmarshaler.
+var _ encoding.TextUnmarshaler = (*Duration)(nil)
+
+var (
+	// durationType is type of the configuration fields decoded as Duration.
+	durationType = reflect.TypeOf(time.Duration(0))
+
+	// unmarshalerType is type of the json.Unmarshaler interface.
+	unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+)
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (d *Duration) UnmarshalText(text []byte) error {
+	var value, err = time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(value)
+
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte(`"`)) {
+		var text string
+		if err := json.Unmarshal(data, &text); err != nil {
+			return err
+		}
+
+		return d.UnmarshalText([]byte(text))
+	}
+
+	var value int64
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("invalid duration %s : %w", data, err)
+	}
+
+	*d = Duration(value)
+
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, durations may be human strings like "5m".
+func (c *Config) UnmarshalJSON(data []byte) error {
+	var fields, err = unmarshalFields(data, c)
+	if err != nil {
+		return err
+	}
+
+	c.explicit = explicitFields(func(name string) bool {
+		var _, ok = fields[name]
+		return ok
+	})
+
+	return nil
+}
+
+// UnmarshalYAML implements yaml.Unmarshaler, keys and values are the same as in JSON.
+func (c *Config) UnmarshalYAML(value *yaml.Node) error {
+	var raw interface{}
+	if err := value.Decode(&raw); err != nil {
+		return err
+	}
+
+	return unmarshalAsJSON(raw, c)
+}
+
+// UnmarshalTOML implements the Unmarshaler interface of the TOML decoders passing the decoded table,
+// keys and values are the same as in JSON.
+func (c *Config) UnmarshalTOML(value interface{}) error {
+	return unmarshalAsJSON(value, c)
+}
+
+// unmarshalAsJSON decodes the value produced by another format decoder reusing JSON unmarshalling.
+func unmarshalAsJSON(raw interface{}, value json.Unmarshaler) error {
+	var data, err = json.Marshal(raw)
+	if err != nil {
+		return err
+	}
+
+	return value.UnmarshalJSON(data)
+}
+
+// unmarshalFields decodes the JSON object into the structure fields matched by json name. The
+// time.Duration fields are decoded as Duration and the nested structures the same way, so durations
+// may be human strings like "5m" at any depth. Fields of other types are decoded by encoding/json.
+// The object members are returned as well.
+func unmarshalFields(data []byte, value interface{}) (fields map[string]json.RawMessage, err error) {
+	if err = json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	var elem = reflect.ValueOf(value).Elem()
+	for i := 0; i < elem.NumField(); i++ {
+		var name = jsonName(elem.Type().Field(i))
+		if name == "" || name == "-" || !elem.Field(i).CanSet() {
+			continue
+		}
+
+		if raw, ok := fields[name]; ok {
+			if err = unmarshalField(raw, elem.Field(i)); err != nil {
+				return nil, fmt.Errorf("invalid %s : %w", name, err)
+			}
+		}
+	}
+
+	return fields, nil
+}
+
+// unmarshalField decodes the JSON value into the structure field.
+func unmarshalField(data json.RawMessage, field reflect.Value) error {
+	var (
+		typ  = field.Type()
+		null = bytes.Equal(bytes.TrimSpace(data), []byte("null"))
+	)
+
+	switch {
+	case typ == durationType:
+		return (*Duration)(field.Addr().Interface().(*time.Duration)).UnmarshalJSON(data)
+	case null || reflect.PtrTo(typ).Implements(unmarshalerType):
+		return json.Unmarshal(data, field.Addr().Interface())
+	case typ.Kind() == reflect.Struct:
+		var _, err = unmarshalFields(data, field.Addr().Interface())
+		return err
+	case typ.Kind() == reflect.Ptr && typ.Elem().Kind() == reflect.Struct && !typ.Implements(unmarshalerType):
+		if field.IsNil() {
+			field.Set(reflect.New(typ.Elem()))
+		}
+
+		var _, err = unmarshalFields(data, field.Interface())
+		return err
+	default:
+		return json.Unmarshal(data, field.Addr().Interface())
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigsDurations(t *testing.T) {
+	var cases = []struct {
+		name   string
+		decode func(doc string) (Configs, error)
+		doc    string
+	}{
+		{
+			name:   "json",
+			decode: func(doc string) (Configs, error) { return ConfigsFromReader(strings.NewReader(doc)) },
+			doc: `{"main": {
+				"driver": "sqlmock",
+				"nodes": ["master"],
+				"conn_max_lifetime": "10m",
+				"query_timeout": 2000000000,
+				"tx_retry": {"max_delay": "1s"},
+				"health_monitor": {"interval": "30s"},
+				"tls": {"server_name": "db"},
+				"profiles": {"batch": {"conn_max_idle_time": "1m"}},
+				"node_configs": ["master", {"dsn": "slave", "conn_max_lifetime": "1h"}]
+			}}`,
+		},
+		{
+			name:   "yaml",
+			decode: func(doc string) (Configs, error) { return ConfigsFromReader(strings.NewReader(doc)) },
+			doc: `
+main:
+  driver: sqlmock
+  nodes: [master]
+  conn_max_lifetime: 10m
+  query_timeout: 2000000000
+  tx_retry:
+    max_delay: 1s
+  health_monitor:
+    interval: 30s
+  tls:
+    server_name: db
+  profiles:
+    batch:
+      conn_max_idle_time: 1m
+  node_configs:
+    - master
+    - dsn: slave
+      conn_max_lifetime: 1h
+`,
+		},
+		{
+			name:   "toml",
+			decode: func(doc string) (Configs, error) { return ConfigsFromTOML(strings.NewReader(doc)) },
+			doc: `
+[main]
+driver = "sqlmock"
+nodes = ["master"]
+conn_max_lifetime = "10m"
+query_timeout = 2000000000
+node_configs = ["master", {dsn = "slave", conn_max_lifetime = "1h"}]
+
+[main.tx_retry]
+max_delay = "1s"
+
+[main.health_monitor]
+interval = "30s"
+
+[main.tls]
+server_name = "db"
+
+[main.profiles.batch]
+conn_max_idle_time = "1m"
+`,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var conf, err = tc.decode(tc.doc)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var c = conf["main"]
+			for _, d := range []struct {
+				name      string
+				got, want time.Duration
+			}{
+				{name: "conn_max_lifetime", got: c.ConnMaxLifetime, want: 10 * time.Minute},
+				{name: "query_timeout", got: c.QueryTimeout, want: 2 * time.Second},
+				{name: "tx_retry.max_delay", got: c.TxRetry.MaxDelay, want: time.Second},
+				{name: "health_monitor.interval", got: c.HealthMonitor.Interval, want: 30 * time.Second},
+				{name: "profiles.batch.conn_max_idle_time", got: c.Profiles["batch"].ConnMaxIdleTime, want: time.Minute},
+				{name: "node_configs.1.conn_max_lifetime", got: c.NodeConfigs[1].ConnMaxLifetime, want: time.Hour},
+			} {
+				if d.got != d.want {
+					t.Errorf("%s is %s, want %s", d.name, d.got, d.want)
+				}
+			}
+
+			if c.TLS == nil || c.TLS.ServerName != "db" {
+				t.Errorf("tls is %+v, want server name db", c.TLS)
+			}
+
+			if len(c.Nodes) != 1 || c.NodeConfigs.DSNs()[0] != "master" {
+				t.Errorf("nodes are %v and %v, want master first", c.Nodes, c.NodeConfigs)
+			}
+
+			if !c.explicit["conn_max_lifetime"] || c.explicit["conn_max_idle_time"] {
+				t.Errorf("explicit fields are %v, want the document ones", c.explicit)
+			}
+		})
+	}
+}
+
+func TestConfigInvalidDuration(t *testing.T) {
+	var _, err = ConfigsFromReader(strings.NewReader(`{"main": {"nodes": ["master"], "tx_retry": {"max_delay": "soon"}}}`))
+	if err == nil || !strings.Contains(err.Error(), "invalid tx_retry : invalid max_delay") {
+		t.Errorf("error is %v, want invalid max_delay", err)
+	}
+}
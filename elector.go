@@ -0,0 +1,218 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Default intervals of the leader election used when they are not set.
+const (
+	DefaultElectionRetryInterval = 5 * time.Second
+	DefaultElectionRenewInterval = 5 * time.Second
+)
+
+type (
+	// Election is configuration of the leader election.
+	Election struct {
+		// Key is advisory lock key shared by the candidates.
+		Key string
+
+		// RetryInterval is interval of the lock attempts of the campaign, DefaultElectionRetryInterval
+		// by default.
+		RetryInterval time.Duration
+
+		// RenewInterval is interval of the leader lease renewal, the check of the lock connection,
+		// DefaultElectionRenewInterval by default. It bounds the time the lost leadership stays
+		// unnoticed, so the singleton jobs should stop being done as soon as it is lost.
+		RenewInterval time.Duration
+	}
+
+	// Elector elects single leader of the candidates sharing the election key by the advisory lock
+	// of the connection master node, see Registry.AdvisoryLock. The leader holds the lock until it
+	// resigns, its connection breaks or the registry is closed.
+	Elector struct {
+		registry *Registry
+		name     string
+		conf     Election
+
+		mux     sync.Mutex
+		lock    *advisoryLock
+		lost    chan struct{}
+		stop    chan struct{}
+		stopped chan struct{}
+	}
+)
+
+// Elector returns elector of the connection, the election key is required.
+func (r *Registry) Elector(name string, election Election) (_ *Elector, err error) {
+	if election.Key == "" {
+		return nil, errors.New("election key is required")
+	}
+
+	var conf Config
+	if conf, err = r.Config(name); err != nil {
+		return nil, err
+	}
+
+	if err = conf.advisoryLockProblem(); err != nil {
+		return nil, err
+	}
+
+	if election.RetryInterval <= 0 {
+		election.RetryInterval = DefaultElectionRetryInterval
+	}
+
+	if election.RenewInterval <= 0 {
+		election.RenewInterval = DefaultElectionRenewInterval
+	}
+
+	return &Elector{registry: r, name: name, conf: election}, nil
+}
+
+// Campaign waits until the elector becomes the leader or the context is done, and returns channel
+// closed when the leadership is lost or resigned. The failed lock attempts are logged and retried.
+// The current loss channel is returned when the elector is the leader already.
+func (e *Elector) Campaign(ctx context.Context) (<-chan struct{}, error) {
+	var timer = time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-e.registry.done:
+			return nil, ErrRegistryShutdown
+		case <-timer.C:
+		}
+
+		if lost, ok := e.leadership(); ok {
+			return lost, nil
+		}
+
+		var lock, acquired, err = e.registry.advisoryLock(ctx, e.name, e.conf.Key, true)
+		if err != nil && ctx.Err() == nil {
+			e.registry.logger.Error("unable campaign for leadership", err, "connection", e.name, "key", e.conf.Key)
+		}
+
+		if acquired {
+			return e.elect(lock), nil
+		}
+
+		timer.Reset(e.conf.RetryInterval)
+	}
+}
+
+// Resign releases the leadership, the loss channel is closed. Nothing happens when the elector isn't
+// the leader.
+func (e *Elector) Resign(ctx context.Context) error {
+	e.mux.Lock()
+	var lock, lost, stop, stopped = e.lock, e.lost, e.stop, e.stopped
+	e.lock = nil
+	e.mux.Unlock()
+
+	if lock == nil {
+		return nil
+	}
+
+	close(stop)
+	<-stopped
+
+	defer close(lost)
+
+	return lock.release(ctx)
+}
+
+// IsLeader reports whether the elector is the leader.
+func (e *Elector) IsLeader() bool {
+	var _, ok = e.leadership()
+	return ok
+}
+
+// leadership returns the loss channel and reports whether the elector is the leader.
+func (e *Elector) leadership() (<-chan struct{}, bool) {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	return e.lost, e.lock != nil
+}
+
+// elect makes the elector the leader holding the lock and starts the lease renewal.
+func (e *Elector) elect(lock *advisoryLock) <-chan struct{} {
+	e.mux.Lock()
+	defer e.mux.Unlock()
+
+	e.lock = lock
+	e.lost = make(chan struct{})
+	e.stop = make(chan struct{})
+	e.stopped = make(chan struct{})
+
+	go e.renew(lock, e.lost, e.stop, e.stopped)
+
+	e.registry.logger.Info("elected leader", "connection", e.name, "key", e.conf.Key)
+
+	return e.lost
+}
+
+// renew checks the lock until it is lost or the elector resigns.
+func (e *Elector) renew(lock *advisoryLock, lost, stop, stopped chan struct{}) {
+	defer close(stopped)
+
+	var ticker = time.NewTicker(e.conf.RenewInterval)
+	defer ticker.Stop()
+
+	for {
+		var err error
+		select {
+		case <-stop:
+			return
+		case <-e.registry.done:
+			err = ErrRegistryShutdown
+		case <-ticker.C:
+			err = e.check(lock)
+		}
+
+		if err == nil {
+			continue
+		}
+
+		e.mux.Lock()
+		if e.lock != lock {
+			// the elector resigns concurrently
+			e.mux.Unlock()
+			return
+		}
+
+		e.lock = nil
+		e.mux.Unlock()
+
+		e.registry.logger.Error("leadership lost", err, "connection", e.name, "key", e.conf.Key)
+
+		lock.discard()
+		close(lost)
+
+		return
+	}
+}
+
+// check returns error when the lock isn't held anymore.
+func (e *Elector) check(lock *advisoryLock) error {
+	var ctx, cancel = context.WithTimeout(context.Background(), e.conf.RenewInterval)
+	defer cancel()
+
+	var held, err = lock.held(ctx)
+	if err != nil {
+		return err
+	}
+
+	if !held {
+		return errors.New("advisory lock is released")
+	}
+
+	return nil
+}
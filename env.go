@@ -0,0 +1,34 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+)
+
+// envRegexp matches ${VAR} placeholder, bare $VAR is left as is since DSN passwords may contain $.
+var envRegexp = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${VAR} placeholders with environment variable values, unset variable is error.
+func expandEnv(value string) (_ string, err error) {
+	var result = envRegexp.ReplaceAllStringFunc(value, func(placeholder string) string {
+		var name = envRegexp.FindStringSubmatch(placeholder)[1]
+
+		var env, ok = os.LookupEnv(name)
+		if !ok && err == nil {
+			err = fmt.Errorf("environment variable %s is not set", name)
+		}
+
+		return env
+	})
+
+	if err != nil {
+		return "", err
+	}
+
+	return result, nil
+}
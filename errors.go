@@ -0,0 +1,61 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Operations reported by ConnectionError.
+const (
+	OpOpen    = "open"
+	OpPing    = "ping"
+	OpResolve = "resolve"
+	OpAcquire = "acquire"
+)
+
+type (
+	// ConnectionError is error of the registry operation on the named connection. Node is index of
+	// the failed connection node or -1 when the operation failed for the connection as a whole.
+	ConnectionError struct {
+		Name string
+		Node int
+		Op   string
+		Err  error
+	}
+
+	// NodesError is combined error of every failed connection node in the node order.
+	NodesError []*ConnectionError
+)
+
+// Error implements the error interface.
+func (e *ConnectionError) Error() string {
+	if e.Node < 0 {
+		return fmt.Sprintf("%s %s connection : %s", e.Op, e.Name, e.Err)
+	}
+
+	return fmt.Sprintf("%s %s connection %s node %d : %s", e.Op, e.Name, nodeRole(e.Node), e.Node, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// Error implements the error interface.
+func (e NodesError) Error() string {
+	var parts = make([]string, 0, len(e))
+	for _, err := range e {
+		parts = append(parts, err.Error())
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// connectionError returns the error wrapped with the connection context.
+func connectionError(name string, node int, op string, err error) error {
+	return &ConnectionError{Name: name, Node: node, Op: op, Err: err}
+}
@@ -0,0 +1,108 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+type (
+	// expvarConnection is pool statistics of the connection published via expvar.
+	expvarConnection struct {
+		Open  bool         `json:"open"`
+		Nodes []expvarNode `json:"nodes"`
+	}
+
+	// expvarNode is pool statistics of the connection node published via expvar.
+	expvarNode struct {
+		Role              string `json:"role"`
+		Available         bool   `json:"available"`
+		MaxOpenConns      int    `json:"max_open_connections"`
+		OpenConns         int    `json:"open_connections"`
+		InUse             int    `json:"in_use_connections"`
+		Idle              int    `json:"idle_connections"`
+		WaitCount         int64  `json:"wait_count"`
+		WaitDuration      int64  `json:"wait_duration_ns"`
+		MaxIdleClosed     int64  `json:"max_idle_closed"`
+		MaxIdleTimeClosed int64  `json:"max_idle_time_closed"`
+		MaxLifetimeClosed int64  `json:"max_lifetime_closed"`
+	}
+)
+
+var (
+	// expvarMux guards expvarRegistries.
+	expvarMux sync.Mutex
+
+	// expvarRegistries are registries published by prefix, expvar variables can't be unpublished, so
+	// a registry constructed later with the same prefix replaces the previous one.
+	expvarRegistries = make(map[string]*Registry)
+)
+
+// WithExpvar option publishes pool statistics of every configured connection via expvar under the
+// prefix, "sql" when it is empty, so they are served by /debug/vars.
+func WithExpvar(prefix string) Option {
+	return optionFunc(func(r *Registry) {
+		if len(prefix) == 0 {
+			prefix = "sql"
+		}
+
+		r.expvarPrefix = prefix
+	})
+}
+
+// publishExpvar publishes the registry pool statistics under the prefix, the prefix must not be taken
+// by another expvar variable.
+func publishExpvar(prefix string, r *Registry) error {
+	expvarMux.Lock()
+	defer expvarMux.Unlock()
+
+	if _, ok := expvarRegistries[prefix]; !ok {
+		if expvar.Get(prefix) != nil {
+			return fmt.Errorf("expvar %s is already published", prefix)
+		}
+
+		expvar.Publish(prefix, expvar.Func(func() interface{} {
+			return expvarStats(prefix)
+		}))
+	}
+
+	expvarRegistries[prefix] = r
+
+	return nil
+}
+
+// expvarStats returns pool statistics of the registry published under the prefix.
+func expvarStats(prefix string) map[string]expvarConnection {
+	expvarMux.Lock()
+	var r = expvarRegistries[prefix]
+	expvarMux.Unlock()
+
+	var stats = r.Stats()
+	var result = make(map[string]expvarConnection, len(stats))
+	for name, s := range stats {
+		var c = expvarConnection{Open: s.Open, Nodes: make([]expvarNode, 0, len(s.Nodes))}
+		for _, n := range s.Nodes {
+			c.Nodes = append(c.Nodes, expvarNode{
+				Role:              n.Role,
+				Available:         n.Available,
+				MaxOpenConns:      n.MaxOpenConnections,
+				OpenConns:         n.OpenConnections,
+				InUse:             n.InUse,
+				Idle:              n.Idle,
+				WaitCount:         n.WaitCount,
+				WaitDuration:      int64(n.WaitDuration),
+				MaxIdleClosed:     n.MaxIdleClosed,
+				MaxIdleTimeClosed: n.MaxIdleTimeClosed,
+				MaxLifetimeClosed: n.MaxLifetimeClosed,
+			})
+		}
+
+		result[name] = c
+	}
+
+	return result
+}
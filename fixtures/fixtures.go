@@ -0,0 +1,167 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+// Package fixtures provide seed data loader for the sql registry connections.
+package fixtures
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	gzSQL "github.com/gozix/sql/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Load loads fixture files matching the patterns in name order inside the transaction of the
+// registry connection. SQL files are executed as is, YAML files are mappings of table names to
+// lists of rows, tables are loaded in the file order:
+//
+//	users:
+//	  - id: 1
+//	    name: John
+func Load(ctx context.Context, registry *gzSQL.Registry, name string, fsys fs.FS, patterns ...string) (err error) {
+	var driver string
+	if driver, err = registry.DriverWithName(name); err != nil {
+		return err
+	}
+
+	var files []string
+	if files, err = glob(fsys, patterns); err != nil {
+		return err
+	}
+
+	return registry.WithTx(ctx, name, nil, func(ctx context.Context, tx *sql.Tx) error {
+		return LoadTx(ctx, tx, driver, fsys, files...)
+	})
+}
+
+// LoadTx loads the fixture files inside the transaction, the driver name selects bind placeholders.
+func LoadTx(ctx context.Context, tx *sql.Tx, driver string, fsys fs.FS, files ...string) (err error) {
+	for _, file := range files {
+		var data []byte
+		if data, err = fs.ReadFile(fsys, file); err != nil {
+			return err
+		}
+
+		switch path.Ext(file) {
+		case ".sql":
+			if _, err = tx.ExecContext(ctx, string(data)); err != nil {
+				return fmt.Errorf("unable load fixture %s : %w", file, err)
+			}
+		case ".yml", ".yaml":
+			if err = loadYAML(ctx, tx, driver, data); err != nil {
+				return fmt.Errorf("unable load fixture %s : %w", file, err)
+			}
+		default:
+			return fmt.Errorf("unsupported fixture file %s", file)
+		}
+	}
+
+	return nil
+}
+
+// glob returns sorted unique file names matching the patterns.
+func glob(fsys fs.FS, patterns []string) (_ []string, err error) {
+	var (
+		files []string
+		seen  = make(map[string]bool)
+	)
+
+	for _, pattern := range patterns {
+		var matches []string
+		if matches, err = fs.Glob(fsys, pattern); err != nil {
+			return nil, err
+		}
+
+		for _, match := range matches {
+			if !seen[match] {
+				seen[match] = true
+				files = append(files, match)
+			}
+		}
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
+
+// loadYAML inserts rows of the YAML document.
+func loadYAML(ctx context.Context, tx *sql.Tx, driver string, data []byte) (err error) {
+	var doc yaml.Node
+	if err = yaml.Unmarshal(data, &doc); err != nil {
+		return err
+	}
+
+	if len(doc.Content) == 0 {
+		return nil
+	}
+
+	var root = doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("fixture must be mapping of tables to rows")
+	}
+
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		var (
+			table = root.Content[i].Value
+			rows  []map[string]interface{}
+		)
+
+		if err = root.Content[i+1].Decode(&rows); err != nil {
+			return fmt.Errorf("invalid %s rows : %w", table, err)
+		}
+
+		for _, row := range rows {
+			if err = insert(ctx, tx, driver, table, row); err != nil {
+				return fmt.Errorf("unable insert into %s : %w", table, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// insert inserts the row with columns in name order.
+func insert(ctx context.Context, tx *sql.Tx, driver, table string, row map[string]interface{}) error {
+	var columns = make([]string, 0, len(row))
+	for column := range row {
+		columns = append(columns, column)
+	}
+
+	sort.Strings(columns)
+
+	var (
+		args         = make([]interface{}, 0, len(columns))
+		placeholders = make([]string, 0, len(columns))
+	)
+
+	for i, column := range columns {
+		args = append(args, row[column])
+		placeholders = append(placeholders, placeholder(driver, i+1))
+	}
+
+	var query = fmt.Sprintf(
+		"INSERT INTO %s (%s) VALUES (%s)", table, strings.Join(columns, ", "), strings.Join(placeholders, ", "),
+	)
+
+	var _, err = tx.ExecContext(ctx, query, args...)
+	return err
+}
+
+// placeholder returns the driver bind placeholder of the n-th argument.
+func placeholder(driver string, n int) string {
+	switch driver {
+	case "postgres", "pgx", "cloudsqlpostgres":
+		return "$" + strconv.Itoa(n)
+	default:
+		return "?"
+	}
+}
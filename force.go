@@ -0,0 +1,23 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import "context"
+
+// forceMasterKey is context key of the force master flag.
+type forceMasterKey struct{}
+
+// ForceMaster returns context whose reads go to the master node, the slave getters called with it
+// return the master node of every connection. It is meant for code paths that must not observe
+// replication lag.
+func ForceMaster(ctx context.Context) context.Context {
+	return context.WithValue(ctx, forceMasterKey{}, true)
+}
+
+// IsMasterForced reports whether the context reads are forced to the master node.
+func IsMasterForced(ctx context.Context) bool {
+	var forced, _ = ctx.Value(forceMasterKey{}).(bool)
+	return forced
+}
@@ -0,0 +1,166 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+// Package gorm provide GORM access to the sql registry connections with read/write splitting.
+package gorm
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+
+	gzSQL "github.com/gozix/sql/v3"
+	"github.com/iqoption/nap"
+	"gorm.io/gorm"
+)
+
+type (
+	// DialectorFunc returns the GORM dialector using the connection pool, e.g.
+	//
+	//	func(pool gorm.ConnPool) gorm.Dialector {
+	//		return postgres.New(postgres.Config{Conn: pool})
+	//	}
+	DialectorFunc func(pool gorm.ConnPool) gorm.Dialector
+
+	// ConnPool is gorm.ConnPool of the named registry connection. Statements and transactions are
+	// executed on the master node, queries outside of transactions are executed on the slave node
+	// chosen by the connection read policy.
+	ConnPool struct {
+		registry *gzSQL.Registry
+		name     string
+		db       *nap.DB
+	}
+
+	// errConnector is driver.Connector failing with the error, its pool yields rows reporting it.
+	errConnector struct {
+		err error
+	}
+)
+
+var (
+	// ConnPool implements the gorm.ConnPool interface.
+	_ gorm.ConnPool = (*ConnPool)(nil)
+
+	// ConnPool implements the gorm.TxBeginner interface.
+	_ gorm.TxBeginner = (*ConnPool)(nil)
+
+	// ConnPool implements the gorm.GetDBConnector interface.
+	_ gorm.GetDBConnector = (*ConnPool)(nil)
+)
+
+// Open returns GORM database of the named registry connection. The registry owns the pools, so
+// the database must not be closed.
+func Open(registry *gzSQL.Registry, name string, dialector DialectorFunc, opts ...gorm.Option) (_ *gorm.DB, err error) {
+	var pool *ConnPool
+	if pool, err = NewConnPool(registry, name); err != nil {
+		return nil, err
+	}
+
+	return gorm.Open(dialector(pool), opts...)
+}
+
+// NewConnPool returns connection pool of the named registry connection, the connection is opened
+// if needed.
+func NewConnPool(registry *gzSQL.Registry, name string) (_ *ConnPool, err error) {
+	var db *nap.DB
+	if db, err = registry.ConnectionWithName(name); err != nil {
+		return nil, err
+	}
+
+	return &ConnPool{
+		registry: registry,
+		name:     name,
+		db:       db,
+	}, nil
+}
+
+// PrepareContext implements the gorm.ConnPool interface.
+func (p *ConnPool) PrepareContext(ctx context.Context, query string) (_ *sql.Stmt, err error) {
+	var db *sql.DB
+	if db, err = p.registry.MasterContext(ctx, p.name); err != nil {
+		return nil, err
+	}
+
+	return db.PrepareContext(ctx, query)
+}
+
+// ExecContext implements the gorm.ConnPool interface.
+func (p *ConnPool) ExecContext(ctx context.Context, query string, args ...interface{}) (_ sql.Result, err error) {
+	var db *sql.DB
+	if db, err = p.registry.MasterContext(ctx, p.name); err != nil {
+		return nil, err
+	}
+
+	return db.ExecContext(ctx, query, args...)
+}
+
+// QueryContext implements the gorm.ConnPool interface.
+func (p *ConnPool) QueryContext(ctx context.Context, query string, args ...interface{}) (_ *sql.Rows, err error) {
+	var db *sql.DB
+	if db, err = p.registry.SlaveWithNameContext(ctx, p.name); err != nil {
+		return nil, err
+	}
+
+	return db.QueryContext(ctx, query, args...)
+}
+
+// QueryRowContext implements the gorm.ConnPool interface. The connection getter error is reported
+// by Scan and Err of the returned row.
+func (p *ConnPool) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	var db, err = p.registry.SlaveWithNameContext(ctx, p.name)
+	if err != nil {
+		return errRow(ctx, err)
+	}
+
+	return db.QueryRowContext(ctx, query, args...)
+}
+
+// BeginTx implements the gorm.TxBeginner interface.
+func (p *ConnPool) BeginTx(ctx context.Context, opts *sql.TxOptions) (_ *sql.Tx, err error) {
+	var db *sql.DB
+	if db, err = p.registry.MasterContext(ctx, p.name); err != nil {
+		return nil, err
+	}
+
+	return db.BeginTx(ctx, opts)
+}
+
+// GetDBConn implements the gorm.GetDBConnector interface, it returns the master node.
+func (p *ConnPool) GetDBConn() (*sql.DB, error) {
+	return p.registry.Master(p.name)
+}
+
+// Ping pings the master node, it is invoked by gorm.Open.
+func (p *ConnPool) Ping() error {
+	var db, err = p.GetDBConn()
+	if err != nil {
+		return err
+	}
+
+	return db.Ping()
+}
+
+// errRow returns row reporting the error, *sql.Row can't be constructed outside of database/sql, so
+// it is queried from the pool whose connections fail with the error.
+func errRow(ctx context.Context, err error) *sql.Row {
+	var db = sql.OpenDB(&errConnector{err: err})
+	defer db.Close()
+
+	return db.QueryRowContext(ctx, "")
+}
+
+// Connect implements driver.Connector.
+func (c *errConnector) Connect(context.Context) (driver.Conn, error) {
+	return nil, c.err
+}
+
+// Driver implements driver.Connector.
+func (c *errConnector) Driver() driver.Driver {
+	return c
+}
+
+// Open implements driver.Driver.
+func (c *errConnector) Open(string) (driver.Conn, error) {
+	return nil, c.err
+}
@@ -0,0 +1,162 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultPingTimeout is default timeout of the registry ping.
+const DefaultPingTimeout = 5 * time.Second
+
+// PingError is combined error of the failed connections keyed by name.
+type PingError map[string]error
+
+// HealthCheck pings every opened connection concurrently and returns per connection status,
+// nil value means the connection is healthy, otherwise it is *ConnectionError of the failed node.
+// Only the master node of the connection tolerating partial failure is required to respond.
+// Connections that are not opened yet are skipped unless the registry was created with the
+// WithHealthCheckOpen option.
+func (r *Registry) HealthCheck(ctx context.Context) map[string]error {
+	return r.check(ctx, r.healthCheckOpen)
+}
+
+// Ping pings every opened connection concurrently, see PingContext.
+func (r *Registry) Ping() error {
+	return r.PingContext(context.Background())
+}
+
+// PingContext pings every opened connection concurrently, connections that are not opened yet are
+// skipped. DefaultPingTimeout is applied when the context has no deadline. The returned error is
+// PingError of the failed connections.
+func (r *Registry) PingContext(ctx context.Context) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultPingTimeout)
+		defer cancel()
+	}
+
+	return newPingError(r.check(ctx, false))
+}
+
+// Liveness checks only the connections that are already opened, it never opens lazy connections,
+// so it stays cheap and does not fail because of a database that was never used.
+func (r *Registry) Liveness(ctx context.Context) error {
+	return r.PingContext(ctx)
+}
+
+// Readiness opens every configured connection that is not opened yet and pings all of them, so it
+// fails until every database is reachable. DefaultPingTimeout is applied when the context has no
+// deadline. The returned error is PingError of the failed connections.
+func (r *Registry) Readiness(ctx context.Context) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, DefaultPingTimeout)
+		defer cancel()
+	}
+
+	return newPingError(r.check(ctx, true))
+}
+
+// Error implements the error interface.
+func (e PingError) Error() string {
+	var names = make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var parts = make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s : %s", name, e[name]))
+	}
+
+	return strings.Join(parts, "; ")
+}
+
+// newPingError returns PingError of the failed connections or nil if every connection is healthy.
+func newPingError(result map[string]error) error {
+	var e = make(PingError)
+	for name, err := range result {
+		if err != nil {
+			e[name] = err
+		}
+	}
+
+	if len(e) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+// check pings every opened connection concurrently, connections that are not opened yet are opened
+// before if open is set, concurrently and with the registry unlocked.
+func (r *Registry) check(ctx context.Context, open bool) map[string]error {
+	var (
+		result = make(map[string]error)
+		conns  = make(map[string]*connection)
+	)
+
+	r.mux.Lock()
+	for name, conf := range r.conf {
+		if c, ok := r.conns[name]; ok {
+			conns[name] = c
+			continue
+		}
+
+		if open && !r.shutdown && !conf.external() {
+			conns[name] = nil
+		}
+	}
+	r.mux.Unlock()
+
+	var (
+		mux sync.Mutex
+		wg  sync.WaitGroup
+	)
+
+	for name, c := range conns {
+		wg.Add(1)
+		go func(name string, c *connection) {
+			defer wg.Done()
+
+			var err error
+			if c == nil {
+				if c, err = r.connection(ctx, name); err != nil {
+					mux.Lock()
+					result[name] = err
+					mux.Unlock()
+
+					return
+				}
+			}
+
+			if c.conf.ToleratePartialFailure {
+				_, err = c.pingMaster(ctx)
+			} else {
+				err = c.ping(ctx)
+			}
+
+			if err != nil {
+				r.logger.Error("connection ping failed", err, connectionKeyvals(name, err)...)
+			}
+
+			mux.Lock()
+			result[name] = err
+			mux.Unlock()
+		}(name, c)
+	}
+
+	wg.Wait()
+
+	return result
+}
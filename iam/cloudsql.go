@@ -0,0 +1,94 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package iam
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// metadataTokenURL is GCE metadata server endpoint issuing access token of the default service account.
+const metadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+type (
+	// CloudSQLConfig is GCP Cloud SQL IAM database authentication configuration.
+	CloudSQLConfig struct {
+		// Username is database user of the IAM principal, e.g. the service account email without
+		// the .gserviceaccount.com suffix for postgres.
+		Username string
+
+		// Token returns OAuth2 access token of the IAM principal and its expiration, by default it is
+		// issued by the metadata server to the default service account.
+		Token func(ctx context.Context) (token string, expire time.Time, err error)
+
+		// Client is HTTP client of the metadata server, http.DefaultClient by default.
+		Client *http.Client
+	}
+
+	// metadataToken is metadata server token response.
+	metadataToken struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+)
+
+// NewCloudSQLProvider returns provider of GCP Cloud SQL authentication tokens, the first node is master.
+// Node endpoints are not used, the access token is the same for every instance.
+func NewCloudSQLProvider(conf CloudSQLConfig, nodes ...Node) (*Provider, error) {
+	if len(conf.Username) == 0 {
+		return nil, errors.New("cloud sql username is required")
+	}
+
+	if conf.Client == nil {
+		conf.Client = http.DefaultClient
+	}
+
+	if conf.Token == nil {
+		conf.Token = func(ctx context.Context) (string, time.Time, error) {
+			return metadataAccessToken(ctx, conf.Client)
+		}
+	}
+
+	return newProvider(func(ctx context.Context, _ string, _ time.Time) (*Credentials, error) {
+		var token, expire, err = conf.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Credentials{Username: conf.Username, Password: token, Expire: expire}, nil
+	}, nodes)
+}
+
+// metadataAccessToken requests access token of the default service account from the metadata server.
+func metadataAccessToken(ctx context.Context, client *http.Client) (_ string, _ time.Time, err error) {
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, metadataTokenURL, nil); err != nil {
+		return "", time.Time{}, err
+	}
+
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	var resp *http.Response
+	if resp, err = client.Do(req); err != nil {
+		return "", time.Time{}, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", time.Time{}, fmt.Errorf("metadata server responded %d", resp.StatusCode)
+	}
+
+	var token metadataToken
+	if err = json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", time.Time{}, fmt.Errorf("unable decode metadata server response : %w", err)
+	}
+
+	return token.AccessToken, time.Now().Add(time.Duration(token.ExpiresIn) * time.Second), nil
+}
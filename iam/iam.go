@@ -0,0 +1,121 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+// Package iam provide short-lived IAM authentication tokens of AWS RDS and GCP Cloud SQL as database
+// passwords of the sql registry.
+package iam
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/gozix/sql/v3"
+)
+
+// refreshMargin is time before the token expiration when the token is replaced by a new one.
+const refreshMargin = 5 * time.Minute
+
+type (
+	// Credentials are database credentials whose password is IAM authentication token.
+	Credentials struct {
+		Username string
+		Password string
+		Expire   time.Time
+	}
+
+	// Node is connection node of the provider.
+	Node struct {
+		// Endpoint is host:port of the database server the token is issued for.
+		Endpoint string
+
+		// DSN is text/template DSN with Username and Password fields, e.g.
+		// postgres://{{.Username | urlquery}}:{{.Password | urlquery}}@db.example.com:5432/app.
+		DSN string
+	}
+
+	// Provider renders node DSN templates with IAM tokens, tokens are issued on demand and reused
+	// until they are about to expire, so every new physical connection authenticates with a valid one.
+	Provider struct {
+		issue     issueFunc
+		nodes     []Node
+		templates []*template.Template
+
+		mux   sync.Mutex
+		creds []*Credentials
+		now   func() time.Time
+	}
+
+	// issueFunc issues the token of the node endpoint.
+	issueFunc func(ctx context.Context, endpoint string, now time.Time) (*Credentials, error)
+)
+
+// Provider.DSN implements sql.DSNProvider.
+var _ sql.DSNProvider = (*Provider)(nil).DSN
+
+// newProvider is provider constructor, the first node is master.
+func newProvider(issue issueFunc, nodes []Node) (_ *Provider, err error) {
+	var p = Provider{
+		issue:     issue,
+		nodes:     nodes,
+		templates: make([]*template.Template, 0, len(nodes)),
+		creds:     make([]*Credentials, len(nodes)),
+		now:       time.Now,
+	}
+
+	for i, node := range nodes {
+		var tpl *template.Template
+		if tpl, err = template.New(fmt.Sprintf("node_%d", i)).Parse(node.DSN); err != nil {
+			return nil, err
+		}
+
+		p.templates = append(p.templates, tpl)
+	}
+
+	return &p, nil
+}
+
+// DSN implements sql.DSNProvider.
+func (p *Provider) DSN(ctx context.Context, _ string) (_ []string, err error) {
+	var dsn = make([]string, 0, len(p.templates))
+	for i, tpl := range p.templates {
+		var creds *Credentials
+		if creds, err = p.Credentials(ctx, i); err != nil {
+			return nil, err
+		}
+
+		var b strings.Builder
+		if err = tpl.Execute(&b, creds); err != nil {
+			return nil, err
+		}
+
+		dsn = append(dsn, b.String())
+	}
+
+	return dsn, nil
+}
+
+// Credentials returns current credentials of the node, the token is issued if it is missing or
+// expires within five minutes.
+func (p *Provider) Credentials(ctx context.Context, node int) (_ *Credentials, err error) {
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	var now = p.now()
+	if creds := p.creds[node]; creds != nil && now.Add(refreshMargin).Before(creds.Expire) {
+		return creds, nil
+	}
+
+	var creds *Credentials
+	if creds, err = p.issue(ctx, p.nodes[node].Endpoint, now); err != nil {
+		return nil, fmt.Errorf("unable issue iam token of node %d : %w", node, err)
+	}
+
+	p.creds[node] = creds
+
+	return creds, nil
+}
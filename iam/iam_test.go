@@ -0,0 +1,81 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package iam
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestProviderCredentials(t *testing.T) {
+	var (
+		start  = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		now    = start
+		issued = map[string]int{}
+		errIAM error
+	)
+
+	var p, err = newProvider(func(_ context.Context, endpoint string, at time.Time) (*Credentials, error) {
+		if errIAM != nil {
+			return nil, errIAM
+		}
+
+		issued[endpoint]++
+
+		return &Credentials{
+			Username: "app",
+			Password: fmt.Sprintf("%s_%d", endpoint, issued[endpoint]),
+			Expire:   at.Add(15 * time.Minute),
+		}, nil
+	}, []Node{
+		{Endpoint: "master", DSN: "{{.Username}}:{{.Password}}@master"},
+		{Endpoint: "slave", DSN: "{{.Username}}:{{.Password}}@slave"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p.now = func() time.Time { return now }
+
+	var cases = []struct {
+		name    string
+		elapsed time.Duration
+		want    []string
+	}{
+		{name: "issued", want: []string{"app:master_1@master", "app:slave_1@slave"}},
+		{name: "reused", elapsed: 9 * time.Minute, want: []string{"app:master_1@master", "app:slave_1@slave"}},
+		{name: "refreshed before expiration", elapsed: 11 * time.Minute, want: []string{"app:master_2@master", "app:slave_2@slave"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			now = start.Add(tc.elapsed)
+
+			var got, err = p.DSN(context.Background(), "main")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("dsn is %v, want %v", got, tc.want)
+			}
+		})
+	}
+
+	errIAM, now = errors.New("access denied"), start.Add(time.Hour)
+	if _, err = p.DSN(context.Background(), "main"); !errors.Is(err, errIAM) {
+		t.Errorf("error is %v, want %v", err, errIAM)
+	}
+}
+
+func TestNewProviderInvalidTemplate(t *testing.T) {
+	if _, err := newProvider(nil, []Node{{DSN: "{{.Username"}}); err == nil {
+		t.Error("provider of invalid dsn template is created")
+	}
+}
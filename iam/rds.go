@@ -0,0 +1,161 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package iam
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// rdsTokenLifetime is lifetime of RDS authentication token.
+const rdsTokenLifetime = 15 * time.Minute
+
+type (
+	// RDSConfig is AWS RDS IAM authentication configuration.
+	RDSConfig struct {
+		// Region is AWS region of the database, AWS_REGION or AWS_DEFAULT_REGION by default.
+		Region string
+
+		// Username is database user authenticated by IAM.
+		Username string
+
+		// Credentials returns AWS credentials signing the token, the AWS_ACCESS_KEY_ID,
+		// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN environment variables by default.
+		Credentials func(ctx context.Context) (AWSCredentials, error)
+	}
+
+	// AWSCredentials are AWS credentials.
+	AWSCredentials struct {
+		AccessKeyID     string
+		SecretAccessKey string
+		SessionToken    string
+	}
+)
+
+// ErrNoAWSCredentials is error triggered when AWS credentials are not found in the environment.
+var ErrNoAWSCredentials = errors.New("aws credentials not found")
+
+// NewRDSProvider returns provider of AWS RDS authentication tokens, the first node is master. The
+// mysql driver requires TLS and allowCleartextPasswords=true to send the token.
+func NewRDSProvider(conf RDSConfig, nodes ...Node) (*Provider, error) {
+	if len(conf.Region) == 0 {
+		conf.Region = os.Getenv("AWS_REGION")
+	}
+
+	if len(conf.Region) == 0 {
+		conf.Region = os.Getenv("AWS_DEFAULT_REGION")
+	}
+
+	if len(conf.Region) == 0 {
+		return nil, errors.New("aws region is required")
+	}
+
+	if conf.Credentials == nil {
+		conf.Credentials = envCredentials
+	}
+
+	return newProvider(func(ctx context.Context, endpoint string, now time.Time) (_ *Credentials, err error) {
+		var creds AWSCredentials
+		if creds, err = conf.Credentials(ctx); err != nil {
+			return nil, err
+		}
+
+		return &Credentials{
+			Username: conf.Username,
+			Password: rdsToken(endpoint, conf.Region, conf.Username, creds, now),
+			Expire:   now.Add(rdsTokenLifetime),
+		}, nil
+	}, nodes)
+}
+
+// envCredentials returns AWS credentials of the environment variables.
+func envCredentials(context.Context) (AWSCredentials, error) {
+	var creds = AWSCredentials{
+		AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+	}
+
+	if len(creds.AccessKeyID) == 0 || len(creds.SecretAccessKey) == 0 {
+		return AWSCredentials{}, ErrNoAWSCredentials
+	}
+
+	return creds, nil
+}
+
+// rdsToken returns the authentication token, it is connect request to the endpoint presigned with
+// AWS Signature Version 4.
+func rdsToken(endpoint, region, username string, creds AWSCredentials, now time.Time) string {
+	now = now.UTC()
+
+	var (
+		date  = now.Format("20060102")
+		stamp = now.Format("20060102T150405Z")
+		scope = date + "/" + region + "/rds-db/aws4_request"
+		query = map[string]string{
+			"Action":              "connect",
+			"DBUser":              username,
+			"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+			"X-Amz-Credential":    creds.AccessKeyID + "/" + scope,
+			"X-Amz-Date":          stamp,
+			"X-Amz-Expires":       "900",
+			"X-Amz-SignedHeaders": "host",
+		}
+	)
+
+	if len(creds.SessionToken) > 0 {
+		query["X-Amz-Security-Token"] = creds.SessionToken
+	}
+
+	var keys = make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	var params = make([]string, 0, len(keys))
+	for _, key := range keys {
+		params = append(params, awsEscape(key)+"="+awsEscape(query[key]))
+	}
+
+	var (
+		canonicalQuery = strings.Join(params, "&")
+		canonical      = "GET\n/\n" + canonicalQuery + "\nhost:" + endpoint + "\n\nhost\n" + sha256Hex("")
+		stringToSign   = "AWS4-HMAC-SHA256\n" + stamp + "\n" + scope + "\n" + sha256Hex(canonical)
+		key            = []byte("AWS4" + creds.SecretAccessKey)
+	)
+
+	for _, part := range []string{date, region, "rds-db", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+
+	return endpoint + "/?" + canonicalQuery + "&X-Amz-Signature=" + hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// awsEscape escapes the value as RFC 3986 requires.
+func awsEscape(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+func sha256Hex(value string) string {
+	var sum = sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, value string) []byte {
+	var mac = hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(value))
+
+	return mac.Sum(nil)
+}
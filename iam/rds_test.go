@@ -0,0 +1,142 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package iam
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigningKey(t *testing.T) {
+	// signing key derivation example of the AWS Signature Version 4 documentation
+	var key = []byte("AWS4wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY")
+	for _, part := range []string{"20120215", "us-east-1", "iam", "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+
+	const want = "f4780e2d9f65fa895f9c67b32ce1baf0b0d8a43505a000a1a9e090d414db404d"
+	if got := hex.EncodeToString(key); got != want {
+		t.Errorf("signing key is %s, want %s", got, want)
+	}
+}
+
+func TestRDSToken(t *testing.T) {
+	const endpoint = "db.example.com:5432"
+
+	var now = time.Date(2024, 3, 1, 12, 30, 45, 0, time.FixedZone("MSK", 3*60*60))
+
+	var cases = []struct {
+		name  string
+		creds AWSCredentials
+	}{
+		{name: "static", creds: AWSCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}},
+		{name: "session", creds: AWSCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret", SessionToken: "session/token"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var token = rdsToken(endpoint, "eu-west-1", "app user", tc.creds, now)
+			if token != rdsToken(endpoint, "eu-west-1", "app user", tc.creds, now) {
+				t.Error("token is not deterministic")
+			}
+
+			if !strings.HasPrefix(token, endpoint+"/?") {
+				t.Fatalf("token %s is not presigned request to the endpoint", token)
+			}
+
+			var (
+				raw       = strings.TrimPrefix(token, endpoint+"/?")
+				i         = strings.LastIndex(raw, "&X-Amz-Signature=")
+				canonical = raw[:i]
+				signature = raw[i+len("&X-Amz-Signature="):]
+			)
+
+			if strings.Contains(canonical, "+") {
+				t.Errorf("query %s is not escaped as RFC 3986 requires", canonical)
+			}
+
+			var query, err = url.ParseQuery(canonical)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var want = map[string]string{
+				"Action":               "connect",
+				"DBUser":               "app user",
+				"X-Amz-Algorithm":      "AWS4-HMAC-SHA256",
+				"X-Amz-Credential":     "AKIDEXAMPLE/20240301/eu-west-1/rds-db/aws4_request",
+				"X-Amz-Date":           "20240301T093045Z",
+				"X-Amz-Expires":        "900",
+				"X-Amz-SignedHeaders":  "host",
+				"X-Amz-Security-Token": tc.creds.SessionToken,
+			}
+
+			for key, value := range want {
+				if got := query.Get(key); got != value {
+					t.Errorf("%s is %q, want %q", key, got, value)
+				}
+			}
+
+			var key = []byte("AWS4" + tc.creds.SecretAccessKey)
+			for _, part := range []string{"20240301", "eu-west-1", "rds-db", "aws4_request"} {
+				key = hmacSHA256(key, part)
+			}
+
+			var stringToSign = "AWS4-HMAC-SHA256\n20240301T093045Z\n20240301/eu-west-1/rds-db/aws4_request\n" +
+				sha256Hex("GET\n/\n"+canonical+"\nhost:"+endpoint+"\n\nhost\n"+sha256Hex(""))
+
+			if want := hex.EncodeToString(hmacSHA256(key, stringToSign)); signature != want {
+				t.Errorf("signature is %s, want %s", signature, want)
+			}
+
+			if other := rdsToken("replica.example.com:5432", "eu-west-1", "app user", tc.creds, now); strings.HasSuffix(other, signature) {
+				t.Error("signature does not depend on the endpoint")
+			}
+		})
+	}
+}
+
+func TestNewRDSProvider(t *testing.T) {
+	t.Setenv("AWS_REGION", "")
+	t.Setenv("AWS_DEFAULT_REGION", "")
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+
+	if _, err := NewRDSProvider(RDSConfig{Username: "app"}); err == nil {
+		t.Error("provider without region is created")
+	}
+
+	t.Setenv("AWS_DEFAULT_REGION", "eu-west-1")
+
+	var p, err = NewRDSProvider(RDSConfig{Username: "app"}, Node{
+		Endpoint: "db.example.com:5432",
+		DSN:      "postgres://{{.Username}}:{{.Password | urlquery}}@db.example.com:5432/app",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = p.DSN(context.Background(), "main"); !errors.Is(err, ErrNoAWSCredentials) {
+		t.Errorf("error is %v, want %v", err, ErrNoAWSCredentials)
+	}
+
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	var dsn []string
+	if dsn, err = p.DSN(context.Background(), "main"); err != nil {
+		t.Fatal(err)
+	}
+
+	const prefix = "postgres://app:db.example.com%3A5432%2F%3FAction%3Dconnect"
+	if len(dsn) != 1 || !strings.HasPrefix(dsn[0], prefix) {
+		t.Errorf("dsn is %v, want token of the environment credentials", dsn)
+	}
+}
@@ -0,0 +1,40 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"time"
+)
+
+// keepalive pings every node of the connection each interval until the connection is closed, so idle
+// sockets are kept alive by NAT and firewalls and dead ones are discarded before queries hit them.
+// Failed pings are logged and reported to the slave node circuit breakers.
+func (c *connection) keepalive(interval time.Duration) {
+	var ticker = time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		for i, node := range c.db.Databases() {
+			var ctx, cancel = context.WithTimeout(context.Background(), interval)
+			var err = node.PingContext(ctx)
+			cancel()
+
+			if err != nil {
+				c.logger.Error("keepalive ping failed", err, "connection", c.name, "node", i)
+			}
+
+			if b := c.breakers[i]; b != nil {
+				b.report(err)
+			}
+		}
+	}
+}
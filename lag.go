@@ -0,0 +1,255 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// DefaultReplicaLagInterval is default interval of the replication lag measurement.
+const DefaultReplicaLagInterval = 5 * time.Second
+
+type (
+	// ReplicaLag is replication lag measurement configuration, it is used when Config.MaxReplicaLag
+	// is set.
+	ReplicaLag struct {
+		// Interval is interval of the measurement, zero means DefaultReplicaLagInterval.
+		Interval time.Duration `json:"interval"`
+
+		// Query is query returning the slave lag in seconds, the driver specific query is used
+		// when empty. It is ignored when the heartbeat table is set.
+		Query string `json:"query"`
+
+		// HeartbeatTable is table with a single row and a single BIGINT "ts" column. The master
+		// writes the current unix time in nanoseconds to it on every measurement and the lag is
+		// the age of the value read from the slave.
+		HeartbeatTable string `json:"heartbeat_table"`
+	}
+
+	// lagMonitor measures replication lag of the slave nodes in background.
+	lagMonitor struct {
+		mux    sync.Mutex
+		conf   ReplicaLag
+		max    time.Duration
+		driver string
+		nodes  []*sql.DB
+		lags   []time.Duration
+		failed []bool
+		evict  evictFunc
+	}
+)
+
+// newLagMonitor returns lag monitor of the connection nodes or nil if the measurement is disabled.
+func newLagMonitor(conf Config, nodes []*sql.DB, evict evictFunc) *lagMonitor {
+	if conf.MaxReplicaLag <= 0 || len(nodes) < 2 {
+		return nil
+	}
+
+	var m = lagMonitor{
+		conf:   conf.ReplicaLag,
+		max:    conf.MaxReplicaLag,
+		driver: conf.Driver,
+		nodes:  nodes,
+		lags:   make([]time.Duration, len(nodes)),
+		failed: make([]bool, len(nodes)),
+		evict:  evict,
+	}
+
+	if m.conf.Interval <= 0 {
+		m.conf.Interval = DefaultReplicaLagInterval
+	}
+
+	return &m
+}
+
+// available reports whether the node lag is measured and does not exceed the maximum.
+func (m *lagMonitor) available(idx int) bool {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	return m.within(idx)
+}
+
+// within reports whether the node lag is measured and does not exceed the maximum, the mutex must be held.
+func (m *lagMonitor) within(idx int) bool {
+	return !m.failed[idx] && m.lags[idx] <= m.max
+}
+
+// run measures the lag right away and then every interval until done is closed.
+func (m *lagMonitor) run(done <-chan struct{}) {
+	var ticker = time.NewTicker(m.conf.Interval)
+	defer ticker.Stop()
+
+	for {
+		m.measure()
+
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// measure measures the lag of every slave node, a node is excluded when its lag can not be measured.
+func (m *lagMonitor) measure() {
+	var ctx, cancel = context.WithTimeout(context.Background(), m.conf.Interval)
+	defer cancel()
+
+	if m.conf.HeartbeatTable != "" {
+		var query = fmt.Sprintf("UPDATE %s SET ts = %d", m.conf.HeartbeatTable, time.Now().UnixNano())
+		if _, err := m.nodes[0].ExecContext(ctx, query); err != nil {
+			m.reportAll(err)
+			return
+		}
+	}
+
+	for idx := 1; idx < len(m.nodes); idx++ {
+		var lag, err = m.lag(ctx, m.nodes[idx])
+		m.report(idx, lag, err)
+	}
+}
+
+// lag returns the replication lag of the node.
+func (m *lagMonitor) lag(ctx context.Context, node *sql.DB) (time.Duration, error) {
+	if m.conf.HeartbeatTable != "" {
+		var ts int64
+		if err := node.QueryRowContext(ctx, "SELECT ts FROM "+m.conf.HeartbeatTable).Scan(&ts); err != nil {
+			return 0, err
+		}
+
+		return time.Since(time.Unix(0, ts)), nil
+	}
+
+	if m.conf.Query == "" && m.driver == "mysql" {
+		return mysqlReplicaLag(ctx, node)
+	}
+
+	var query = m.conf.Query
+	if query == "" {
+		switch m.driver {
+		case "postgres", "pgx", "cloudsqlpostgres":
+			// the idle primary doesn't advance the replay timestamp, so the replica that replayed
+			// everything it received is not lagging
+			query = "SELECT CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0 " +
+				"ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0) END"
+		case DriverClickHouse:
+			query = "SELECT toFloat64(max(absolute_delay)) FROM system.replicas"
+		default:
+			return 0, fmt.Errorf("no replica lag query for %q driver", m.driver)
+		}
+	}
+
+	var seconds float64
+	if err := node.QueryRowContext(ctx, query).Scan(&seconds); err != nil {
+		return 0, err
+	}
+
+	return time.Duration(seconds * float64(time.Second)), nil
+}
+
+// report stores the measurement result of the node.
+func (m *lagMonitor) report(idx int, lag time.Duration, err error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	var available = m.within(idx)
+
+	m.lags[idx] = lag
+	m.failed[idx] = err != nil
+
+	if err == nil && lag > m.max {
+		err = fmt.Errorf("replica lag %s exceeds %s", lag, m.max)
+	}
+
+	if m.within(idx) != available {
+		m.evict(idx, !m.within(idx), err)
+	}
+}
+
+// reportAll marks every slave node failed.
+func (m *lagMonitor) reportAll(err error) {
+	for idx := 1; idx < len(m.nodes); idx++ {
+		m.report(idx, 0, err)
+	}
+}
+
+// mysqlReplicaLag returns Seconds_Behind_Master (Seconds_Behind_Source since MySQL 8.0.22) of the node.
+// SHOW REPLICA STATUS is queried, SHOW SLAVE STATUS when the server rejects it, since the former is
+// missing before MySQL 8.0.22 and the latter is removed in MySQL 8.4. The stopped replication reports
+// NULL, it is treated as an error.
+func mysqlReplicaLag(ctx context.Context, node *sql.DB) (_ time.Duration, err error) {
+	var (
+		rows  *sql.Rows
+		myErr *mysql.MySQLError
+	)
+
+	if rows, err = node.QueryContext(ctx, "SHOW REPLICA STATUS"); errors.As(err, &myErr) {
+		rows, err = node.QueryContext(ctx, "SHOW SLAVE STATUS")
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer func() {
+		if closeErr := rows.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	var columns []string
+	if columns, err = rows.Columns(); err != nil {
+		return 0, err
+	}
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return 0, err
+		}
+
+		return 0, errors.New("node is not a replica")
+	}
+
+	var (
+		values = make([]sql.RawBytes, len(columns))
+		dest   = make([]interface{}, len(columns))
+	)
+
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	if err = rows.Scan(dest...); err != nil {
+		return 0, err
+	}
+
+	for i, column := range columns {
+		if column != "Seconds_Behind_Master" && column != "Seconds_Behind_Source" {
+			continue
+		}
+
+		if values[i] == nil {
+			return 0, errors.New("replication is stopped")
+		}
+
+		var seconds int64
+		if seconds, err = strconv.ParseInt(string(values[i]), 10, 64); err != nil {
+			return 0, err
+		}
+
+		return time.Duration(seconds) * time.Second, nil
+	}
+
+	return 0, errors.New("no replica lag column")
+}
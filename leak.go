@@ -0,0 +1,163 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"fmt"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	// Leak is rows or transaction held open longer than the connection LeakThreshold.
+	Leak struct {
+		Connection string
+		Node       int
+		Role       string
+
+		// Op is OpQuery for rows and OpBegin for transaction.
+		Op    string
+		Query string
+		Since time.Time
+
+		// Stack is stack trace of the goroutine that opened the rows or began the transaction.
+		Stack string
+	}
+
+	// leakTracker records rows and transactions opened on the connection nodes.
+	leakTracker struct {
+		mux       sync.Mutex
+		threshold time.Duration
+		clock     Clock
+		seq       uint64
+		open      map[uint64]*trackedLeak
+	}
+
+	// trackedLeak is open rows or transaction.
+	trackedLeak struct {
+		Leak
+		reported bool
+	}
+)
+
+// newLeakTracker returns tracker or nil if the threshold is not set.
+func newLeakTracker(threshold time.Duration, clock Clock) *leakTracker {
+	if threshold <= 0 {
+		return nil
+	}
+
+	return &leakTracker{
+		threshold: threshold,
+		clock:     clock,
+		open:      make(map[uint64]*trackedLeak),
+	}
+}
+
+// Leaks returns rows and transactions of the opened connections held longer than their LeakThreshold,
+// the oldest go first. Connections without LeakThreshold are not tracked.
+func (r *Registry) Leaks() []Leak {
+	r.mux.Lock()
+	var trackers = make([]*leakTracker, 0, len(r.conns))
+	for _, c := range r.conns {
+		if c.leaks != nil {
+			trackers = append(trackers, c.leaks)
+		}
+	}
+	r.mux.Unlock()
+
+	var leaks []Leak
+	for _, t := range trackers {
+		for _, l := range t.leaked(false) {
+			leaks = append(leaks, l.Leak)
+		}
+	}
+
+	sort.Slice(leaks, func(i, j int) bool {
+		return leaks[i].Since.Before(leaks[j].Since)
+	})
+
+	return leaks
+}
+
+// track records the operation with the caller stack, the returned function forgets it. Nil tracker
+// returns nil function.
+func (t *leakTracker) track(e *QueryEvent) func() {
+	if t == nil {
+		return nil
+	}
+
+	var l = trackedLeak{
+		Leak: Leak{
+			Connection: e.Connection,
+			Node:       e.Node,
+			Role:       e.Role,
+			Op:         e.Op,
+			Query:      e.Query,
+			Since:      t.clock.Now(),
+			Stack:      string(debug.Stack()),
+		},
+	}
+
+	t.mux.Lock()
+	t.seq++
+	var id = t.seq
+	t.open[id] = &l
+	t.mux.Unlock()
+
+	return func() {
+		t.mux.Lock()
+		delete(t.open, id)
+		t.mux.Unlock()
+	}
+}
+
+// leaked returns copies of the operations held longer than the threshold, when unreported is set
+// only those not returned before are returned.
+func (t *leakTracker) leaked(unreported bool) []trackedLeak {
+	t.mux.Lock()
+	defer t.mux.Unlock()
+
+	var (
+		now    = t.clock.Now()
+		leaked []trackedLeak
+	)
+
+	for _, l := range t.open {
+		if now.Sub(l.Since) < t.threshold || unreported && l.reported {
+			continue
+		}
+
+		if unreported {
+			l.reported = true
+		}
+
+		leaked = append(leaked, *l)
+	}
+
+	return leaked
+}
+
+// run logs every new leak each threshold until done is closed.
+func (t *leakTracker) run(logger Logger, done <-chan struct{}) {
+	var ticker = time.NewTicker(t.threshold)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		for _, l := range t.leaked(true) {
+			var err = fmt.Errorf("%s held longer than %s", l.Op, t.threshold)
+			logger.Error("connection leak", err,
+				"connection", l.Connection, "node", l.Node, "query", l.Query, "since", l.Since, "stack", l.Stack,
+			)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// DefaultListenerBuffer is capacity of the subscription channel used when the buffer is not set.
+const DefaultListenerBuffer = 64
+
+// Reconnect delays of the listener connection, the delay is doubled after every failed attempt.
+const (
+	listenerMinReconnectDelay = time.Second
+	listenerMaxReconnectDelay = 30 * time.Second
+)
+
+type (
+	// Notification is Postgres notification of the listened channel.
+	Notification struct {
+		Channel string
+		Payload string
+
+		// PID is process ID of the notifying backend.
+		PID uint32
+
+		// Reconnect notification without payload is delivered to every subscription once the lost
+		// listener connection is re-established, the notifications sent in between are lost.
+		Reconnect bool
+	}
+
+	// Listener maintains dedicated master node connection of the Postgres connection listening to the
+	// subscribed channels. The lost connection is re-established and the channels are listened again.
+	Listener struct {
+		registry *Registry
+		name     string
+		ctx      context.Context
+		cancel   context.CancelFunc
+		stopped  chan struct{}
+
+		mux   sync.Mutex
+		subs  map[string][]*Subscription
+		dirty bool
+		wake  context.CancelFunc
+	}
+
+	// Subscription is subscription of the listener channel.
+	Subscription struct {
+		// C delivers the channel notifications, it is not closed by Close.
+		C <-chan Notification
+
+		listener *Listener
+		channel  string
+		c        chan Notification
+		done     chan struct{}
+		once     sync.Once
+	}
+
+	// listenConn is connection listening to the notifications.
+	listenConn interface {
+		listen(ctx context.Context, channel string) error
+		unlisten(ctx context.Context, channel string) error
+		wait(ctx context.Context) (Notification, error)
+	}
+
+	// pgxListenConn is listenConn of the pgx connection.
+	pgxListenConn struct {
+		conn *pgx.Conn
+	}
+)
+
+// ErrListenUnsupported is error triggered when listener is requested on a connection of a driver other than pgx.
+var ErrListenUnsupported = errors.New("listen is unsupported by driver")
+
+// Listener returns listener of the pgx connection notifications. Every listener holds its own pooled
+// connection, so a single one should be shared by the subscriptions. The listener is stopped by Close
+// or when the registry is closed.
+func (r *Registry) Listener(name string) (_ *Listener, err error) {
+	var conf Config
+	if conf, err = r.Config(name); err != nil {
+		return nil, err
+	}
+
+	if conf.Driver != "pgx" {
+		return nil, fmt.Errorf("%w %s", ErrListenUnsupported, conf.Driver)
+	}
+
+	var l = Listener{
+		registry: r,
+		name:     name,
+		stopped:  make(chan struct{}),
+		subs:     make(map[string][]*Subscription),
+	}
+
+	l.ctx, l.cancel = context.WithCancel(context.Background())
+
+	go func() {
+		select {
+		case <-r.done:
+			l.cancel()
+		case <-l.ctx.Done():
+		}
+	}()
+
+	go l.run(r.acquireListenConn)
+
+	return &l, nil
+}
+
+// Subscribe subscribes to the channel notifications delivered to the channel of the buffer capacity,
+// DefaultListenerBuffer when it is not positive. Slow subscriptions delay delivery to the rest.
+func (l *Listener) Subscribe(channel string, buffer int) *Subscription {
+	if buffer <= 0 {
+		buffer = DefaultListenerBuffer
+	}
+
+	var s = Subscription{
+		listener: l,
+		channel:  channel,
+		c:        make(chan Notification, buffer),
+		done:     make(chan struct{}),
+	}
+
+	s.C = s.c
+
+	l.mux.Lock()
+	l.subs[channel] = append(l.subs[channel], &s)
+	l.changed()
+	l.mux.Unlock()
+
+	return &s
+}
+
+// Close stops the listener and releases its connection.
+func (l *Listener) Close() error {
+	l.cancel()
+	<-l.stopped
+
+	return nil
+}
+
+// Close cancels the subscription, the channel is unlistened when it has no subscriptions left.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		close(s.done)
+
+		var l = s.listener
+
+		l.mux.Lock()
+		defer l.mux.Unlock()
+
+		var subs = l.subs[s.channel]
+		for i, sub := range subs {
+			if sub == s {
+				subs = append(subs[:i:i], subs[i+1:]...)
+				break
+			}
+		}
+
+		if len(subs) == 0 {
+			delete(l.subs, s.channel)
+		} else {
+			l.subs[s.channel] = subs
+		}
+
+		l.changed()
+	})
+}
+
+// changed marks the channels changed and wakes the waiting connection, the mutex must be held.
+func (l *Listener) changed() {
+	l.dirty = true
+	if l.wake != nil {
+		l.wake()
+	}
+}
+
+// run serves the listener connections until the listener is stopped.
+func (l *Listener) run(acquire func(ctx context.Context, name string, fn func(conn listenConn) error) error) {
+	defer close(l.stopped)
+
+	var (
+		delay       = listenerMinReconnectDelay
+		reconnected = false
+	)
+
+	for {
+		var served bool
+		var err = acquire(l.ctx, l.name, func(conn listenConn) error {
+			served = true
+			return l.serve(conn, reconnected)
+		})
+
+		if l.ctx.Err() != nil {
+			return
+		}
+
+		if served {
+			delay, reconnected = listenerMinReconnectDelay, true
+		}
+
+		l.registry.logger.Error("listener connection failed", err, "connection", l.name, "delay", delay)
+
+		var timer = time.NewTimer(delay)
+		select {
+		case <-l.ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if delay *= 2; delay > listenerMaxReconnectDelay {
+			delay = listenerMaxReconnectDelay
+		}
+	}
+}
+
+// serve listens to the subscribed channels on the connection and delivers their notifications until
+// the connection fails or the listener is stopped.
+func (l *Listener) serve(conn listenConn, reconnected bool) error {
+	var listening = make(map[string]bool)
+	for {
+		l.mux.Lock()
+		var wanted = make(map[string]bool, len(l.subs))
+		for channel := range l.subs {
+			wanted[channel] = true
+		}
+
+		l.dirty = false
+		l.mux.Unlock()
+
+		for channel := range wanted {
+			if listening[channel] {
+				continue
+			}
+
+			if err := conn.listen(l.ctx, channel); err != nil {
+				return err
+			}
+
+			listening[channel] = true
+		}
+
+		for channel := range listening {
+			if wanted[channel] {
+				continue
+			}
+
+			if err := conn.unlisten(l.ctx, channel); err != nil {
+				return err
+			}
+
+			delete(listening, channel)
+		}
+
+		if reconnected {
+			l.registry.logger.Info("listener connection re-established", "connection", l.name)
+
+			for channel := range listening {
+				l.deliver(Notification{Channel: channel, Reconnect: true})
+			}
+
+			reconnected = false
+		}
+
+		var waitCtx, cancel = context.WithCancel(l.ctx)
+
+		l.mux.Lock()
+		if l.dirty {
+			l.mux.Unlock()
+			cancel()
+			continue
+		}
+
+		l.wake = cancel
+		l.mux.Unlock()
+
+		var n, err = conn.wait(waitCtx)
+
+		l.mux.Lock()
+		l.wake = nil
+		l.mux.Unlock()
+
+		var woken = waitCtx.Err() != nil
+		cancel()
+
+		switch {
+		case l.ctx.Err() != nil:
+			return l.ctx.Err()
+		case err != nil && woken:
+			continue
+		case err != nil:
+			return err
+		}
+
+		l.deliver(n)
+	}
+}
+
+// deliver sends the notification to every subscription of its channel.
+func (l *Listener) deliver(n Notification) {
+	l.mux.Lock()
+	var subs = append([]*Subscription(nil), l.subs[n.Channel]...)
+	l.mux.Unlock()
+
+	for _, s := range subs {
+		select {
+		case s.c <- n:
+		case <-s.done:
+		case <-l.ctx.Done():
+			return
+		}
+	}
+}
+
+// acquireListenConn invokes fn with pgx connection of the connection master node, the connection is
+// discarded from the pool when fn fails.
+func (r *Registry) acquireListenConn(ctx context.Context, name string, fn func(conn listenConn) error) (err error) {
+	var conn *sql.Conn
+	if conn, err = r.Conn(ctx, name); err != nil {
+		return err
+	}
+
+	defer func() {
+		if closeErr := conn.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	return conn.Raw(func(driverConn interface{}) error {
+		var native, ok = unwrapConn(driverConn).(*stdlib.Conn)
+		if !ok {
+			return fmt.Errorf("unable listen by %T connection : %w", driverConn, ErrListenUnsupported)
+		}
+
+		if err := fn(&pgxListenConn{conn: native.Conn()}); err != nil {
+			return fmt.Errorf("%w : %s", driver.ErrBadConn, err)
+		}
+
+		return nil
+	})
+}
+
+// listen implements listenConn.
+func (c *pgxListenConn) listen(ctx context.Context, channel string) error {
+	var _, err = c.conn.Exec(ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize())
+	return err
+}
+
+// unlisten implements listenConn.
+func (c *pgxListenConn) unlisten(ctx context.Context, channel string) error {
+	var _, err = c.conn.Exec(ctx, "UNLISTEN "+pgx.Identifier{channel}.Sanitize())
+	return err
+}
+
+// wait implements listenConn.
+func (c *pgxListenConn) wait(ctx context.Context) (Notification, error) {
+	var n, err = c.conn.WaitForNotification(ctx)
+	if err != nil {
+		return Notification{}, err
+	}
+
+	return Notification{Channel: n.Channel, Payload: n.Payload, PID: n.PID}, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// LoadData describes the rows loaded by Registry.LoadData. The table, the columns and the options are
+// written to the statement as is, they must be quoted by the caller when needed.
+type LoadData struct {
+	Table string
+
+	// Columns are the loaded columns in order of the fields, every table column by default.
+	Columns []string
+
+	// Options are clauses of the input format, e.g. FIELDS TERMINATED BY ',' IGNORE 1 LINES, the
+	// MySQL default is tab separated fields of newline terminated lines.
+	Options string
+}
+
+// ErrLoadDataUnsupported is error triggered when LOAD DATA is requested on a connection of a driver other than MySQL.
+var ErrLoadDataUnsupported = errors.New("load data is unsupported by driver")
+
+// loadDataReaders is sequence of the reader handler names.
+var loadDataReaders uint64
+
+// LoadData streams the reader into the table of the MySQL connection master node, or its ambient
+// transaction, by LOAD DATA LOCAL INFILE and returns number of the loaded rows. The reader is
+// registered in the driver for the statement only, it is closed by the driver when it implements
+// io.Closer. The server must allow local_infile.
+func (r *Registry) LoadData(ctx context.Context, name string, load LoadData, reader io.Reader) (_ int64, err error) {
+	if load.Table == "" {
+		return 0, errors.New("load data table is required")
+	}
+
+	var conf Config
+	if conf, err = r.Config(name); err != nil {
+		return 0, err
+	}
+
+	if conf.Driver != "mysql" {
+		return 0, fmt.Errorf("%w %s", ErrLoadDataUnsupported, conf.Driver)
+	}
+
+	var db Executor
+	if db, err = r.ExecutorFromContext(ctx, name); err != nil {
+		return 0, err
+	}
+
+	var handler = "gozix_sql_" + name + "_" + strconv.FormatUint(atomic.AddUint64(&loadDataReaders, 1), 10)
+
+	mysql.RegisterReaderHandler(handler, func() io.Reader {
+		return reader
+	})
+
+	defer mysql.DeregisterReaderHandler(handler)
+
+	var result sql.Result
+	if result, err = db.ExecContext(ctx, load.statement(handler)); err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
+// statement returns LOAD DATA statement of the reader handler.
+func (l LoadData) statement(handler string) string {
+	var query strings.Builder
+	query.WriteString("LOAD DATA LOCAL INFILE 'Reader::" + handler + "' INTO TABLE " + l.Table)
+
+	if l.Options != "" {
+		query.WriteString(" " + l.Options)
+	}
+
+	if len(l.Columns) > 0 {
+		query.WriteString(" (" + strings.Join(l.Columns, ", ") + ")")
+	}
+
+	return query.String()
+}
@@ -0,0 +1,43 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import "errors"
+
+type (
+	// Logger is the registry logger, keyvals are alternating key and value pairs.
+	Logger interface {
+		Info(msg string, keyvals ...interface{})
+		Error(msg string, err error, keyvals ...interface{})
+	}
+
+	// nopLogger is Logger discarding every message.
+	nopLogger struct{}
+)
+
+// WithLogger option sets the registry logger, nothing is logged by default.
+func WithLogger(logger Logger) Option {
+	return optionFunc(func(r *Registry) {
+		r.logger = logger
+	})
+}
+
+// Info implements Logger.
+func (nopLogger) Info(string, ...interface{}) {}
+
+// Error implements Logger.
+func (nopLogger) Error(string, error, ...interface{}) {}
+
+// connectionKeyvals returns keyvals identifying the connection and the failed node of the error.
+func connectionKeyvals(name string, err error, keyvals ...interface{}) []interface{} {
+	var result = append([]interface{}{"connection", name}, keyvals...)
+
+	var connErr *ConnectionError
+	if errors.As(err, &connErr) && connErr.Node >= 0 {
+		result = append(result, "node", connErr.Node)
+	}
+
+	return result
+}
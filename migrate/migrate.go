@@ -0,0 +1,359 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+// Package migrate provide SQL migrations for the sql registry connections.
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	gzSQL "github.com/gozix/sql/v3"
+	"github.com/iqoption/nap"
+)
+
+// DefaultTable is default name of the version tracking table.
+const DefaultTable = "schema_migrations"
+
+type (
+	// Migration is a single schema change.
+	Migration struct {
+		Version int64
+		Name    string
+		Up      string
+		Down    string
+	}
+
+	// Option interface.
+	Option interface {
+		apply(m *Migrator)
+	}
+
+	// Migrator applies migrations to the database.
+	Migrator struct {
+		db         *sql.DB
+		driver     string
+		table      string
+		migrations []Migration
+	}
+
+	// optionFunc wraps a func, so it satisfies the Option interface.
+	optionFunc func(m *Migrator)
+)
+
+var (
+	// ErrNoDownMigration is error triggered when migration to roll back has no down statements.
+	ErrNoDownMigration = errors.New("migration has no down statements")
+
+	// ErrDuplicateVersion is error triggered when two migrations have the same version.
+	ErrDuplicateVersion = errors.New("duplicate migration version")
+
+	// fileRegexp matches migration file name, e.g. 0001_create_users.up.sql.
+	fileRegexp = regexp.MustCompile(`^(\d+)_(.+)\.(up|down)\.sql$`)
+)
+
+// Table option sets name of the version tracking table.
+func Table(name string) Option {
+	return optionFunc(func(m *Migrator) {
+		m.table = name
+	})
+}
+
+// Load reads migrations from the directory of the file system, e.g. embed.FS or os.DirFS. Files
+// must be named as <version>_<name>.up.sql and <version>_<name>.down.sql, down file is optional.
+// A file may contain several statements if the driver supports it, e.g. mysql requires
+// multiStatements=true DSN parameter.
+func Load(fsys fs.FS, dir string) (_ []Migration, err error) {
+	var entries []fs.DirEntry
+	if entries, err = fs.ReadDir(fsys, dir); err != nil {
+		return nil, err
+	}
+
+	var byVersion = make(map[int64]*Migration, len(entries))
+	for _, entry := range entries {
+		var match = fileRegexp.FindStringSubmatch(entry.Name())
+		if entry.IsDir() || match == nil {
+			continue
+		}
+
+		var version int64
+		if version, err = strconv.ParseInt(match[1], 10, 64); err != nil {
+			return nil, fmt.Errorf("invalid migration version %s : %w", entry.Name(), err)
+		}
+
+		var data []byte
+		if data, err = fs.ReadFile(fsys, path.Join(dir, entry.Name())); err != nil {
+			return nil, err
+		}
+
+		var m, ok = byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("%w %d", ErrDuplicateVersion, version)
+		}
+
+		if match[3] == "up" {
+			m.Up = string(data)
+		} else {
+			m.Down = string(data)
+		}
+	}
+
+	var migrations = make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version < migrations[j].Version
+	})
+
+	return migrations, nil
+}
+
+// New is migrator constructor, the driver name selects the dialect and the locking strategy.
+func New(db *sql.DB, driver string, migrations []Migration, options ...Option) *Migrator {
+	var m = Migrator{
+		db:         db,
+		driver:     driver,
+		table:      DefaultTable,
+		migrations: append([]Migration(nil), migrations...),
+	}
+
+	sort.Slice(m.migrations, func(i, j int) bool {
+		return m.migrations[i].Version < m.migrations[j].Version
+	})
+
+	for _, option := range options {
+		option.apply(&m)
+	}
+
+	return &m
+}
+
+// NewWithRegistry returns migrator of the registry connection master node.
+func NewWithRegistry(
+	ctx context.Context, registry *gzSQL.Registry, name string, migrations []Migration, options ...Option,
+) (_ *Migrator, err error) {
+	var driver string
+	if driver, err = registry.DriverWithName(name); err != nil {
+		return nil, err
+	}
+
+	var db *nap.DB
+	if db, err = registry.ConnectionWithNameContext(ctx, name); err != nil {
+		return nil, err
+	}
+
+	return New(db.Master(), driver, migrations, options...), nil
+}
+
+// Version returns the latest applied migration version, zero means nothing is applied.
+func (m *Migrator) Version(ctx context.Context) (version int64, err error) {
+	err = m.locked(ctx, func(conn *sql.Conn, applied map[int64]bool) error {
+		for v := range applied {
+			if v > version {
+				version = v
+			}
+		}
+
+		return nil
+	})
+
+	return version, err
+}
+
+// Up applies every pending migration in version order and returns the number of applied ones.
+func (m *Migrator) Up(ctx context.Context) (n int, err error) {
+	err = m.locked(ctx, func(conn *sql.Conn, applied map[int64]bool) error {
+		for _, migration := range m.migrations {
+			if applied[migration.Version] {
+				continue
+			}
+
+			var insert = fmt.Sprintf(
+				"INSERT INTO %s (version, name) VALUES (%s, %s)", m.table, m.placeholder(1), m.placeholder(2),
+			)
+
+			if err = m.exec(ctx, conn, migration.Up, insert, migration.Version, migration.Name); err != nil {
+				return fmt.Errorf("unable apply migration %d_%s : %w", migration.Version, migration.Name, err)
+			}
+
+			n++
+		}
+
+		return nil
+	})
+
+	return n, err
+}
+
+// Down rolls back up to steps latest applied migrations and returns the number of rolled back ones.
+func (m *Migrator) Down(ctx context.Context, steps int) (n int, err error) {
+	err = m.locked(ctx, func(conn *sql.Conn, applied map[int64]bool) error {
+		for i := len(m.migrations) - 1; i >= 0 && n < steps; i-- {
+			var migration = m.migrations[i]
+			if !applied[migration.Version] {
+				continue
+			}
+
+			if len(strings.TrimSpace(migration.Down)) == 0 {
+				return fmt.Errorf("%w %d_%s", ErrNoDownMigration, migration.Version, migration.Name)
+			}
+
+			var remove = fmt.Sprintf("DELETE FROM %s WHERE version = %s", m.table, m.placeholder(1))
+			if err = m.exec(ctx, conn, migration.Down, remove, migration.Version); err != nil {
+				return fmt.Errorf("unable roll back migration %d_%s : %w", migration.Version, migration.Name, err)
+			}
+
+			n++
+		}
+
+		return nil
+	})
+
+	return n, err
+}
+
+// locked runs fn holding the cross-instance migration lock on a dedicated session.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn, applied map[int64]bool) error) (err error) {
+	var conn *sql.Conn
+	if conn, err = m.db.Conn(ctx); err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	var unlock func() error
+	if unlock, err = m.lock(ctx, conn); err != nil {
+		return fmt.Errorf("unable acquire migration lock : %w", err)
+	}
+
+	defer func() {
+		if uErr := unlock(); uErr != nil && err == nil {
+			err = fmt.Errorf("unable release migration lock : %w", uErr)
+		}
+	}()
+
+	var create = fmt.Sprintf(
+		"CREATE TABLE IF NOT EXISTS %s (version BIGINT PRIMARY KEY, name VARCHAR(255) NOT NULL, "+
+			"applied_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)",
+		m.table,
+	)
+
+	if _, err = conn.ExecContext(ctx, create); err != nil {
+		return fmt.Errorf("unable create migrations table : %w", err)
+	}
+
+	var applied map[int64]bool
+	if applied, err = m.applied(ctx, conn); err != nil {
+		return err
+	}
+
+	return fn(conn, applied)
+}
+
+// lock acquires the driver specific session lock and returns the release function.
+func (m *Migrator) lock(ctx context.Context, conn *sql.Conn) (_ func() error, err error) {
+	var key = int64(crc32.ChecksumIEEE([]byte(m.table)))
+
+	switch m.driver {
+	case "postgres", "pgx", "cloudsqlpostgres":
+		if _, err = conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", key); err != nil {
+			return nil, err
+		}
+
+		return func() error {
+			var _, err = conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", key)
+			return err
+		}, nil
+	case "mysql":
+		var name = "gozix_sql_migrate_" + m.table
+		var acquired sql.NullInt64
+		if err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, -1)", name).Scan(&acquired); err != nil {
+			return nil, err
+		}
+
+		if acquired.Int64 != 1 {
+			return nil, fmt.Errorf("lock %s is not acquired", name)
+		}
+
+		return func() error {
+			var _, err = conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", name)
+			return err
+		}, nil
+	default:
+		return func() error { return nil }, nil
+	}
+}
+
+// applied returns versions of applied migrations.
+func (m *Migrator) applied(ctx context.Context, conn *sql.Conn) (_ map[int64]bool, err error) {
+	var rows *sql.Rows
+	if rows, err = conn.QueryContext(ctx, fmt.Sprintf("SELECT version FROM %s", m.table)); err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var applied = make(map[int64]bool)
+	for rows.Next() {
+		var version int64
+		if err = rows.Scan(&version); err != nil {
+			return nil, err
+		}
+
+		applied[version] = true
+	}
+
+	return applied, rows.Err()
+}
+
+// exec runs the migration statements and updates the version table in a single transaction.
+func (m *Migrator) exec(ctx context.Context, conn *sql.Conn, statements, track string, args ...interface{}) (err error) {
+	var tx *sql.Tx
+	if tx, err = conn.BeginTx(ctx, nil); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, statements); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, track, args...); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// placeholder returns the driver bind placeholder of the n-th argument.
+func (m *Migrator) placeholder(n int) string {
+	switch m.driver {
+	case "postgres", "pgx", "cloudsqlpostgres":
+		return "$" + strconv.Itoa(n)
+	default:
+		return "?"
+	}
+}
+
+// apply implements Option.
+func (f optionFunc) apply(m *Migrator) {
+	f(m)
+}
@@ -0,0 +1,149 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+)
+
+// DefaultHealthMonitorInterval is interval of the health monitor started for the connection that
+// tolerates partial failure without the health monitor configured.
+const DefaultHealthMonitorInterval = 5 * time.Second
+
+type (
+	// HealthMonitor is slave nodes health monitor configuration.
+	HealthMonitor struct {
+		// Interval is interval of the slave nodes pinging, zero disables the monitor.
+		Interval time.Duration `json:"interval"`
+
+		// Timeout is timeout of the single ping, zero means the interval.
+		Timeout time.Duration `json:"timeout"`
+
+		// FailureThreshold is number of consecutive failed pings removing the node from the read
+		// rotation, zero means 1.
+		FailureThreshold int `json:"failure_threshold"`
+
+		// SuccessThreshold is number of consecutive successful pings returning the removed node to
+		// the read rotation, zero means 1.
+		SuccessThreshold int `json:"success_threshold"`
+	}
+
+	// monitor pings the slave nodes in background and tracks which of them are healthy.
+	monitor struct {
+		mux    sync.Mutex
+		conf   HealthMonitor
+		nodes  []*sql.DB
+		health []nodeHealth
+		evict  evictFunc
+	}
+
+	// nodeHealth is health state of the node.
+	nodeHealth struct {
+		failures  int
+		successes int
+		evicted   bool
+	}
+)
+
+// newMonitor returns monitor of the connection nodes or nil if the monitor is disabled.
+func newMonitor(conf HealthMonitor, nodes []*sql.DB, evict evictFunc) *monitor {
+	if conf.Interval <= 0 || len(nodes) < 2 {
+		return nil
+	}
+
+	if conf.Timeout <= 0 {
+		conf.Timeout = conf.Interval
+	}
+
+	if conf.FailureThreshold <= 0 {
+		conf.FailureThreshold = 1
+	}
+
+	if conf.SuccessThreshold <= 0 {
+		conf.SuccessThreshold = 1
+	}
+
+	return &monitor{
+		conf:   conf,
+		nodes:  nodes,
+		health: make([]nodeHealth, len(nodes)),
+		evict:  evict,
+	}
+}
+
+// available reports whether the node is in the read rotation.
+func (m *monitor) available(idx int) bool {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	return !m.health[idx].evicted
+}
+
+// run pings the slave nodes every interval until done is closed.
+func (m *monitor) run(done <-chan struct{}) {
+	var ticker = time.NewTicker(m.conf.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+
+		for idx := 1; idx < len(m.nodes); idx++ {
+			var ctx, cancel = context.WithTimeout(context.Background(), m.conf.Timeout)
+			var err = m.nodes[idx].PingContext(ctx)
+			cancel()
+
+			m.report(idx, err)
+		}
+	}
+}
+
+// fail removes the node from the read rotation until it passes the success threshold.
+func (m *monitor) fail(idx int, err error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	var h = &m.health[idx]
+	h.successes = 0
+	h.failures = m.conf.FailureThreshold
+
+	if !h.evicted {
+		h.evicted = true
+		m.evict(idx, true, err)
+	}
+}
+
+// report accounts the ping result of the node.
+func (m *monitor) report(idx int, err error) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	var (
+		h       = &m.health[idx]
+		evicted = h.evicted
+	)
+
+	if err != nil {
+		h.successes = 0
+		if h.failures++; h.failures >= m.conf.FailureThreshold {
+			h.evicted = true
+		}
+	} else {
+		h.failures = 0
+		if h.successes++; h.successes >= m.conf.SuccessThreshold {
+			h.evicted = false
+		}
+	}
+
+	if h.evicted != evicted {
+		m.evict(idx, h.evicted, err)
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// BindNamed replaces :name parameters of the query by the driver placeholders, see placeholder, and
+// returns the arguments bound from the struct fields, matched like QueryAll
+// columns, or from the map[string]interface{} keys. String literals, quoted identifiers, comments and
+// Postgres :: casts are left untouched.
+func BindNamed(driverName, query string, arg interface{}) (_ string, args []interface{}, err error) {
+	var lookup func(name string) (interface{}, bool)
+	if lookup, err = namedLookup(arg); err != nil {
+		return "", nil, err
+	}
+
+	var (
+		number  = isNumberedDriver(driverName)
+		indexes = make(map[string]int)
+		out     strings.Builder
+	)
+
+	out.Grow(len(query))
+
+	for i := 0; i < len(query); {
+		var end = skipQuoted(query, i)
+		if end > i {
+			out.WriteString(query[i:end])
+			i = end
+			continue
+		}
+
+		if query[i] != ':' {
+			out.WriteByte(query[i])
+			i++
+			continue
+		}
+
+		if i+1 < len(query) && query[i+1] == ':' {
+			out.WriteString("::")
+			i += 2
+			continue
+		}
+
+		end = i + 1
+		for end < len(query) && isNameByte(query[end], end == i+1) {
+			end++
+		}
+
+		if end == i+1 {
+			out.WriteByte(':')
+			i++
+			continue
+		}
+
+		var name = query[i+1 : end]
+		i = end
+
+		if idx, ok := indexes[name]; ok && number {
+			out.WriteString(placeholder(driverName, idx))
+			continue
+		}
+
+		var value, ok = lookup(name)
+		if !ok {
+			return "", nil, fmt.Errorf("missing value of named parameter %s", name)
+		}
+
+		args = append(args, value)
+		indexes[name] = len(args)
+		out.WriteString(placeholder(driverName, len(args)))
+	}
+
+	return out.String(), args, nil
+}
+
+// NamedExec executes the statement with named parameters on the connection, or on its ambient
+// transaction, see BindNamed.
+func (r *Registry) NamedExec(ctx context.Context, name, query string, arg interface{}) (_ sql.Result, err error) {
+	var (
+		db   Executor
+		args []interface{}
+	)
+
+	if db, query, args, err = r.bindNamed(ctx, name, query, arg); err != nil {
+		return nil, err
+	}
+
+	return db.ExecContext(ctx, query, args...)
+}
+
+// NamedQuery executes the query with named parameters on the connection, or on its ambient
+// transaction, see BindNamed.
+func (r *Registry) NamedQuery(ctx context.Context, name, query string, arg interface{}) (_ *sql.Rows, err error) {
+	var (
+		db   Executor
+		args []interface{}
+	)
+
+	if db, query, args, err = r.bindNamed(ctx, name, query, arg); err != nil {
+		return nil, err
+	}
+
+	return db.QueryContext(ctx, query, args...)
+}
+
+// bindNamed returns executor of the connection and the query bound to its driver placeholders.
+func (r *Registry) bindNamed(ctx context.Context, name, query string, arg interface{}) (
+	db Executor, _ string, args []interface{}, err error,
+) {
+	var conf Config
+	if conf, err = r.Config(name); err != nil {
+		return nil, "", nil, err
+	}
+
+	if query, args, err = BindNamed(conf.Driver, query, arg); err != nil {
+		return nil, "", nil, err
+	}
+
+	if db, err = r.ExecutorFromContext(ctx, name); err != nil {
+		return nil, "", nil, err
+	}
+
+	return db, query, args, nil
+}
+
+// namedLookup returns function looking up the named parameter values of the struct or the map.
+func namedLookup(arg interface{}) (func(name string) (interface{}, bool), error) {
+	if values, ok := arg.(map[string]interface{}); ok {
+		return func(name string) (interface{}, bool) {
+			var value, ok = values[name]
+			return value, ok
+		}, nil
+	}
+
+	var value = reflect.ValueOf(arg)
+	for value.Kind() == reflect.Ptr && !value.IsNil() {
+		value = value.Elem()
+	}
+
+	if value.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("unable bind named parameters of %T, struct or map[string]interface{} expected", arg)
+	}
+
+	var fields = structFields(value.Type())
+
+	return func(name string) (interface{}, bool) {
+		var index, ok = fields[strings.ToLower(name)]
+		if !ok {
+			return nil, false
+		}
+
+		return value.FieldByIndex(index).Interface(), true
+	}, nil
+}
+
+// placeholder returns the driver placeholder of the n-th argument, $1 on Postgres, @p1 on SQL Server
+// and ? on other drivers.
+func placeholder(driverName string, n int) string {
+	switch driverName {
+	case "postgres", "pgx", "cloudsqlpostgres":
+		return "$" + strconv.Itoa(n)
+	case "sqlserver", "mssql":
+		return "@p" + strconv.Itoa(n)
+	default:
+		return "?"
+	}
+}
+
+// isNumberedDriver reports whether the driver placeholders are numbered, so they may be repeated.
+func isNumberedDriver(driverName string) bool {
+	return placeholder(driverName, 1) != "?"
+}
+
+// isNameByte reports whether the byte belongs to the parameter name.
+func isNameByte(b byte, first bool) bool {
+	switch {
+	case b == '_', b >= 'a' && b <= 'z', b >= 'A' && b <= 'Z':
+		return true
+	default:
+		return !first && b >= '0' && b <= '9'
+	}
+}
+
+// skipQuoted returns end of the string literal, the quoted identifier or the comment starting at i,
+// i itself when there is none there.
+func skipQuoted(query string, i int) int {
+	switch {
+	case query[i] == '\'' || query[i] == '"' || query[i] == '`':
+		var quote = query[i]
+		for j := i + 1; j < len(query); j++ {
+			if query[j] != quote {
+				continue
+			}
+
+			// doubled quote is escaped one
+			if j+1 < len(query) && query[j+1] == quote {
+				j++
+				continue
+			}
+
+			return j + 1
+		}
+
+		return len(query)
+	case strings.HasPrefix(query[i:], "--"):
+		if end := strings.IndexByte(query[i:], '\n'); end >= 0 {
+			return i + end + 1
+		}
+
+		return len(query)
+	case strings.HasPrefix(query[i:], "/*"):
+		if end := strings.Index(query[i+2:], "*/"); end >= 0 {
+			return i + 2 + end + 2
+		}
+
+		return len(query)
+	default:
+		return i
+	}
+}
@@ -0,0 +1,233 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cast"
+)
+
+type (
+	// Node is connection node configuration. It is unmarshalled either from a DSN string or from
+	// an object. Zero pool settings are inherited from the connection, negative values mean
+	// no limit for the node regardless of the connection settings. Weight is the slave node share
+	// of reads under the weighted read policy, zero means the connection ReadWeights entry, whose
+	// zero means the default weight 1 as well, so a node isn't excluded by zero weights. Role
+	// is RoleMaster or RoleReplica, when roles are set the master node may be listed anywhere,
+	// otherwise the first node is master.
+	Node struct {
+		DSN             string        `json:"dsn"`
+		Role            string        `json:"role"`
+		Weight          int           `json:"weight"`
+		MaxOpenConns    int           `json:"max_open_conns"`
+		MaxIdleConns    int           `json:"max_idle_conns"`
+		ConnMaxLifetime time.Duration `json:"conn_max_lifetime"`
+		ConnMaxIdleTime time.Duration `json:"conn_max_idle_time"`
+	}
+
+	// Nodes are connection nodes configurations, the first one is master. They are set by
+	// Config.NodeConfigs, which takes precedence over the DSN list of Config.Nodes.
+	Nodes []Node
+)
+
+// NewNodes returns nodes configurations inheriting the connection pool settings.
+func NewNodes(dsn ...string) Nodes {
+	var nodes = make(Nodes, 0, len(dsn))
+	for _, value := range dsn {
+		nodes = append(nodes, Node{DSN: value})
+	}
+
+	return nodes
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (n *Node) UnmarshalJSON(data []byte) error {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte(`"`)) {
+		*n = Node{}
+		return json.Unmarshal(data, &n.DSN)
+	}
+
+	var _, err = unmarshalFields(data, n)
+	return err
+}
+
+// DSNs returns data source names of the nodes.
+func (n Nodes) DSNs() []string {
+	var dsn = make([]string, 0, len(n))
+	for _, node := range n {
+		dsn = append(dsn, node.DSN)
+	}
+
+	return dsn
+}
+
+// nodes returns the node configurations, NodeConfigs take precedence over Nodes.
+func (c Config) nodes() Nodes {
+	if len(c.NodeConfigs) > 0 {
+		return c.NodeConfigs
+	}
+
+	return NewNodes(c.Nodes...)
+}
+
+// ordered returns the configuration whose NodeConfigs list every node and whose master node is the
+// first one, the ReadWeights entries follow their nodes. The nodes keep their order when node roles
+// are not set.
+func (c Config) ordered() Config {
+	c.NodeConfigs = c.nodes()
+
+	var master = -1
+	for i, node := range c.NodeConfigs {
+		if node.Role == RoleMaster {
+			master = i
+			break
+		}
+	}
+
+	if master <= 0 {
+		return c
+	}
+
+	var nodes = make(Nodes, 0, len(c.NodeConfigs))
+	nodes = append(nodes, c.NodeConfigs[master])
+	nodes = append(nodes, c.NodeConfigs[:master]...)
+	c.NodeConfigs = append(nodes, c.NodeConfigs[master+1:]...)
+
+	if master < len(c.ReadWeights) {
+		var weights = make([]int, 0, len(c.ReadWeights))
+		weights = append(weights, c.ReadWeights[master])
+		weights = append(weights, c.ReadWeights[:master]...)
+		c.ReadWeights = append(weights, c.ReadWeights[master+1:]...)
+	}
+
+	return c
+}
+
+// validateRoles checks that either no node role is set or there is exactly one master and the rest
+// are replicas.
+func (n Nodes) validateRoles() error {
+	var set, masters int
+	for i, node := range n {
+		switch node.Role {
+		case "":
+			continue
+		case RoleMaster:
+			masters++
+		case RoleReplica, RoleSlave:
+		default:
+			return fmt.Errorf("unknown role %q of node %d", node.Role, i)
+		}
+
+		set++
+	}
+
+	switch {
+	case set == 0:
+		return nil
+	case set < len(n):
+		return errors.New("role must be set for every node")
+	case masters != 1:
+		return fmt.Errorf("exactly one master node is required, got %d", masters)
+	default:
+		return nil
+	}
+}
+
+// apply applies the node pool settings inherited from the connection configuration.
+func (n Node) apply(db *sql.DB, conf Config) {
+	db.SetMaxOpenConns(inheritInt(n.MaxOpenConns, conf.MaxOpenConns))
+	db.SetMaxIdleConns(inheritInt(n.MaxIdleConns, conf.MaxIdleConns))
+	db.SetConnMaxLifetime(inheritDuration(n.ConnMaxLifetime, conf.ConnMaxLifetime))
+	db.SetConnMaxIdleTime(inheritDuration(n.ConnMaxIdleTime, conf.ConnMaxIdleTime))
+}
+
+// nodesFromValue converts viper value to the nodes configurations.
+func nodesFromValue(value interface{}) (_ Nodes, err error) {
+	var items []interface{}
+	if items, err = cast.ToSliceE(value); err != nil {
+		var dsn []string
+		if dsn, err = cast.ToStringSliceE(value); err != nil {
+			return nil, err
+		}
+
+		return NewNodes(dsn...), nil
+	}
+
+	var nodes = make(Nodes, 0, len(items))
+	for _, item := range items {
+		var node Node
+		if node, err = nodeFromValue(item); err != nil {
+			return nil, err
+		}
+
+		nodes = append(nodes, node)
+	}
+
+	return nodes, nil
+}
+
+// nodeFromValue converts viper value to the node configuration.
+func nodeFromValue(value interface{}) (node Node, err error) {
+	if dsn, ok := value.(string); ok {
+		return Node{DSN: dsn}, nil
+	}
+
+	var m map[string]interface{}
+	if m, err = cast.ToStringMapE(value); err != nil {
+		return node, fmt.Errorf("invalid node value : %w", err)
+	}
+
+	if node.DSN, err = cast.ToStringE(m["dsn"]); err != nil {
+		return node, fmt.Errorf("invalid node dsn : %w", err)
+	}
+
+	if node.Role, err = cast.ToStringE(m["role"]); err != nil {
+		return node, fmt.Errorf("invalid node role : %w", err)
+	}
+
+	if node.Weight, err = cast.ToIntE(m["weight"]); err != nil {
+		return node, fmt.Errorf("invalid node weight : %w", err)
+	}
+
+	if node.MaxOpenConns, err = cast.ToIntE(m["max_open_conns"]); err != nil {
+		return node, fmt.Errorf("invalid node max_open_conns : %w", err)
+	}
+
+	if node.MaxIdleConns, err = cast.ToIntE(m["max_idle_conns"]); err != nil {
+		return node, fmt.Errorf("invalid node max_idle_conns : %w", err)
+	}
+
+	if node.ConnMaxLifetime, err = cast.ToDurationE(m["conn_max_lifetime"]); err != nil {
+		return node, fmt.Errorf("invalid node conn_max_lifetime : %w", err)
+	}
+
+	if node.ConnMaxIdleTime, err = cast.ToDurationE(m["conn_max_idle_time"]); err != nil {
+		return node, fmt.Errorf("invalid node conn_max_idle_time : %w", err)
+	}
+
+	return node, nil
+}
+
+func inheritInt(value, inherited int) int {
+	if value == 0 {
+		return inherited
+	}
+
+	return value
+}
+
+func inheritDuration(value, inherited time.Duration) time.Duration {
+	if value == 0 {
+		return inherited
+	}
+
+	return value
+}
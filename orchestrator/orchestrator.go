@@ -0,0 +1,128 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+// Package orchestrator provide Orchestrator cluster topology discovery of the sql registry nodes.
+package orchestrator
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/gozix/sql/v3"
+)
+
+type (
+	// Config is Orchestrator topology configuration.
+	Config struct {
+		// Address is Orchestrator API address, e.g. http://orchestrator:3000.
+		Address string
+
+		// Cluster is cluster alias or name of any of its instances.
+		Cluster string
+
+		// Username and Password are HTTP basic authentication credentials, optional.
+		Username string
+		Password string
+
+		// Client is HTTP client, http.DefaultClient by default.
+		Client *http.Client
+	}
+
+	// Topology discovers the cluster master and its healthy replicas by the Orchestrator API.
+	Topology struct {
+		conf Config
+	}
+
+	// instanceKey is Orchestrator instance key.
+	instanceKey struct {
+		Hostname string `json:"Hostname"`
+		Port     int    `json:"Port"`
+	}
+
+	// instance is Orchestrator instance.
+	instance struct {
+		Key              instanceKey `json:"Key"`
+		MasterKey        instanceKey `json:"MasterKey"`
+		IsLastCheckValid bool        `json:"IsLastCheckValid"`
+		IsDowntimed      bool        `json:"IsDowntimed"`
+	}
+)
+
+// Topology implements sql.TopologyDiscoverer.
+var _ sql.TopologyDiscoverer = (*Topology)(nil)
+
+// NewTopology is topology constructor.
+func NewTopology(conf Config) *Topology {
+	if conf.Client == nil {
+		conf.Client = http.DefaultClient
+	}
+
+	return &Topology{conf: conf}
+}
+
+// DiscoverTopology implements sql.TopologyDiscoverer. Replicas are the valid instances replicating
+// from the master directly and not downtimed.
+func (t *Topology) DiscoverTopology(ctx context.Context) (primary string, replicas []string, err error) {
+	var master instance
+	if err = t.get(ctx, "master/"+url.PathEscape(t.conf.Cluster), &master); err != nil {
+		return "", nil, err
+	}
+
+	var instances []instance
+	if err = t.get(ctx, "cluster/"+url.PathEscape(t.conf.Cluster), &instances); err != nil {
+		return "", nil, err
+	}
+
+	for _, i := range instances {
+		if i.MasterKey == master.Key && i.IsLastCheckValid && !i.IsDowntimed {
+			replicas = append(replicas, i.Key.endpoint())
+		}
+	}
+
+	return master.Key.endpoint(), replicas, nil
+}
+
+// get requests the API path to the value.
+func (t *Topology) get(ctx context.Context, path string, value interface{}) (err error) {
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(t.conf.Address, "/")+"/api/"+path, nil); err != nil {
+		return err
+	}
+
+	if len(t.conf.Username) > 0 {
+		req.SetBasicAuth(t.conf.Username, t.conf.Password)
+	}
+
+	var resp *http.Response
+	if resp, err = t.conf.Client.Do(req); err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return fmt.Errorf("orchestrator responded %d", resp.StatusCode)
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(value); err != nil {
+		return fmt.Errorf("unable decode orchestrator response : %w", err)
+	}
+
+	return nil
+}
+
+// endpoint returns host:port of the instance, it is empty for the zero key.
+func (k instanceKey) endpoint() string {
+	if k.Hostname == "" {
+		return ""
+	}
+
+	return net.JoinHostPort(k.Hostname, strconv.Itoa(k.Port))
+}
@@ -0,0 +1,169 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/global"
+	"go.opentelemetry.io/otel/metric/instrument"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+)
+
+type (
+	// otelMetrics records the pool gauges and the statements latency via OpenTelemetry metrics API.
+	otelMetrics struct {
+		meter metric.Meter
+		err   error
+
+		duration          instrument.Float64Histogram
+		usage             instrument.Int64ObservableUpDownCounter
+		max               instrument.Int64ObservableUpDownCounter
+		waitCount         instrument.Int64ObservableCounter
+		waitTime          instrument.Float64ObservableCounter
+		maxIdleClosed     instrument.Int64ObservableCounter
+		maxIdleTimeClosed instrument.Int64ObservableCounter
+		maxLifetimeClosed instrument.Int64ObservableCounter
+	}
+)
+
+// Attribute keys of the registry metrics.
+var (
+	attrState = attribute.Key("state")
+	attrError = attribute.Key("error")
+)
+
+// WithOTelMetrics option publishes pool statistics of every opened node and latency histogram of the
+// executed statements in milliseconds via OpenTelemetry metrics API, so they are exported by the
+// provider readers, e.g. to OTLP collector. Nil provider means the global meter provider.
+func WithOTelMetrics(provider metric.MeterProvider) Option {
+	return optionFunc(func(r *Registry) {
+		if provider == nil {
+			provider = global.MeterProvider()
+		}
+
+		r.otelMetrics = newOTelMetrics(provider.Meter(tracerName))
+		r.chain = append(r.chain, r.otelMetrics)
+	})
+}
+
+// newOTelMetrics returns the metrics of the meter, the instruments creation error is kept to be
+// reported by the registry constructor.
+func newOTelMetrics(meter metric.Meter) *otelMetrics {
+	var m = otelMetrics{meter: meter}
+
+	m.duration, m.err = meter.Float64Histogram(
+		"db.client.operation.duration",
+		instrument.WithDescription("The statement execution latency"),
+		instrument.WithUnit("ms"),
+	)
+
+	m.usage = m.int64UpDownCounter("db.client.connections.usage", "The number of connections by state")
+	m.max = m.int64UpDownCounter("db.client.connections.max", "Maximum number of open connections")
+	m.waitCount = m.int64Counter("db.client.connections.wait_count", "The total number of connections waited for")
+	m.maxIdleClosed = m.int64Counter(
+		"db.client.connections.max_idle_closed", "The total number of connections closed due to max idle connections",
+	)
+	m.maxIdleTimeClosed = m.int64Counter(
+		"db.client.connections.max_idle_time_closed", "The total number of connections closed due to max idle time",
+	)
+	m.maxLifetimeClosed = m.int64Counter(
+		"db.client.connections.max_lifetime_closed", "The total number of connections closed due to max lifetime",
+	)
+
+	if m.err == nil {
+		m.waitTime, m.err = meter.Float64ObservableCounter(
+			"db.client.connections.wait_time",
+			instrument.WithDescription("The total time blocked waiting for a new connection"),
+			instrument.WithUnit("ms"),
+		)
+	}
+
+	return &m
+}
+
+// int64Counter returns the observable counter, nothing is created after the first error.
+func (m *otelMetrics) int64Counter(name, description string) (c instrument.Int64ObservableCounter) {
+	if m.err == nil {
+		c, m.err = m.meter.Int64ObservableCounter(name, instrument.WithDescription(description))
+	}
+
+	return c
+}
+
+// int64UpDownCounter returns the observable up-down counter, nothing is created after the first error.
+func (m *otelMetrics) int64UpDownCounter(name, description string) (c instrument.Int64ObservableUpDownCounter) {
+	if m.err == nil {
+		c, m.err = m.meter.Int64ObservableUpDownCounter(name, instrument.WithDescription(description))
+	}
+
+	return c
+}
+
+// register registers the pool statistics callback until done is closed.
+func (m *otelMetrics) register(r *Registry, done <-chan struct{}) error {
+	if m.err != nil {
+		return m.err
+	}
+
+	var registration, err = m.meter.RegisterCallback(
+		func(_ context.Context, o metric.Observer) error {
+			m.observe(r, o)
+			return nil
+		},
+		m.usage, m.max, m.waitCount, m.waitTime, m.maxIdleClosed, m.maxIdleTimeClosed, m.maxLifetimeClosed,
+	)
+
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		<-done
+		_ = registration.Unregister()
+	}()
+
+	return nil
+}
+
+// observe records the current state of the registry pools.
+func (m *otelMetrics) observe(r *Registry, o metric.Observer) {
+	for name, stats := range r.Stats() {
+		for i, node := range stats.Nodes {
+			var attrs = []attribute.KeyValue{attrConnection.String(name), attrNode.Int(i), attrRole.String(node.Role)}
+
+			o.ObserveInt64(m.usage, int64(node.Idle), append(attrs, attrState.String("idle"))...)
+			o.ObserveInt64(m.usage, int64(node.InUse), append(attrs, attrState.String("used"))...)
+			o.ObserveInt64(m.max, int64(node.MaxOpenConnections), attrs...)
+			o.ObserveInt64(m.waitCount, node.WaitCount, attrs...)
+			o.ObserveFloat64(m.waitTime, durationMillis(node.WaitDuration), attrs...)
+			o.ObserveInt64(m.maxIdleClosed, node.MaxIdleClosed, attrs...)
+			o.ObserveInt64(m.maxIdleTimeClosed, node.MaxIdleTimeClosed, attrs...)
+			o.ObserveInt64(m.maxLifetimeClosed, node.MaxLifetimeClosed, attrs...)
+		}
+	}
+}
+
+func (m *otelMetrics) before(ctx context.Context, _ *QueryEvent) (context.Context, error) {
+	return ctx, nil
+}
+
+func (m *otelMetrics) after(ctx context.Context, e *QueryEvent) {
+	if m.duration == nil || e.Op != OpExec && e.Op != OpQuery {
+		return
+	}
+
+	m.duration.Record(
+		ctx,
+		durationMillis(e.Duration),
+		semconv.DBSystemKey.String(dbSystem(e.Driver)),
+		semconv.DBOperationKey.String(e.Op),
+		attrConnection.String(e.Connection),
+		attrRole.String(e.Role),
+		attrError.Bool(e.Err != nil),
+	)
+}
@@ -0,0 +1,116 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+// Package patroni provide Patroni cluster topology discovery of the sql registry nodes.
+package patroni
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gozix/sql/v3"
+)
+
+type (
+	// Config is Patroni topology configuration.
+	Config struct {
+		// Addresses are REST API addresses of the cluster members, e.g. http://pg-1:8008, they are
+		// tried in turn until one responds.
+		Addresses []string
+
+		// Client is HTTP client, http.DefaultClient by default.
+		Client *http.Client
+	}
+
+	// Topology discovers the cluster leader and the running replicas by the Patroni REST API.
+	Topology struct {
+		conf Config
+	}
+
+	// cluster is Patroni cluster response.
+	cluster struct {
+		Members []struct {
+			Name  string `json:"name"`
+			Role  string `json:"role"`
+			State string `json:"state"`
+			Host  string `json:"host"`
+			Port  int    `json:"port"`
+		} `json:"members"`
+	}
+)
+
+// Topology implements sql.TopologyDiscoverer.
+var _ sql.TopologyDiscoverer = (*Topology)(nil)
+
+// NewTopology is topology constructor.
+func NewTopology(conf Config) *Topology {
+	if conf.Client == nil {
+		conf.Client = http.DefaultClient
+	}
+
+	return &Topology{conf: conf}
+}
+
+// DiscoverTopology implements sql.TopologyDiscoverer.
+func (t *Topology) DiscoverTopology(ctx context.Context) (primary string, replicas []string, err error) {
+	if len(t.conf.Addresses) == 0 {
+		return "", nil, errors.New("no patroni addresses")
+	}
+
+	var c *cluster
+	for _, address := range t.conf.Addresses {
+		if c, err = t.cluster(ctx, address); err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		return "", nil, err
+	}
+
+	for _, member := range c.Members {
+		var endpoint = net.JoinHostPort(member.Host, strconv.Itoa(member.Port))
+		switch {
+		// the standby leader is read only replica of the remote cluster, the old versions name the leader master
+		case member.Role == "leader" || member.Role == "master":
+			primary = endpoint
+		case member.State == "running" || member.State == "streaming":
+			replicas = append(replicas, endpoint)
+		}
+	}
+
+	return primary, replicas, nil
+}
+
+// cluster requests the cluster state from the member API.
+func (t *Topology) cluster(ctx context.Context, address string) (_ *cluster, err error) {
+	var req *http.Request
+	if req, err = http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimRight(address, "/")+"/cluster", nil); err != nil {
+		return nil, err
+	}
+
+	var resp *http.Response
+	if resp, err = t.conf.Client.Do(req); err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		return nil, fmt.Errorf("patroni responded %d", resp.StatusCode)
+	}
+
+	var c cluster
+	if err = json.NewDecoder(resp.Body).Decode(&c); err != nil {
+		return nil, fmt.Errorf("unable decode patroni response : %w", err)
+	}
+
+	return &c, nil
+}
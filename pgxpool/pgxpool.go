@@ -0,0 +1,233 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+// Package pgxpool provide pgxpool backend of the sql registry connections. Connections with the
+// pgxpool backend are not opened by the registry itself, they are opened by this package using
+// the registry configuration, so the binary protocol, COPY and LISTEN/NOTIFY are available.
+package pgxpool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	gzSQL "github.com/gozix/sql/v3"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+type (
+	// Registry is registry of the pgxpool backed connections.
+	Registry struct {
+		mux      sync.Mutex
+		registry *gzSQL.Registry
+		pools    map[string]*Pools
+	}
+
+	// Pools are pools of the connection nodes, the first one is master.
+	Pools struct {
+		nodes   []*pgxpool.Pool
+		counter uint64
+	}
+)
+
+// NewRegistry returns registry of the connections configured with gzSQL.BackendPgxPool backend.
+func NewRegistry(registry *gzSQL.Registry) *Registry {
+	return &Registry{
+		registry: registry,
+		pools:    make(map[string]*Pools),
+	}
+}
+
+// Pools returns pools of the named connection, they are opened on first use.
+func (r *Registry) Pools(ctx context.Context, name string) (_ *Pools, err error) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if p, ok := r.pools[name]; ok {
+		return p, nil
+	}
+
+	var conf gzSQL.Config
+	if conf, err = r.registry.Config(name); err != nil {
+		return nil, err
+	}
+
+	if conf.Backend != gzSQL.BackendPgxPool {
+		return nil, gzSQL.ErrUnsupportedBackend
+	}
+
+	var p *Pools
+	if p, err = open(ctx, name, conf); err != nil {
+		return nil, fmt.Errorf("unable open %s connection : %w", name, err)
+	}
+
+	r.pools[name] = p
+
+	return p, nil
+}
+
+// Master returns master pool of the named connection.
+func (r *Registry) Master(ctx context.Context, name string) (*pgxpool.Pool, error) {
+	var p, err = r.Pools(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Master(), nil
+}
+
+// Slave returns slave pool of the named connection, the master pool is returned when the context
+// forces it, see sql.ForceMaster.
+func (r *Registry) Slave(ctx context.Context, name string) (*pgxpool.Pool, error) {
+	var p, err = r.Pools(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	if gzSQL.IsMasterForced(ctx) {
+		return p.Master(), nil
+	}
+
+	return p.Slave(), nil
+}
+
+// Close closes every opened pool.
+func (r *Registry) Close() {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	var names = make([]string, 0, len(r.pools))
+	for name := range r.pools {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		r.pools[name].Close()
+		delete(r.pools, name)
+	}
+}
+
+// Master returns master pool.
+func (p *Pools) Master() *pgxpool.Pool {
+	return p.nodes[0]
+}
+
+// Slave returns slave pool in round-robin order, the master pool is returned if there is no slaves.
+func (p *Pools) Slave() *pgxpool.Pool {
+	if len(p.nodes) == 1 {
+		return p.nodes[0]
+	}
+
+	var n = atomic.AddUint64(&p.counter, 1)
+
+	return p.nodes[1+n%uint64(len(p.nodes)-1)]
+}
+
+// Close closes every pool.
+func (p *Pools) Close() {
+	for _, pool := range p.nodes {
+		pool.Close()
+	}
+}
+
+// open opens and pings pools of every connection node.
+func open(ctx context.Context, name string, conf gzSQL.Config) (_ *Pools, err error) {
+	var dsn []string
+	if dsn, err = conf.ResolveDSNs(ctx, name); err != nil {
+		return nil, err
+	}
+
+	if len(dsn) == 0 {
+		return nil, errors.New("no nodes")
+	}
+
+	var p = Pools{nodes: make([]*pgxpool.Pool, 0, len(dsn))}
+	for i := range dsn {
+		var node gzSQL.Node
+		if i < len(conf.NodeConfigs) {
+			node = conf.NodeConfigs[i]
+		}
+
+		var pool *pgxpool.Pool
+		if pool, err = openPool(ctx, dsn[i], node, conf); err != nil {
+			p.Close()
+			return nil, &gzSQL.ConnectionError{Name: name, Node: i, Op: gzSQL.OpOpen, Err: err}
+		}
+
+		p.nodes = append(p.nodes, pool)
+	}
+
+	return &p, nil
+}
+
+// openPool opens and pings pool of the node, the node pool settings override the connection ones.
+func openPool(ctx context.Context, dsn string, node gzSQL.Node, conf gzSQL.Config) (_ *pgxpool.Pool, err error) {
+	var poolConf *pgxpool.Config
+	if poolConf, err = pgxpool.ParseConfig(dsn); err != nil {
+		return nil, err
+	}
+
+	if conf.PoolerMode {
+		poolConf.ConnConfig.DefaultQueryExecMode = pgx.QueryExecModeSimpleProtocol
+		poolConf.ConnConfig.StatementCacheCapacity = 0
+		poolConf.ConnConfig.DescriptionCacheCapacity = 0
+	}
+
+	if conf.Dialer != nil {
+		poolConf.ConnConfig.DialFunc = pgconn.DialFunc(conf.Dialer)
+		poolConf.ConnConfig.LookupFunc = func(_ context.Context, host string) ([]string, error) {
+			return []string{host}, nil
+		}
+	}
+
+	if value := inheritInt(node.MaxOpenConns, conf.MaxOpenConns); value > 0 {
+		poolConf.MaxConns = int32(value)
+	}
+
+	if value := inheritDuration(node.ConnMaxLifetime, conf.ConnMaxLifetime); value > 0 {
+		poolConf.MaxConnLifetime = value
+	}
+
+	if value := inheritDuration(node.ConnMaxIdleTime, conf.ConnMaxIdleTime); value > 0 {
+		poolConf.MaxConnIdleTime = value
+	}
+
+	var pool *pgxpool.Pool
+	if pool, err = pgxpool.NewWithConfig(ctx, poolConf); err != nil {
+		return nil, err
+	}
+
+	if err = pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return pool, nil
+}
+
+// inheritInt returns the node value or the connection one if the node value is zero.
+func inheritInt(node, conn int) int {
+	if node != 0 {
+		return node
+	}
+
+	return conn
+}
+
+// inheritDuration returns the node value or the connection one if the node value is zero.
+func inheritDuration(node, conn time.Duration) time.Duration {
+	if node != 0 {
+		return node
+	}
+
+	return conn
+}
@@ -0,0 +1,123 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"sync/atomic"
+)
+
+// ReadPolicy is policy of reads distribution across slave nodes.
+type ReadPolicy string
+
+// Read policies.
+const (
+	// ReadPolicyRoundRobin distributes reads evenly in turn, it is default policy.
+	ReadPolicyRoundRobin ReadPolicy = "round_robin"
+
+	// ReadPolicyRandom sends read to a random slave node.
+	ReadPolicyRandom ReadPolicy = "random"
+
+	// ReadPolicyLeastConnections sends read to the slave node with the minimum in use connections.
+	ReadPolicyLeastConnections ReadPolicy = "least_connections"
+
+	// ReadPolicyWeighted sends read to a random slave node proportionally to the node weight.
+	ReadPolicyWeighted ReadPolicy = "weighted"
+)
+
+// validate checks the policy is known.
+func (p ReadPolicy) validate() error {
+	switch p {
+	case "", ReadPolicyRoundRobin, ReadPolicyRandom, ReadPolicyLeastConnections, ReadPolicyWeighted:
+		return nil
+	default:
+		return fmt.Errorf("unknown read policy %q", string(p))
+	}
+}
+
+// pick returns index of the chosen candidate.
+func (p ReadPolicy) pick(c *connection, candidates []int) int {
+	var nodes = c.db.Databases()
+
+	switch p {
+	case ReadPolicyRandom:
+		return candidates[rand.Intn(len(candidates))] //nolint:gosec
+	case ReadPolicyLeastConnections:
+		var (
+			best  = candidates[0]
+			inUse = nodes[best].Stats().InUse
+		)
+
+		for _, idx := range candidates[1:] {
+			if n := nodes[idx].Stats().InUse; n < inUse {
+				best, inUse = idx, n
+			}
+		}
+
+		return best
+	case ReadPolicyWeighted:
+		var total int
+		for _, idx := range candidates {
+			total += c.weight(idx)
+		}
+
+		if total <= 0 {
+			return candidates[rand.Intn(len(candidates))] //nolint:gosec
+		}
+
+		var n = rand.Intn(total) //nolint:gosec
+		for _, idx := range candidates {
+			if n -= c.weight(idx); n < 0 {
+				return idx
+			}
+		}
+
+		return candidates[len(candidates)-1]
+	default:
+		return candidates[atomic.AddUint64(&c.counter, 1)%uint64(len(candidates))]
+	}
+}
+
+// weight returns read weight of the node, the node weight takes precedence over the connection
+// ReadWeights entry, nodes without configured weight, zero one included, have weight 1.
+func (c *connection) weight(idx int) int {
+	if idx < len(c.conf.NodeConfigs) && c.conf.NodeConfigs[idx].Weight > 0 {
+		return c.conf.NodeConfigs[idx].Weight
+	}
+
+	if idx < len(c.conf.ReadWeights) && c.conf.ReadWeights[idx] > 0 {
+		return c.conf.ReadWeights[idx]
+	}
+
+	return 1
+}
+
+// slave returns a slave node chosen by the read policy. Nodes removed by circuit breakers, by
+// the health monitor or lagging too much are skipped, the master node is returned if there is no
+// slaves or all of them are removed. MasterReadPercent of reads go to the master node anyway.
+func (c *connection) slave() *sql.DB {
+	var (
+		nodes      = c.db.Databases()
+		candidates = make([]int, 0, len(nodes))
+	)
+
+	if c.conf.MasterReadPercent > 0 && rand.Intn(100) < c.conf.MasterReadPercent { //nolint:gosec
+		return nodes[0]
+	}
+
+	for idx := 1; idx < len(nodes); idx++ {
+		if c.available(idx) {
+			candidates = append(candidates, idx)
+		}
+	}
+
+	if len(candidates) == 0 {
+		return nodes[0]
+	}
+
+	return nodes[c.conf.ReadPolicy.pick(c, candidates)]
+}
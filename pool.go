@@ -0,0 +1,56 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"errors"
+	"time"
+)
+
+// SetPoolLimits adjusts the pool settings of the named connection live, the opened pools are updated
+// in place without reconnecting and the settings are kept when the connection is reopened. Node pool
+// settings keep taking precedence over the connection ones. A following Reload with configuration
+// that differs by the pool settings reopens the connection.
+func (r *Registry) SetPoolLimits(name string, maxOpen, maxIdle int, maxLifetime, maxIdleTime time.Duration) error {
+	if maxOpen < 0 || maxIdle < 0 {
+		return errors.New("negative pool limits")
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	var conf, ok = r.conf[name]
+	if !ok {
+		return ErrUnknownConnection
+	}
+
+	conf.MaxOpenConns = maxOpen
+	conf.MaxIdleConns = maxIdle
+	conf.ConnMaxLifetime = maxLifetime
+	conf.ConnMaxIdleTime = maxIdleTime
+
+	if c, ok := r.conns[name]; ok {
+		for i, node := range c.db.Databases() {
+			var n Node
+			if i < len(conf.NodeConfigs) {
+				n = conf.NodeConfigs[i]
+			}
+
+			n.apply(node, conf)
+		}
+	}
+
+	var configs = make(Configs, len(r.conf))
+	for key, value := range r.conf {
+		configs[key] = value
+	}
+
+	configs[name] = conf
+	r.conf = configs
+	r.confVersion++
+	r.touch(name)
+
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"errors"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// poolerDSN returns the node DSN switched to statements a transaction pooling proxy like PgBouncer or
+// ProxySQL can route: no named server side prepared statements survive between transactions.
+func poolerDSN(driverName, dsn string) (_ string, err error) {
+	switch driverName {
+	case "pgx":
+		return withPostgresParams(dsn, [][2]string{
+			{"default_query_exec_mode", "simple_protocol"},
+			{"statement_cache_capacity", "0"},
+			{"description_cache_capacity", "0"},
+		})
+	case "postgres", "cloudsqlpostgres":
+		return withPostgresParams(dsn, [][2]string{{"binary_parameters", "yes"}})
+	case "mysql":
+		var cfg *mysql.Config
+		if cfg, err = mysql.ParseDSN(dsn); err != nil {
+			return "", err
+		}
+
+		cfg.InterpolateParams = true
+
+		return cfg.FormatDSN(), nil
+	default:
+		return dsn, nil
+	}
+}
+
+// poolerProblems returns problems of the connection configuration in pooler mode, session state is
+// lost once the proxy hands the server connection to another client.
+func (c Config) poolerProblems() (problems []error) {
+	if !c.PoolerMode {
+		return nil
+	}
+
+	if c.ReadOnlySlaves || len(c.InitStatements) > 0 {
+		problems = append(problems, errors.New("session statements are not supported in pooler mode"))
+	}
+
+	return problems
+}
@@ -0,0 +1,210 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"sync"
+)
+
+// Query priority classes.
+const (
+	// PriorityInteractive is priority of latency sensitive queries, it is default priority.
+	PriorityInteractive Priority = iota
+
+	// PriorityBatch is priority of background queries, they are limited by Concurrency.BatchLimit and
+	// wait while interactive queries are queued.
+	PriorityBatch
+)
+
+type (
+	// Priority is query priority class.
+	Priority int
+
+	// Concurrency is configuration of the connection statements concurrency limit.
+	Concurrency struct {
+		// Limit is maximum number of concurrently executing statements across the connection nodes,
+		// zero disables the limit.
+		Limit int `json:"limit"`
+
+		// BatchLimit is maximum number of concurrently executing batch statements, zero means half
+		// of the limit rounded up.
+		BatchLimit int `json:"batch_limit"`
+	}
+
+	// priorityKey is context key of the query priority.
+	priorityKey struct{}
+
+	// prioritySlotKey is context key of the acquired semaphore slot.
+	prioritySlotKey struct{}
+
+	// prioritySemaphore is counting semaphore serving interactive waiters before batch ones.
+	prioritySemaphore struct {
+		mux         sync.Mutex
+		limit       int
+		batchLimit  int
+		inUse       int
+		batchInUse  int
+		interactive []chan struct{}
+		batch       []chan struct{}
+	}
+
+	// prioritySlot is acquired semaphore slot.
+	prioritySlot struct {
+		priority Priority
+	}
+
+	// priorityInterceptor limits concurrently executing statements with the semaphore.
+	priorityInterceptor struct {
+		sem *prioritySemaphore
+	}
+)
+
+// ContextWithPriority returns context whose statements run with the priority, it matters for
+// connections with Concurrency limit only.
+func ContextWithPriority(ctx context.Context, priority Priority) context.Context {
+	return context.WithValue(ctx, priorityKey{}, priority)
+}
+
+// PriorityFromContext returns priority of the context statements.
+func PriorityFromContext(ctx context.Context) Priority {
+	var priority, _ = ctx.Value(priorityKey{}).(Priority)
+	return priority
+}
+
+// newPriorityInterceptor returns the interceptor or nil if the limit is not set.
+func newPriorityInterceptor(conf Concurrency) interceptor {
+	if conf.Limit <= 0 {
+		return nil
+	}
+
+	if conf.BatchLimit <= 0 || conf.BatchLimit > conf.Limit {
+		conf.BatchLimit = (conf.Limit + 1) / 2
+	}
+
+	return &priorityInterceptor{sem: &prioritySemaphore{limit: conf.Limit, batchLimit: conf.BatchLimit}}
+}
+
+func (i *priorityInterceptor) before(ctx context.Context, e *QueryEvent) (context.Context, error) {
+	if e.Op != OpExec && e.Op != OpQuery {
+		return ctx, nil
+	}
+
+	var priority = PriorityFromContext(ctx)
+	if err := i.sem.acquire(ctx, priority); err != nil {
+		return ctx, err
+	}
+
+	return context.WithValue(ctx, prioritySlotKey{}, &prioritySlot{priority: priority}), nil
+}
+
+func (i *priorityInterceptor) after(ctx context.Context, _ *QueryEvent) {
+	if slot, ok := ctx.Value(prioritySlotKey{}).(*prioritySlot); ok {
+		i.sem.release(slot.priority)
+	}
+}
+
+// acquire waits for a free slot of the priority until the context is done.
+func (s *prioritySemaphore) acquire(ctx context.Context, priority Priority) error {
+	s.mux.Lock()
+	if s.admits(priority) && len(s.interactive) == 0 && (priority == PriorityInteractive || len(s.batch) == 0) {
+		s.take(priority)
+		s.mux.Unlock()
+
+		return nil
+	}
+
+	var ready = make(chan struct{})
+	if priority == PriorityBatch {
+		s.batch = append(s.batch, ready)
+	} else {
+		s.interactive = append(s.interactive, ready)
+	}
+	s.mux.Unlock()
+
+	select {
+	case <-ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	select {
+	case <-ready:
+		// the slot was handed over concurrently with the cancellation, give it back
+		s.inUse--
+		if priority == PriorityBatch {
+			s.batchInUse--
+		}
+
+		s.wake()
+	default:
+		s.remove(priority, ready)
+	}
+
+	return ctx.Err()
+}
+
+// release frees the slot of the priority and hands it over to the next waiter.
+func (s *prioritySemaphore) release(priority Priority) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.inUse--
+	if priority == PriorityBatch {
+		s.batchInUse--
+	}
+
+	s.wake()
+}
+
+// wake hands free slots over to the waiters, interactive ones go first. The mutex must be held.
+func (s *prioritySemaphore) wake() {
+	for len(s.interactive) > 0 && s.admits(PriorityInteractive) {
+		s.take(PriorityInteractive)
+		close(s.interactive[0])
+		s.interactive = s.interactive[1:]
+	}
+
+	for len(s.interactive) == 0 && len(s.batch) > 0 && s.admits(PriorityBatch) {
+		s.take(PriorityBatch)
+		close(s.batch[0])
+		s.batch = s.batch[1:]
+	}
+}
+
+// admits reports whether a slot of the priority is free. The mutex must be held.
+func (s *prioritySemaphore) admits(priority Priority) bool {
+	if s.inUse >= s.limit {
+		return false
+	}
+
+	return priority != PriorityBatch || s.batchInUse < s.batchLimit
+}
+
+// take occupies a slot of the priority. The mutex must be held.
+func (s *prioritySemaphore) take(priority Priority) {
+	s.inUse++
+	if priority == PriorityBatch {
+		s.batchInUse++
+	}
+}
+
+// remove drops the waiter of the priority. The mutex must be held.
+func (s *prioritySemaphore) remove(priority Priority, ready chan struct{}) {
+	var queue = &s.interactive
+	if priority == PriorityBatch {
+		queue = &s.batch
+	}
+
+	for i, waiter := range *queue {
+		if waiter == ready {
+			*queue = append((*queue)[:i:i], (*queue)[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPrioritySemaphoreAdmission(t *testing.T) {
+	var cases = []struct {
+		name     string
+		limit    int
+		batch    int
+		held     []Priority
+		priority Priority
+		admitted bool
+	}{
+		{name: "free", limit: 2, batch: 1, priority: PriorityInteractive, admitted: true},
+		{name: "limit reached", limit: 1, batch: 1, held: []Priority{PriorityInteractive}, priority: PriorityInteractive},
+		{name: "batch limit reached", limit: 3, batch: 1, held: []Priority{PriorityBatch}, priority: PriorityBatch},
+		{
+			name: "interactive beyond batch limit", limit: 3, batch: 1, held: []Priority{PriorityBatch},
+			priority: PriorityInteractive, admitted: true,
+		},
+		{
+			name: "batch limit shared by limit", limit: 2, batch: 2,
+			held: []Priority{PriorityInteractive, PriorityBatch}, priority: PriorityBatch,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var sem = prioritySemaphore{limit: tc.limit, batchLimit: tc.batch}
+			for _, priority := range tc.held {
+				if err := sem.acquire(context.Background(), priority); err != nil {
+					t.Fatalf("unable acquire held slot : %v", err)
+				}
+			}
+
+			var ctx, cancel = context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+
+			var err = sem.acquire(ctx, tc.priority)
+			switch {
+			case tc.admitted && err != nil:
+				t.Fatalf("not admitted : %v", err)
+			case !tc.admitted && !errors.Is(err, context.DeadlineExceeded):
+				t.Fatalf("admitted with %v", err)
+			}
+
+			if len(sem.interactive) != 0 || len(sem.batch) != 0 {
+				t.Fatal("canceled waiter is queued")
+			}
+		})
+	}
+}
+
+func TestPrioritySemaphoreInteractiveFirst(t *testing.T) {
+	var sem = prioritySemaphore{limit: 1, batchLimit: 1}
+	if err := sem.acquire(context.Background(), PriorityInteractive); err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		wg      sync.WaitGroup
+		order   = make(chan Priority, 2)
+		waiting = func(n int) {
+			for {
+				sem.mux.Lock()
+				var queued = len(sem.interactive) + len(sem.batch)
+				sem.mux.Unlock()
+
+				if queued == n {
+					return
+				}
+
+				time.Sleep(time.Millisecond)
+			}
+		}
+	)
+
+	for i, priority := range []Priority{PriorityBatch, PriorityInteractive} {
+		wg.Add(1)
+		go func(priority Priority) {
+			defer wg.Done()
+
+			if err := sem.acquire(context.Background(), priority); err != nil {
+				t.Error(err)
+			}
+
+			order <- priority
+			sem.release(priority)
+		}(priority)
+
+		waiting(i + 1)
+	}
+
+	sem.release(PriorityInteractive)
+	wg.Wait()
+
+	if first, second := <-order, <-order; first != PriorityInteractive || second != PriorityBatch {
+		t.Fatalf("served %d before %d", first, second)
+	}
+
+	if sem.inUse != 0 || sem.batchInUse != 0 {
+		t.Fatalf("slots leaked, %d in use, %d batch", sem.inUse, sem.batchInUse)
+	}
+}
+
+func TestNewPriorityInterceptorBatchLimit(t *testing.T) {
+	var cases = []struct {
+		conf Concurrency
+		want int
+	}{
+		{conf: Concurrency{Limit: 4}, want: 2},
+		{conf: Concurrency{Limit: 5}, want: 3},
+		{conf: Concurrency{Limit: 4, BatchLimit: 1}, want: 1},
+		{conf: Concurrency{Limit: 4, BatchLimit: 8}, want: 2},
+	}
+
+	for _, tc := range cases {
+		var i = newPriorityInterceptor(tc.conf).(*priorityInterceptor)
+		if i.sem.batchLimit != tc.want {
+			t.Errorf("batch limit of %+v is %d, want %d", tc.conf, i.sem.batchLimit, tc.want)
+		}
+	}
+
+	if newPriorityInterceptor(Concurrency{}) != nil {
+		t.Error("interceptor without limit")
+	}
+}
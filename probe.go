@@ -0,0 +1,174 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// DefaultRoleProbeTimeout is timeout of the node role probe used when the timeout is not set.
+const DefaultRoleProbeTimeout = time.Second
+
+// RoleProbe is configuration of the node role verification. Every node runs the probe periodically,
+// when a slave node turns out to be the only primary, the connection is reopened with that node as
+// master, so the writes follow a replica promoted behind the registry back.
+type RoleProbe struct {
+	// Interval is interval of the probes, zero disables them.
+	Interval time.Duration `json:"interval"`
+
+	// Timeout is timeout of a single probe, zero means DefaultRoleProbeTimeout.
+	Timeout time.Duration `json:"timeout"`
+
+	// Query is query returning true on replica, the driver specific query is used when empty.
+	Query string `json:"query"`
+}
+
+// roleProbeQuery returns the driver specific query reporting whether the node is replica.
+func roleProbeQuery(driverName string) string {
+	switch driverName {
+	case "postgres", "pgx", "cloudsqlpostgres":
+		return "SELECT pg_is_in_recovery()"
+	case "mysql":
+		return "SELECT @@global.read_only"
+	default:
+		return ""
+	}
+}
+
+// problems returns the role probe configuration problems.
+func (p RoleProbe) problems(conf Config) (problems []error) {
+	if p.Interval <= 0 {
+		return nil
+	}
+
+	if p.Query == "" && roleProbeQuery(conf.Driver) == "" {
+		problems = append(problems, fmt.Errorf("no role probe query for %q driver", conf.Driver))
+	}
+
+	if conf.Discovery.enabled() || conf.DSNProvider != nil {
+		problems = append(problems, errors.New("role probe requires statically configured nodes"))
+	}
+
+	return problems
+}
+
+// probeRoles verifies the node roles of the connection until it is closed or replaced in the registry.
+func (r *Registry) probeRoles(c *connection, nodes []*sql.DB) {
+	var (
+		conf   = c.conf.RoleProbe
+		ticker = time.NewTicker(conf.Interval)
+	)
+
+	defer ticker.Stop()
+
+	if conf.Query == "" {
+		conf.Query = roleProbeQuery(c.conf.Driver)
+	}
+
+	if conf.Timeout <= 0 {
+		conf.Timeout = DefaultRoleProbeTimeout
+	}
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		var primaries []int
+		for i, node := range nodes {
+			var ctx, cancel = context.WithTimeout(context.Background(), conf.Timeout)
+
+			var replica bool
+			if err := node.QueryRowContext(ctx, conf.Query).Scan(&replica); err != nil {
+				r.logger.Error("unable probe node role", err, "connection", c.name, "node", i)
+			} else if !replica {
+				primaries = append(primaries, i)
+			}
+
+			cancel()
+		}
+
+		switch {
+		case len(primaries) == 1 && primaries[0] > 0:
+			r.logger.Info("slave node is promoted", "connection", c.name, "node", primaries[0])
+			if !r.promote(c, primaries[0]) {
+				return
+			}
+		case len(primaries) > 1:
+			r.logger.Info("several nodes report primary role", "connection", c.name, "nodes", primaries)
+		case len(primaries) == 0:
+			r.logger.Info("no node reports primary role", "connection", c.name)
+		}
+	}
+}
+
+// promote reopens the connection with the slave node as master and drains the old one in background,
+// the configuration keeps the new order. It reports whether the connection is still the registry one,
+// so the promotion is retried on next probe. The fresh connection is opened with the registry unlocked.
+func (r *Registry) promote(c *connection, idx int) bool {
+	r.mux.Lock()
+	if r.shutdown || r.conns[c.name] != c {
+		r.mux.Unlock()
+		return false
+	}
+
+	var (
+		conf = r.conf[c.name]
+		gen  = r.gens[c.name]
+	)
+
+	r.mux.Unlock()
+
+	if idx >= len(conf.NodeConfigs) {
+		return false
+	}
+
+	var nodes = make(Nodes, len(conf.NodeConfigs))
+	for i, node := range conf.NodeConfigs {
+		node.Role = RoleSlave
+		if i == idx {
+			node.Role = RoleMaster
+		}
+
+		nodes[i] = node
+	}
+
+	conf.NodeConfigs = nodes
+	conf = conf.ordered()
+
+	var fresh, err = r.openConfig(context.Background(), c.name, conf)
+	if err != nil {
+		r.logger.Error("unable reopen connection with promoted node", err, "connection", c.name)
+		return true
+	}
+
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if r.shutdown || r.conns[c.name] != c || r.gens[c.name] != gen {
+		_ = fresh.close()
+		return !r.shutdown && r.conns[c.name] == c
+	}
+
+	var configs = make(Configs, len(r.conf))
+	for key, value := range r.conf {
+		configs[key] = value
+	}
+
+	configs[c.name] = conf
+	r.conf = configs
+	r.confVersion++
+	r.conns[c.name] = fresh
+	r.touch(c.name)
+	go r.drain(c)
+
+	return false
+}
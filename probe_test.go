@@ -0,0 +1,107 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestRoleProbeProblems(t *testing.T) {
+	var cases = []struct {
+		name string
+		conf Config
+		want int
+	}{
+		{name: "disabled", conf: Config{Driver: "sqlmock"}},
+		{name: "driver query", conf: Config{Driver: "pgx", RoleProbe: RoleProbe{Interval: time.Second}}},
+		{name: "custom query", conf: Config{Driver: "sqlmock", RoleProbe: RoleProbe{Interval: time.Second, Query: "SELECT 1"}}},
+		{name: "no query", conf: Config{Driver: "sqlmock", RoleProbe: RoleProbe{Interval: time.Second}}, want: 1},
+		{
+			name: "discovered nodes",
+			conf: Config{Driver: "mysql", RoleProbe: RoleProbe{Interval: time.Second}, Discovery: Discovery{SRV: "_mysql._tcp.db"}},
+			want: 1,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := tc.conf.RoleProbe.problems(tc.conf); len(got) != tc.want {
+				t.Errorf("problems are %v, want %d", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRegistryPromote(t *testing.T) {
+	var mocks = make(map[string]sqlmock.Sqlmock)
+	for dsn, replica := range map[string]bool{"gozix_probe_a": true, "gozix_probe_b": false} {
+		var db, mock, err = sqlmock.NewWithDSN(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer db.Close()
+
+		mock.ExpectQuery("SELECT is_replica").WillReturnRows(sqlmock.NewRows([]string{"replica"}).AddRow(replica))
+		mocks[dsn] = mock
+	}
+
+	var registry, err = NewRegistry(Configs{"main": {
+		Driver:    "sqlmock",
+		Nodes:     []string{"gozix_probe_a", "gozix_probe_b"},
+		RoleProbe: RoleProbe{Interval: 10 * time.Millisecond, Query: "SELECT is_replica"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer registry.Close()
+
+	var old *connection
+	if old, err = registry.connection(context.Background(), "main"); err != nil {
+		t.Fatal(err)
+	}
+
+	var deadline = time.Now().Add(5 * time.Second)
+	for {
+		registry.mux.Lock()
+		var (
+			current = registry.conns["main"]
+			conf    = registry.conf["main"]
+		)
+		registry.mux.Unlock()
+
+		if current != old {
+			var want = Nodes{{DSN: "gozix_probe_b", Role: RoleMaster}, {DSN: "gozix_probe_a", Role: RoleSlave}}
+			if !reflect.DeepEqual(conf.NodeConfigs, want) {
+				t.Errorf("nodes are %v, want %v", conf.NodeConfigs, want)
+			}
+
+			break
+		}
+
+		if time.Now().After(deadline) {
+			t.Fatal("connection is not reopened after promotion")
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var db, _ = registry.ConnectionWithName("main")
+	if err = db.Master().Ping(); err != nil {
+		t.Errorf("reopened connection is unusable : %v", err)
+	}
+
+	for dsn, mock := range mocks {
+		if err = mock.ExpectationsWereMet(); err != nil {
+			t.Errorf("node %s is not probed : %v", dsn, err)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/iqoption/nap"
+)
+
+type (
+	// Profile is pool configuration of the connection profile, e.g. background. The profile has the same
+	// nodes as the connection but separate pools, so its workload can't starve the connection one. Zero
+	// settings are inherited from the connection.
+	Profile struct {
+		MaxOpenConns    int           `json:"max_open_conns"`
+		MaxIdleConns    int           `json:"max_idle_conns"`
+		ConnMaxLifetime time.Duration `json:"conn_max_lifetime"`
+		ConnMaxIdleTime time.Duration `json:"conn_max_idle_time"`
+	}
+
+	// Profiles are connection profiles keyed by name.
+	Profiles map[string]Profile
+)
+
+var (
+	// ErrUnknownProfile is error triggered when connection profile with provided name not founded.
+	ErrUnknownProfile = errors.New("unknown profile")
+
+	// errConnectionClosed is error triggered when profile of the closed connection is requested.
+	errConnectionClosed = errors.New("connection is closed")
+)
+
+// ConnectionWithNameAndProfile is connection profile getter, see Profile.
+func (r *Registry) ConnectionWithNameAndProfile(name, profile string) (*nap.DB, error) {
+	return r.ConnectionWithNameAndProfileContext(context.Background(), name, profile)
+}
+
+// ConnectionWithNameAndProfileContext is connection profile getter with context. The profile pools are
+// opened on first use and closed together with the connection.
+func (r *Registry) ConnectionWithNameAndProfileContext(ctx context.Context, name, profile string) (_ *nap.DB, err error) {
+	var c *connection
+	if c, err = r.connection(ctx, name); err != nil {
+		return nil, err
+	}
+
+	if c, err = c.profile(ctx, r, profile); err != nil {
+		return nil, err
+	}
+
+	return c.db, nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler, durations may be human strings like "5m".
+func (p *Profile) UnmarshalJSON(data []byte) error {
+	var _, err = unmarshalFields(data, p)
+	return err
+}
+
+// profile returns the opened connection profile, it is opened if needed.
+func (c *connection) profile(ctx context.Context, r *Registry, name string) (_ *connection, err error) {
+	var profile, ok = c.conf.Profiles[name]
+	if !ok {
+		return nil, ErrUnknownProfile
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	select {
+	case <-c.done:
+		return nil, errConnectionClosed
+	default:
+	}
+
+	if p, ok := c.profiles[name]; ok {
+		return p, nil
+	}
+
+	var p *connection
+	if p, err = r.openConfig(ctx, c.name, c.conf.withProfile(profile)); err != nil {
+		return nil, err
+	}
+
+	if c.profiles == nil {
+		c.profiles = make(map[string]*connection)
+	}
+
+	c.profiles[name] = p
+
+	return p, nil
+}
+
+// withProfile returns the configuration with the profile pool settings. Profiles and lifecycle hooks are
+// dropped, the hooks were already invoked for the connection itself.
+func (c Config) withProfile(p Profile) Config {
+	c.MaxOpenConns = inheritInt(p.MaxOpenConns, c.MaxOpenConns)
+	c.MaxIdleConns = inheritInt(p.MaxIdleConns, c.MaxIdleConns)
+	c.ConnMaxLifetime = inheritDuration(p.ConnMaxLifetime, c.ConnMaxLifetime)
+	c.ConnMaxIdleTime = inheritDuration(p.ConnMaxIdleTime, c.ConnMaxIdleTime)
+	c.Profiles = nil
+	c.BeforeOpen = nil
+	c.AfterOpen = nil
+	c.AfterClose = nil
+
+	return c
+}
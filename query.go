@@ -0,0 +1,190 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// fieldsCache caches column fields of the struct types.
+var fieldsCache sync.Map // map[reflect.Type]map[string][]int
+
+// scannerType is type of the sql.Scanner interface.
+var scannerType = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+
+// QueryAll executes the query and scans every row into T. Struct fields are matched with the columns
+// by the db tag or by lower cased name, `db:"-"` fields are skipped, embedded structs are flattened,
+// every column must have a field. Other types, e.g. int64, string or sql.Scanner implementations,
+// are scanned from the single column.
+func QueryAll[T any](ctx context.Context, db Executor, query string, args ...interface{}) (_ []T, err error) {
+	var rows *sql.Rows
+	if rows, err = db.QueryContext(ctx, query, args...); err != nil {
+		return nil, err
+	}
+
+	defer func() {
+		if closeErr := rows.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	var scan func(dest *T) error
+	if scan, err = scanner[T](rows); err != nil {
+		return nil, err
+	}
+
+	var result []T
+	for rows.Next() {
+		var item T
+		if err = scan(&item); err != nil {
+			return nil, err
+		}
+
+		result = append(result, item)
+	}
+
+	return result, rows.Err()
+}
+
+// QueryOne executes the query and scans the first row into T like QueryAll, sql.ErrNoRows is
+// returned when there are no rows.
+func QueryOne[T any](ctx context.Context, db Executor, query string, args ...interface{}) (item T, err error) {
+	var rows *sql.Rows
+	if rows, err = db.QueryContext(ctx, query, args...); err != nil {
+		return item, err
+	}
+
+	defer func() {
+		if closeErr := rows.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	var scan func(dest *T) error
+	if scan, err = scanner[T](rows); err != nil {
+		return item, err
+	}
+
+	if !rows.Next() {
+		if err = rows.Err(); err != nil {
+			return item, err
+		}
+
+		return item, sql.ErrNoRows
+	}
+
+	err = scan(&item)
+
+	return item, err
+}
+
+// scanner returns function scanning the current row into T.
+func scanner[T any](rows *sql.Rows) (func(dest *T) error, error) {
+	var columns, err = rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	var typ = reflect.TypeOf((*T)(nil)).Elem()
+	if !isStructDest(typ) {
+		if len(columns) != 1 {
+			return nil, fmt.Errorf("unable scan %d columns into %s", len(columns), typ)
+		}
+
+		return func(dest *T) error {
+			return rows.Scan(dest)
+		}, nil
+	}
+
+	var (
+		fields  = structFields(typ)
+		indexes = make([][]int, len(columns))
+	)
+
+	for i, column := range columns {
+		var index, ok = fields[strings.ToLower(column)]
+		if !ok {
+			return nil, fmt.Errorf("missing destination for column %s in %s", column, typ)
+		}
+
+		indexes[i] = index
+	}
+
+	return func(dest *T) error {
+		var (
+			value = reflect.ValueOf(dest).Elem()
+			ptrs  = make([]interface{}, len(indexes))
+		)
+
+		for i, index := range indexes {
+			ptrs[i] = value.FieldByIndex(index).Addr().Interface()
+		}
+
+		return rows.Scan(ptrs...)
+	}, nil
+}
+
+// isStructDest reports whether the type is struct scanned by fields rather than as a single value.
+func isStructDest(typ reflect.Type) bool {
+	if typ.Kind() != reflect.Struct || reflect.PtrTo(typ).Implements(scannerType) {
+		return false
+	}
+
+	return typ.PkgPath() != "time" || typ.Name() != "Time"
+}
+
+// structFields returns field indexes of the struct type by lower cased column name.
+func structFields(typ reflect.Type) map[string][]int {
+	if fields, ok := fieldsCache.Load(typ); ok {
+		return fields.(map[string][]int)
+	}
+
+	var fields = make(map[string][]int)
+	collectFields(typ, nil, fields)
+	fieldsCache.Store(typ, fields)
+
+	return fields
+}
+
+// collectFields adds the fields of the struct type to the fields, outer fields take precedence over
+// the embedded ones.
+func collectFields(typ reflect.Type, parent []int, fields map[string][]int) {
+	var embedded []reflect.StructField
+	for i := 0; i < typ.NumField(); i++ {
+		var field = typ.Field(i)
+
+		var tag = field.Tag.Get("db")
+		if tag == "-" {
+			continue
+		}
+
+		if field.Anonymous && tag == "" && isStructDest(field.Type) {
+			embedded = append(embedded, field)
+			continue
+		}
+
+		if !field.IsExported() {
+			continue
+		}
+
+		var name = strings.ToLower(field.Name)
+		if tag != "" {
+			name = strings.ToLower(tag)
+		}
+
+		if _, ok := fields[name]; !ok {
+			fields[name] = append(append([]int(nil), parent...), field.Index...)
+		}
+	}
+
+	for _, field := range embedded {
+		collectFields(field.Type, append(append([]int(nil), parent...), field.Index...), fields)
+	}
+}
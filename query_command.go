@@ -0,0 +1,175 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Output formats of the sql:query command.
+const (
+	formatTable = "table"
+	formatJSON  = "json"
+)
+
+// provideQueryCmd returns command running ad-hoc statement against the connection node.
+func provideQueryCmd(registry *Registry) *cobra.Command {
+	var cmd = cobra.Command{
+		Use:           "sql:query <statement>",
+		Short:         "Run statement against a database connection and print the result",
+		Args:          cobra.MinimumNArgs(1),
+		SilenceUsage:  true,
+		SilenceErrors: true,
+	}
+
+	var (
+		name   = cmd.Flags().StringP("connection", "c", DEFAULT, "connection name")
+		node   = cmd.Flags().IntP("node", "n", 0, "node index, 0 is master, -1 is slave chosen by the read policy")
+		format = cmd.Flags().StringP("format", "f", formatTable, "output format, table or json")
+	)
+
+	cmd.RunE = func(cmd *cobra.Command, args []string) (err error) {
+		if *format != formatTable && *format != formatJSON {
+			return fmt.Errorf("unknown %q format", *format)
+		}
+
+		var db *sql.DB
+		if db, err = queryNode(cmd, registry, *name, *node); err != nil {
+			return err
+		}
+
+		var rows *sql.Rows
+		if rows, err = db.QueryContext(cmd.Context(), strings.Join(args, " ")); err != nil {
+			return err
+		}
+
+		defer func() {
+			if closeErr := rows.Close(); err == nil {
+				err = closeErr
+			}
+		}()
+
+		var (
+			columns []string
+			values  [][]interface{}
+		)
+
+		if columns, values, err = scanAll(rows); err != nil {
+			return err
+		}
+
+		if *format == formatJSON {
+			return printJSON(cmd.OutOrStdout(), columns, values)
+		}
+
+		return printTable(cmd.OutOrStdout(), columns, values)
+	}
+
+	return &cmd
+}
+
+// queryNode returns the connection node by index, negative index means a slave chosen by the read policy.
+func queryNode(cmd *cobra.Command, registry *Registry, name string, node int) (*sql.DB, error) {
+	if node < 0 {
+		return registry.SlaveWithNameContext(cmd.Context(), name)
+	}
+
+	var db, err = registry.ConnectionWithNameContext(cmd.Context(), name)
+	if err != nil {
+		return nil, err
+	}
+
+	var nodes = db.Databases()
+	if node >= len(nodes) {
+		return nil, fmt.Errorf("%s connection has %d nodes, node %d is missing", name, len(nodes), node)
+	}
+
+	return nodes[node], nil
+}
+
+// scanAll reads every row, byte slices are converted to strings.
+func scanAll(rows *sql.Rows) (columns []string, values [][]interface{}, err error) {
+	if columns, err = rows.Columns(); err != nil {
+		return nil, nil, err
+	}
+
+	for rows.Next() {
+		var (
+			row  = make([]interface{}, len(columns))
+			dest = make([]interface{}, len(columns))
+		)
+
+		for i := range row {
+			dest[i] = &row[i]
+		}
+
+		if err = rows.Scan(dest...); err != nil {
+			return nil, nil, err
+		}
+
+		for i, value := range row {
+			if b, ok := value.([]byte); ok {
+				row[i] = string(b)
+			}
+		}
+
+		values = append(values, row)
+	}
+
+	return columns, values, rows.Err()
+}
+
+// printTable prints the rows as aligned table.
+func printTable(w io.Writer, columns []string, values [][]interface{}) error {
+	if len(columns) == 0 {
+		_, err := fmt.Fprintln(w, "OK")
+		return err
+	}
+
+	var tw = newTabWriter(w)
+	_, _ = fmt.Fprintln(tw, strings.Join(columns, "\t"))
+
+	for _, row := range values {
+		var cells = make([]string, 0, len(row))
+		for _, value := range row {
+			if value == nil {
+				cells = append(cells, "NULL")
+				continue
+			}
+
+			cells = append(cells, fmt.Sprint(value))
+		}
+
+		_, _ = fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+
+	_, _ = fmt.Fprintf(tw, "(%d rows)\n", len(values))
+
+	return tw.Flush()
+}
+
+// printJSON prints the rows as JSON array of objects keyed by column name.
+func printJSON(w io.Writer, columns []string, values [][]interface{}) error {
+	var result = make([]map[string]interface{}, 0, len(values))
+	for _, row := range values {
+		var item = make(map[string]interface{}, len(columns))
+		for i, column := range columns {
+			item[column] = row[i]
+		}
+
+		result = append(result, item)
+	}
+
+	var encoder = json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(result)
+}
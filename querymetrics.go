@@ -0,0 +1,111 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"regexp"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// queryMetrics records latency and errors of the statements by normalized query text.
+type queryMetrics struct {
+	registerer prometheus.Registerer
+	duration   *prometheus.HistogramVec
+	errors     *prometheus.CounterVec
+}
+
+// Patterns of the query normalization.
+var (
+	literalString  = regexp.MustCompile(`'(?:[^']|'')*'`)
+	literalNumber  = regexp.MustCompile(`([^\w$.]|^)-?\d+(?:\.\d+)?(?:[eE][-+]?\d+)?`)
+	whitespaces    = regexp.MustCompile(`\s+`)
+	placeholderSet = regexp.MustCompile(`\?(?:\s*,\s*\?)+`)
+)
+
+// WithQueryMetrics option records latency histogram and error counter of every executed statement
+// by normalized statement text, connection name and node role. Literals are stripped from the text
+// to keep the labels cardinality low, the correlation ID of the statement is attached as exemplar.
+// Nil buckets mean prometheus.DefBuckets.
+func WithQueryMetrics(registerer prometheus.Registerer, buckets []float64) Option {
+	return optionFunc(func(r *Registry) {
+		var labels = []string{"statement", "connection", "role"}
+
+		r.queryMetrics = &queryMetrics{
+			registerer: registerer,
+			duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Name:    "query_duration_seconds",
+				Help:    "The statement execution latency",
+				Buckets: buckets,
+			}, labels),
+			errors: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "query_errors_total",
+				Help: "The total number of failed statements",
+			}, labels),
+		}
+
+		r.chain = append(r.chain, r.queryMetrics)
+	})
+}
+
+// register registers the metrics in the registerer.
+func (m *queryMetrics) register() error {
+	if err := m.registerer.Register(m.duration); err != nil {
+		return err
+	}
+
+	if err := m.registerer.Register(m.errors); err != nil {
+		m.registerer.Unregister(m.duration)
+		return err
+	}
+
+	return nil
+}
+
+func (m *queryMetrics) before(ctx context.Context, _ *QueryEvent) (context.Context, error) {
+	return ctx, nil
+}
+
+func (m *queryMetrics) after(_ context.Context, e *QueryEvent) {
+	if e.Op != OpExec && e.Op != OpQuery {
+		return
+	}
+
+	var labels = prometheus.Labels{
+		"statement":  normalizeQuery(e.Query),
+		"connection": e.Connection,
+		"role":       e.Role,
+	}
+
+	var sample = exemplar(e.CorrelationID)
+	if observer, ok := m.duration.With(labels).(prometheus.ExemplarObserver); ok && sample != nil {
+		observer.ObserveWithExemplar(e.Duration.Seconds(), sample)
+	} else {
+		m.duration.With(labels).Observe(e.Duration.Seconds())
+	}
+
+	if e.Err == nil {
+		return
+	}
+
+	if adder, ok := m.errors.With(labels).(prometheus.ExemplarAdder); ok && sample != nil {
+		adder.AddWithExemplar(1, sample)
+	} else {
+		m.errors.With(labels).Inc()
+	}
+}
+
+// normalizeQuery replaces string and numeric literals with placeholders, collapses placeholder
+// lists and whitespaces, so statements differing by values only have the same text.
+func normalizeQuery(query string) string {
+	query = literalString.ReplaceAllString(query, "?")
+	query = literalNumber.ReplaceAllString(query, "${1}?")
+	query = whitespaces.ReplaceAllString(query, " ")
+	query = placeholderSet.ReplaceAllString(query, "?")
+
+	return strings.TrimSpace(query)
+}
@@ -0,0 +1,30 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import "testing"
+
+func TestNormalizeQuery(t *testing.T) {
+	var cases = []struct {
+		query string
+		want  string
+	}{
+		{query: "SELECT * FROM users WHERE id = 42", want: "SELECT * FROM users WHERE id = ?"},
+		{query: "SELECT * FROM users WHERE name = 'O''Brien'", want: "SELECT * FROM users WHERE name = ?"},
+		{query: "SELECT * FROM users WHERE score > -1.5e3", want: "SELECT * FROM users WHERE score > ?"},
+		{query: "SELECT * FROM users WHERE id IN (1, 2,3)", want: "SELECT * FROM users WHERE id IN (?)"},
+		{query: "SELECT * FROM users WHERE id IN (?, ?, ?)", want: "SELECT * FROM users WHERE id IN (?)"},
+		{query: "SELECT * FROM users WHERE id = $1 AND age > $2", want: "SELECT * FROM users WHERE id = $1 AND age > $2"},
+		{query: "SELECT t1.id FROM table1 t1 LIMIT 10", want: "SELECT t1.id FROM table1 t1 LIMIT ?"},
+		{query: "  SELECT\n\tid\n  FROM users  ", want: "SELECT id FROM users"},
+		{query: "INSERT INTO users (name, age) VALUES ('alice', 30), ('bob', 25)", want: "INSERT INTO users (name, age) VALUES (?), (?)"},
+	}
+
+	for _, tc := range cases {
+		if got := normalizeQuery(tc.query); got != tc.want {
+			t.Errorf("normalized query of %q is %q, want %q", tc.query, got, tc.want)
+		}
+	}
+}
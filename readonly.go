@@ -0,0 +1,40 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import "fmt"
+
+// readOnlySession returns statement switching the session of the driver to read only mode.
+func readOnlySession(driverName string) (string, error) {
+	switch driverName {
+	case "postgres", "pgx", "cloudsqlpostgres":
+		return "SET SESSION CHARACTERISTICS AS TRANSACTION READ ONLY", nil
+	case "mysql":
+		return "SET SESSION TRANSACTION READ ONLY", nil
+	case "sqlite", "sqlite3":
+		return "PRAGMA query_only = ON", nil
+	default:
+		return "", fmt.Errorf("read only slaves are not supported by %q driver", driverName)
+	}
+}
+
+// sessionStatements returns statements executed on every new physical connection of the node, the
+// configured init statements go last so they may override the registry ones.
+func sessionStatements(conf Config, node int) (statements []string, err error) {
+	if isSQLite(conf.Driver) {
+		statements = append(statements, sqlitePragmas(conf, node)...)
+	}
+
+	if conf.ReadOnlySlaves && node > 0 {
+		var statement string
+		if statement, err = readOnlySession(conf.Driver); err != nil {
+			return nil, err
+		}
+
+		statements = append(statements, statement)
+	}
+
+	return append(statements, conf.InitStatements...), nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type (
+	// RecentQuery describes the statement recently executed on the connection. Arguments are not
+	// captured, only their number, so they never leak to the support tooling.
+	RecentQuery struct {
+		Time     time.Time
+		Node     int
+		Role     string
+		Op       string
+		Query    string
+		NumArgs  int
+		Duration time.Duration
+		Err      error
+	}
+
+	// queryRing is ring buffer of the last executed statements.
+	queryRing struct {
+		mux     sync.Mutex
+		queries []RecentQuery
+		next    int
+		full    bool
+	}
+)
+
+// RecentQueries returns the last statements executed on the named connection, the oldest go first.
+// Nothing is captured for the connection without RecentQueries or not opened yet.
+func (r *Registry) RecentQueries(name string) ([]RecentQuery, error) {
+	r.mux.Lock()
+	var _, ok = r.conf[name]
+	var c = r.conns[name]
+	r.mux.Unlock()
+
+	if !ok {
+		return nil, ErrUnknownConnection
+	}
+
+	if c == nil {
+		return nil, nil
+	}
+
+	return c.recent.list(), nil
+}
+
+// newQueryRing returns ring buffer of the size or nil if the size is not positive.
+func newQueryRing(size int) *queryRing {
+	if size <= 0 {
+		return nil
+	}
+
+	return &queryRing{queries: make([]RecentQuery, size)}
+}
+
+func (q *queryRing) before(ctx context.Context, _ *QueryEvent) (context.Context, error) {
+	return ctx, nil
+}
+
+func (q *queryRing) after(_ context.Context, e *QueryEvent) {
+	if e.Op != OpExec && e.Op != OpQuery {
+		return
+	}
+
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	q.queries[q.next] = RecentQuery{
+		Time:     e.Start,
+		Node:     e.Node,
+		Role:     e.Role,
+		Op:       e.Op,
+		Query:    e.Query,
+		NumArgs:  len(e.Args),
+		Duration: e.Duration,
+		Err:      e.Err,
+	}
+
+	q.next = (q.next + 1) % len(q.queries)
+	q.full = q.full || q.next == 0
+}
+
+// list returns copy of the buffered statements, the oldest go first. Nil ring returns nil.
+func (q *queryRing) list() []RecentQuery {
+	if q == nil {
+		return nil
+	}
+
+	q.mux.Lock()
+	defer q.mux.Unlock()
+
+	if !q.full {
+		return append([]RecentQuery(nil), q.queries[:q.next]...)
+	}
+
+	var result = make([]RecentQuery, 0, len(q.queries))
+	result = append(result, q.queries[q.next:]...)
+
+	return append(result, q.queries[:q.next]...)
+}
@@ -0,0 +1,126 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// redacted replaces passwords in the redacted DSN.
+const redacted = "xxxxx"
+
+// redactedError is error whose message has the DSN passwords masked, the original error is available
+// via errors.Is and errors.As.
+type redactedError struct {
+	err error
+	msg string
+}
+
+var (
+	// passwordParamRegexp matches password in keyword/value pairs and URL query parameters.
+	passwordParamRegexp = regexp.MustCompile(`(?i)\b(password|passwd|pwd)(\s*=\s*)('(?:[^'\\]|\\.)*'|[^\s;&]*)`)
+
+	// urlUserinfoRegexp matches password of the URL which net/url fails to parse.
+	urlUserinfoRegexp = regexp.MustCompile(`(://[^:/@]*):[^@/]*@`)
+)
+
+// RedactDSN returns the DSN with passwords masked, it is safe to be logged or returned within errors.
+// URL, MySQL and keyword/value formats are supported, the format is detected when the driver name is
+// empty.
+func RedactDSN(driver, dsn string) string {
+	switch {
+	case driver == "mysql", driver == "" && isMySQLDSN(dsn):
+		return redactPasswordParams(redactMySQL(dsn))
+	case strings.Contains(dsn, "://"):
+		return redactPasswordParams(redactURL(dsn))
+	default:
+		return redactPasswordParams(dsn)
+	}
+}
+
+// String implements fmt.Stringer, the DSN password is masked.
+func (n Node) String() string {
+	return RedactDSN("", n.DSN)
+}
+
+// isMySQLDSN reports whether the DSN looks like [user[:password]@][net[(addr)]]/dbname one.
+func isMySQLDSN(dsn string) bool {
+	if strings.Contains(dsn, "://") {
+		return false
+	}
+
+	var slash = strings.LastIndex(dsn, "/")
+	if slash < 0 {
+		return false
+	}
+
+	var at = strings.LastIndex(dsn[:slash], "@")
+
+	return at >= 0 && !strings.ContainsAny(dsn[:at], "= ;")
+}
+
+// redactMySQL masks password of [user[:password]@][net[(addr)]]/dbname DSN.
+func redactMySQL(dsn string) string {
+	var slash = strings.LastIndex(dsn, "/")
+	if slash < 0 {
+		slash = len(dsn)
+	}
+
+	var at = strings.LastIndex(dsn[:slash], "@")
+	if at < 0 {
+		return dsn
+	}
+
+	var colon = strings.Index(dsn[:at], ":")
+	if colon < 0 {
+		return dsn
+	}
+
+	return dsn[:colon+1] + redacted + dsn[at:]
+}
+
+// redactURL masks the URL userinfo password.
+func redactURL(dsn string) string {
+	var u, err = url.Parse(dsn)
+	if err != nil {
+		return urlUserinfoRegexp.ReplaceAllString(dsn, "$1:"+redacted+"@")
+	}
+
+	return u.Redacted()
+}
+
+// redactPasswordParams masks password parameters.
+func redactPasswordParams(dsn string) string {
+	return passwordParamRegexp.ReplaceAllString(dsn, "${1}${2}"+redacted)
+}
+
+// redactError returns the error whose message has the DSNs replaced by the redacted ones, the error
+// is returned as is if its message does not contain them.
+func redactError(err error, driver string, dsn ...string) error {
+	var msg = err.Error()
+	for _, value := range dsn {
+		if value != "" {
+			msg = strings.ReplaceAll(msg, value, RedactDSN(driver, value))
+		}
+	}
+
+	if msg == err.Error() {
+		return err
+	}
+
+	return &redactedError{err: err, msg: msg}
+}
+
+// Error implements the error interface.
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+// Unwrap returns the original error.
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+// Package redis provide Redis store of the sql registry query cache.
+package redis
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	gzSQL "github.com/gozix/sql/v3"
+	"github.com/redis/go-redis/v9"
+)
+
+// Cache is query cache store keeping values in Redis, tags are Redis sets of the attached keys.
+type Cache struct {
+	client redis.UniversalClient
+	prefix string
+}
+
+// Cache implements the registry CacheStore interface.
+var _ gzSQL.CacheStore = (*Cache)(nil)
+
+// New returns query cache store of the client, every key is prefixed by the prefix.
+func New(client redis.UniversalClient, prefix string) *Cache {
+	return &Cache{client: client, prefix: prefix}
+}
+
+// Get implements the registry CacheStore interface.
+func (c *Cache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	var value, err = c.client.Get(ctx, c.prefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	return value, true, nil
+}
+
+// Set implements the registry CacheStore interface. Tag sets expire with the last attached key.
+func (c *Cache) Set(ctx context.Context, key string, value []byte, ttl time.Duration, tags []string) error {
+	var _, err = c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, c.prefix+key, value, ttl)
+
+		for _, tag := range tags {
+			pipe.SAdd(ctx, c.prefix+tag, c.prefix+key)
+			pipe.Expire(ctx, c.prefix+tag, ttl)
+		}
+
+		return nil
+	})
+
+	return err
+}
+
+// Invalidate implements the registry CacheStore interface.
+func (c *Cache) Invalidate(ctx context.Context, tags ...string) error {
+	for _, tag := range tags {
+		var keys, err = c.client.SMembers(ctx, c.prefix+tag).Result()
+		if err != nil {
+			return err
+		}
+
+		if _, err = c.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, key := range append(keys, c.prefix+tag) {
+				pipe.Del(ctx, key)
+			}
+
+			return nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
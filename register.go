@@ -0,0 +1,107 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrConnectionExists is error triggered when registered connection name is already taken.
+var ErrConnectionExists = errors.New("connection already exists")
+
+// Register adds the named connection. It is opened on first use or right away if the registry
+// was created with the WithEagerConnect option, in the latter case the connection is not added
+// when it fails to open.
+func (r *Registry) Register(name string, conf Config) (err error) {
+	for {
+		r.mux.Lock()
+		var candidate = conf.withDefaults(&r.shared).ordered().constrained()
+		if err = (Configs{name: candidate}).Validate(); err != nil {
+			r.mux.Unlock()
+			return err
+		}
+
+		if r.shutdown {
+			r.mux.Unlock()
+			return ErrRegistryShutdown
+		}
+
+		if _, ok := r.conf[name]; ok {
+			r.mux.Unlock()
+			return ErrConnectionExists
+		}
+
+		var (
+			version = r.confVersion
+			c       *connection
+		)
+
+		if r.eager && !candidate.external() {
+			// the connection is opened with the registry unlocked, so the rest are handed out meanwhile
+			r.mux.Unlock()
+
+			if c, err = r.openConfig(context.Background(), name, candidate); err != nil {
+				return fmt.Errorf("unable open %s connection : %w", name, err)
+			}
+
+			r.mux.Lock()
+			if r.shutdown || r.confVersion != version {
+				// the configuration is changed meanwhile, the registration is repeated against the current one
+				r.mux.Unlock()
+				_ = c.close()
+
+				continue
+			}
+		}
+
+		if c != nil {
+			r.conns[name] = c
+		}
+
+		var configs = make(Configs, len(r.conf)+1)
+		for key, value := range r.conf {
+			configs[key] = value
+		}
+
+		configs[name] = candidate
+		r.conf = configs
+		r.confVersion++
+		r.touch(name)
+		r.mux.Unlock()
+
+		return nil
+	}
+}
+
+// Unregister removes the named connection. The opened connection is no longer handed out and is
+// drained and closed in background.
+func (r *Registry) Unregister(name string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if _, ok := r.conf[name]; !ok {
+		return ErrUnknownConnection
+	}
+
+	if c, ok := r.conns[name]; ok {
+		delete(r.conns, name)
+		go r.drain(c)
+	}
+
+	var configs = make(Configs, len(r.conf))
+	for key, value := range r.conf {
+		if key != name {
+			configs[key] = value
+		}
+	}
+
+	r.conf = configs
+	r.confVersion++
+	r.touch(name)
+
+	return nil
+}
@@ -5,90 +5,433 @@
 package sql
 
 import (
+	"context"
+	"database/sql"
 	"errors"
-	"strings"
+	"fmt"
+	"sort"
 	"sync"
 	"time"
 
 	"github.com/iqoption/nap"
+	"github.com/prometheus/client_golang/prometheus"
 )
 
-// DEFAULT is default connection name.
-const DEFAULT = "default"
+const (
+	// DEFAULT is default connection name.
+	DEFAULT = "default"
+
+	// RoleMaster is role of the first connection node, it receives writes.
+	RoleMaster = "master"
+
+	// RoleSlave is role of the rest connection nodes, they receive reads.
+	RoleSlave = "slave"
+
+	// RoleReplica is configuration alias of RoleSlave.
+	RoleReplica = "replica"
+)
 
 type (
 	// Config is registry configuration item.
 	Config struct {
-		Nodes           []string                      `json:"nodes"`
-		Driver          string                        `json:"driver"`
-		MaxOpenConns    int                           `json:"max_open_conns"`
-		MaxIdleConns    int                           `json:"max_idle_conns"`
-		ConnMaxLifetime time.Duration                 `json:"conn_max_lifetime"`
-		AfterOpen       func(name string, db *nap.DB) `json:"-"`
+		Nodes           []string       `json:"nodes"`
+		NodeConfigs     Nodes          `json:"node_configs"`
+		Driver          string         `json:"driver"`
+		Backend         string         `json:"backend"`
+		MaxOpenConns    int            `json:"max_open_conns"`
+		MaxIdleConns    int            `json:"max_idle_conns"`
+		ConnMaxLifetime time.Duration  `json:"conn_max_lifetime"`
+		ConnMaxIdleTime time.Duration  `json:"conn_max_idle_time"`
+		OpenRetry       Retry          `json:"open_retry"`
+		TxRetry         Retry          `json:"tx_retry"`
+		TxIsolation     string         `json:"tx_isolation"`
+		TxReadOnly      bool           `json:"tx_read_only"`
+		CircuitBreaker  CircuitBreaker `json:"circuit_breaker"`
+		HealthMonitor   HealthMonitor  `json:"health_monitor"`
+		MaxReplicaLag   time.Duration  `json:"max_replica_lag"`
+		ReplicaLag      ReplicaLag     `json:"replica_lag"`
+		ReadPolicy      ReadPolicy     `json:"read_policy"`
+
+		// ReadWeights are the node read weights of the weighted read policy by node index, zero means
+		// the default weight 1 like zero Node.Weight does.
+		ReadWeights []int `json:"read_weights"`
+
+		MasterReadPercent           int                             `json:"master_read_percent"`
+		TLS                         *TLS                            `json:"tls"`
+		StmtCacheSize               int                             `json:"stmt_cache_size"`
+		ReadOnlySlaves              bool                            `json:"read_only_slaves"`
+		ReadYourWrites              bool                            `json:"read_your_writes"`
+		ReadYourWritesWindow        time.Duration                   `json:"read_your_writes_window"`
+		QueryTimeout                time.Duration                   `json:"query_timeout"`
+		ToleratePartialFailure      bool                            `json:"tolerate_partial_failure"`
+		StrictOpen                  bool                            `json:"strict_open"`
+		KeepaliveInterval           time.Duration                   `json:"keepalive_interval"`
+		DedicatedConnAcquireTimeout time.Duration                   `json:"dedicated_conn_acquire_timeout"`
+		LeakThreshold               time.Duration                   `json:"leak_threshold"`
+		AdaptivePool                AdaptivePool                    `json:"adaptive_pool"`
+		Profiles                    Profiles                        `json:"profiles"`
+		Concurrency                 Concurrency                     `json:"concurrency"`
+		CacheTTL                    time.Duration                   `json:"cache_ttl"`
+		InitStatements              []string                        `json:"init_statements"`
+		Discovery                   Discovery                       `json:"discovery"`
+		RoleProbe                   RoleProbe                       `json:"role_probe"`
+		PoolerMode                  bool                            `json:"pooler_mode"`
+		SlowQueryThreshold          time.Duration                   `json:"slow_query_threshold"`
+		RecentQueries               int                             `json:"recent_queries"`
+		DSNProvider                 DSNProvider                     `json:"-"`
+		Dialer                      DialFunc                        `json:"-"`
+		BeforeOpen                  func(name string, conf *Config) `json:"-"`
+		AfterOpen                   func(name string, db *nap.DB)   `json:"-"`
+		AfterClose                  func(name string)               `json:"-"`
+		BeforeQuery                 []BeforeQueryFunc               `json:"-"`
+		AfterQuery                  []AfterQueryFunc                `json:"-"`
+
+		// explicit are json names of the fields set by the configuration source, they keep their
+		// values, false and zero ones too, instead of taking the defaults.
+		explicit map[string]bool
 	}
 
+	// DSNProvider returns DSN of every connection node, the first one is master. It is invoked
+	// when the connection is opened and every time a new physical connection is established, so
+	// credentials fetched from a secrets manager may rotate. The result must keep the node count.
+	DSNProvider func(ctx context.Context, name string) ([]string, error)
+
 	// Configs are registry configurations.
 	Configs map[string]Config
 
+	// Option interface. Every registry option is a bundle option as well, it is passed to the registry
+	// constructed by the bundle.
+	Option interface {
+		BundleOption
+		apply(registry *Registry)
+	}
+
 	// Registry is database connection registry.
 	Registry struct {
-		mux  sync.Mutex
-		dbs  map[string]*nap.DB
-		conf Configs
+		mux   sync.Mutex
+		conns map[string]*connection
+		conf  Configs
+		eager bool
+		chain interceptors
+
+		// opening are the connections being opened on first use, gens are bumped on every change of
+		// the named connection, so the connections opened with the mutex released are installed only
+		// when nothing changed meanwhile, and confVersion is bumped on every configuration change.
+		opening     map[string]*openCall
+		gens        map[string]uint64
+		confVersion uint64
+
+		healthCheckOpen bool
+		slowQueryLogger SlowQueryLogger
+		logger          Logger
+		clock           Clock
+		defaults        *Config
+		pool            *Config
+		shared          Config
+		cacheStore      CacheStore
+
+		metrics         prometheus.Registerer
+		metricsInterval time.Duration
+		queryMetrics    *queryMetrics
+		txMetrics       *txMetrics
+		otelMetrics     *otelMetrics
+		statsd          *statsdExporter
+		expvarPrefix    string
+
+		done      chan struct{}
+		closeOnce sync.Once
+		shutdown  bool
+	}
+
+	// optionFunc wraps a func, so it satisfies the Option interface.
+	optionFunc func(registry *Registry)
+
+	// openCall is the connection being opened on first use, the callers of the same connection wait
+	// for it instead of opening their own.
+	openCall struct {
+		done chan struct{}
+		err  error
 	}
 )
 
 var (
 	// ErrUnknownConnection is error triggered when connection with provided name not founded.
 	ErrUnknownConnection = errors.New("unknown connection")
+
+	// ErrRegistryShutdown is error triggered when connection is requested after registry shutdown.
+	ErrRegistryShutdown = errors.New("registry is shut down")
 )
 
-// NewRegistry is registry constructor.
-func NewRegistry(conf Configs) (*Registry, error) {
-	return &Registry{
-		dbs:  make(map[string]*nap.DB),
-		conf: conf,
-	}, nil
+// NewRegistry is registry constructor, the registry dependencies are set by the options. The
+// configurations are validated up front, see Configs.Validate.
+func NewRegistry(conf Configs, options ...Option) (_ *Registry, err error) {
+	var r = Registry{
+		conns:   make(map[string]*connection),
+		opening: make(map[string]*openCall),
+		gens:    make(map[string]uint64),
+		logger:  nopLogger{},
+		clock:   systemClock{},
+		done:    make(chan struct{}),
+
+		cacheStore: NewMemoryCache(DefaultCacheSize),
+	}
+
+	for _, option := range options {
+		option.apply(&r)
+	}
+
+	r.conf, r.shared = conf.withDefaults(r.defaults, r.pool)
+	if err = r.conf.Validate(); err != nil {
+		return nil, err
+	}
+
+	if r.eager {
+		if err = r.connectAll(context.Background()); err != nil {
+			return nil, err
+		}
+	}
+
+	if r.metrics != nil {
+		var collector = newPrometheusCollector()
+		if err = r.metrics.Register(collector); err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+
+		var interval = r.metricsInterval
+		if interval <= 0 {
+			interval = DefaultMetricsInterval
+		}
+
+		go collector.run(&r, interval, r.done)
+	}
+
+	if r.queryMetrics != nil {
+		if err = r.queryMetrics.register(); err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+	}
+
+	if r.txMetrics != nil {
+		if err = r.txMetrics.register(); err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+	}
+
+	if r.otelMetrics != nil {
+		if err = r.otelMetrics.register(&r, r.done); err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+	}
+
+	if r.statsd != nil {
+		if err = r.statsd.open(); err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+
+		go r.statsd.run(&r, r.done)
+	}
+
+	if r.expvarPrefix != "" {
+		if err = publishExpvar(r.expvarPrefix, &r); err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+	}
+
+	return &r, nil
+}
+
+// WithEagerConnect option opens and pings every configured connection during registry
+// construction instead of lazily on first use.
+func WithEagerConnect() Option {
+	return optionFunc(func(r *Registry) {
+		r.eager = true
+	})
 }
 
 // Close is method for close connections.
 func (r *Registry) Close() (err error) {
+	r.closeOnce.Do(func() {
+		close(r.done)
+	})
+
 	r.mux.Lock()
 	defer r.mux.Unlock()
 
-	for key, db := range r.dbs {
-		if err = db.Close(); err != nil {
+	for name := range r.conf {
+		r.touch(name)
+	}
+
+	for key, c := range r.conns {
+		if err = c.close(); err != nil {
 			return err
 		}
 
-		delete(r.dbs, key)
+		delete(r.conns, key)
 	}
 
 	return nil
 }
 
+// CloseWithName closes the named connection leaving the rest opened. The connection is opened
+// again on next use like after Close.
+func (r *Registry) CloseWithName(name string) error {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	if _, ok := r.conf[name]; !ok {
+		return ErrUnknownConnection
+	}
+
+	r.touch(name)
+
+	var c, ok = r.conns[name]
+	if !ok {
+		return nil
+	}
+
+	delete(r.conns, name)
+
+	return c.close()
+}
+
+// Reconnect opens a fresh pool for the named connection and closes the existing one. Callers
+// holding the old pool get closed database errors, so they must request the connection again.
+// The existing pool is left untouched when the fresh one fails to open. The pool is opened with the
+// registry unlocked, so the other connections are handed out meanwhile.
+func (r *Registry) Reconnect(name string) (err error) {
+	for {
+		r.mux.Lock()
+		if r.shutdown {
+			r.mux.Unlock()
+			return ErrRegistryShutdown
+		}
+
+		var conf, ok = r.conf[name]
+		if !ok {
+			r.mux.Unlock()
+			return ErrUnknownConnection
+		}
+
+		var gen = r.gens[name]
+		r.mux.Unlock()
+
+		var c *connection
+		if c, err = r.openConfig(context.Background(), name, conf); err != nil {
+			return err
+		}
+
+		r.mux.Lock()
+		if r.shutdown || r.gens[name] != gen {
+			// the connection is changed meanwhile, it is reopened with the current configuration
+			r.mux.Unlock()
+			_ = c.close()
+			continue
+		}
+
+		var old, exists = r.conns[name]
+		r.conns[name] = c
+		r.touch(name)
+		r.mux.Unlock()
+
+		if exists {
+			return old.close()
+		}
+
+		return nil
+	}
+}
+
 // Connection is default connection getter.
 func (r *Registry) Connection() (*nap.DB, error) {
-	return r.ConnectionWithName(DEFAULT)
+	return r.ConnectionWithNameContext(context.Background(), DEFAULT)
+}
+
+// ConnectionContext is default connection getter with context.
+func (r *Registry) ConnectionContext(ctx context.Context) (*nap.DB, error) {
+	return r.ConnectionWithNameContext(ctx, DEFAULT)
 }
 
 // ConnectionWithName is connection getter by name.
-func (r *Registry) ConnectionWithName(name string) (_ *nap.DB, err error) {
-	r.mux.Lock()
-	defer r.mux.Unlock()
+func (r *Registry) ConnectionWithName(name string) (*nap.DB, error) {
+	return r.ConnectionWithNameContext(context.Background(), name)
+}
 
-	if db, ok := r.dbs[name]; ok {
-		return db, nil
+// ConnectionWithNameContext is connection getter by name with context. The context is used
+// while connection is opened and pinged, so the caller deadline and cancellation are respected.
+func (r *Registry) ConnectionWithNameContext(ctx context.Context, name string) (_ *nap.DB, err error) {
+	var c *connection
+	if c, err = r.connection(ctx, name); err != nil {
+		return nil, err
 	}
 
-	var db *nap.DB
-	if db, err = r.open(name); err != nil {
+	return c.db, nil
+}
+
+// Slave is default connection slave node getter.
+func (r *Registry) Slave() (*sql.DB, error) {
+	return r.SlaveWithNameContext(context.Background(), DEFAULT)
+}
+
+// SlaveContext is default connection slave node getter with context.
+func (r *Registry) SlaveContext(ctx context.Context) (*sql.DB, error) {
+	return r.SlaveWithNameContext(ctx, DEFAULT)
+}
+
+// SlaveWithName is slave node getter by connection name.
+func (r *Registry) SlaveWithName(name string) (*sql.DB, error) {
+	return r.SlaveWithNameContext(context.Background(), name)
+}
+
+// SlaveWithNameContext is slave node getter by connection name with context. Unlike the nap
+// round-robin, the node is chosen by the connection read policy and slave nodes removed from
+// rotation by the circuit breaker are skipped. The master node is returned when the context forces
+// it, see ForceMaster, or the context session made a write recently, see ContextWithSession.
+func (r *Registry) SlaveWithNameContext(ctx context.Context, name string) (_ *sql.DB, err error) {
+	var c *connection
+	if c, err = r.connection(ctx, name); err != nil {
+		return nil, err
+	}
+
+	return c.read(ctx), nil
+}
+
+// Master is master node getter by connection name.
+func (r *Registry) Master(name string) (*sql.DB, error) {
+	return r.MasterContext(context.Background(), name)
+}
+
+// MasterContext is master node getter by connection name with context.
+func (r *Registry) MasterContext(ctx context.Context, name string) (_ *sql.DB, err error) {
+	var c *connection
+	if c, err = r.connection(ctx, name); err != nil {
+		return nil, err
+	}
+
+	return c.db.Master(), nil
+}
+
+// Slaves is slave nodes getter by connection name. Every slave node is returned in the configuration
+// order regardless of the read rotation, the result is empty if the connection has no slaves.
+func (r *Registry) Slaves(name string) ([]*sql.DB, error) {
+	return r.SlavesContext(context.Background(), name)
+}
+
+// SlavesContext is slave nodes getter by connection name with context.
+func (r *Registry) SlavesContext(ctx context.Context, name string) (_ []*sql.DB, err error) {
+	var c *connection
+	if c, err = r.connection(ctx, name); err != nil {
 		return nil, err
 	}
 
-	r.dbs[name] = db
+	var nodes = c.db.Databases()
 
-	return r.dbs[name], nil
+	return append([]*sql.DB(nil), nodes[1:]...), nil
 }
 
 // Driver is default connection driver name getter.
@@ -109,26 +452,361 @@ func (r *Registry) DriverWithName(name string) (string, error) {
 
 }
 
-func (r *Registry) open(name string) (db *nap.DB, err error) {
-	var conf, ok = r.conf[name]
-	if !ok {
-		return nil, ErrUnknownConnection
+// Names returns sorted names of the configured connections.
+func (r *Registry) Names() []string {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	var names = make([]string, 0, len(r.conf))
+	for name := range r.conf {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// ForEach invokes fn for every opened connection in name order, connections are not opened by
+// it. Iteration is stopped on the first error, which is returned.
+func (r *Registry) ForEach(fn func(name string, db *nap.DB) error) (err error) {
+	r.mux.Lock()
+	var dbs = make(map[string]*nap.DB, len(r.conns))
+	for name, c := range r.conns {
+		dbs[name] = c.db
 	}
-	if db, err = nap.Open(conf.Driver, strings.Join(conf.Nodes, ";")); err != nil {
+	r.mux.Unlock()
+
+	var names = make([]string, 0, len(dbs))
+	for name := range dbs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err = fn(name, dbs[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WithMetrics option enables pool statistics sampling for every node of every opened connection,
+// the statistics are exported via the registerer. Zero interval means DefaultMetricsInterval.
+func WithMetrics(registerer prometheus.Registerer, interval time.Duration) Option {
+	return optionFunc(func(r *Registry) {
+		r.metrics = registerer
+		r.metricsInterval = interval
+	})
+}
+
+// WithHealthCheckOpen option makes HealthCheck open connections that are not opened yet
+// instead of skipping them.
+func WithHealthCheckOpen() Option {
+	return optionFunc(func(r *Registry) {
+		r.healthCheckOpen = true
+	})
+}
+
+// connection returns opened connection by name, the connection is opened if needed. It is opened
+// with the registry unlocked, so a slow or unreachable connection doesn't block the others, and the
+// concurrent callers of the same connection wait for a single open.
+func (r *Registry) connection(ctx context.Context, name string) (c *connection, err error) {
+	for {
+		r.mux.Lock()
+		if r.shutdown {
+			r.mux.Unlock()
+			return nil, ErrRegistryShutdown
+		}
+
+		var ok bool
+		if c, ok = r.conns[name]; ok {
+			r.mux.Unlock()
+			return c, nil
+		}
+
+		if call, ok := r.opening[name]; ok {
+			r.mux.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-call.done:
+			}
+
+			// the open aborted by the context of another caller is repeated
+			if call.err != nil && !isContextError(call.err) {
+				return nil, call.err
+			}
+
+			continue
+		}
+
+		var conf Config
+		if conf, ok = r.conf[name]; !ok {
+			r.mux.Unlock()
+			return nil, ErrUnknownConnection
+		}
+
+		var (
+			call = &openCall{done: make(chan struct{})}
+			gen  = r.gens[name]
+		)
+
+		r.opening[name] = call
+		r.mux.Unlock()
+
+		c, call.err = r.openConfig(ctx, name, conf)
+
+		r.mux.Lock()
+		delete(r.opening, name)
+
+		var installed = call.err == nil && !r.shutdown && r.gens[name] == gen
+		if installed {
+			r.conns[name] = c
+		}
+
+		close(call.done)
+		r.mux.Unlock()
+
+		switch {
+		case call.err != nil:
+			return nil, call.err
+		case installed:
+			return c, nil
+		}
+
+		// the connection is changed meanwhile, it is opened again with the current configuration
+		_ = c.close()
+	}
+}
+
+// touch marks the named connection changed, the mutex must be held.
+func (r *Registry) touch(name string) {
+	r.gens[name]++
+}
+
+// isContextError reports whether the error is caused by the canceled or expired context.
+func isContextError(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// connectAll opens every connection of the registry the way the getters do, so the registry is unlocked
+// while the connections are opened. The opened connections are closed when any of them fails.
+func (r *Registry) connectAll(ctx context.Context) (err error) {
+	r.mux.Lock()
+	var names = make([]string, 0, len(r.conf))
+	for name, conf := range r.conf {
+		if !conf.external() {
+			names = append(names, name)
+		}
+	}
+
+	r.mux.Unlock()
+
+	for _, name := range names {
+		if _, err = r.connection(ctx, name); err != nil {
+			r.mux.Lock()
+			for key, opened := range r.conns {
+				_ = opened.close()
+				delete(r.conns, key)
+			}
+
+			r.mux.Unlock()
+
+			return fmt.Errorf("unable open %s connection : %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// openConfig opens connection with provided configuration, the BeforeOpen hook receives a copy of it.
+func (r *Registry) openConfig(ctx context.Context, name string, conf Config) (c *connection, err error) {
+	if conf.BeforeOpen != nil {
+		conf.BeforeOpen(name, &conf)
+	}
+
+	if conf.external() {
+		return nil, ErrUnsupportedBackend
+	}
+
+	if err = conf.ReadPolicy.validate(); err != nil {
+		return nil, err
+	}
+
+	if _, err = parseIsolation(conf.TxIsolation); err != nil {
 		return nil, err
 	}
 
-	db.SetMaxOpenConns(conf.MaxOpenConns)
-	db.SetMaxIdleConns(conf.MaxIdleConns)
-	db.SetConnMaxLifetime(conf.ConnMaxLifetime)
+	var attempt int
+	err = conf.OpenRetry.Do(ctx, func(ctx context.Context) (err error) {
+		attempt++
+		if c, err = r.dial(ctx, name, conf); err != nil {
+			r.logger.Error("unable open connection", err, connectionKeyvals(name, err, "attempt", attempt)...)
+		}
+
+		return err
+	})
 
-	if err = db.Ping(); err != nil {
+	if err != nil {
 		return nil, err
 	}
 
+	c.opened = true
+	r.logger.Info("connection opened", "connection", name, "nodes", len(c.db.Databases()))
+
 	if conf.AfterOpen != nil {
-		conf.AfterOpen(name, db)
+		conf.AfterOpen(name, c.db)
+	}
+
+	return c, nil
+}
+
+// dial opens and pings every node of the connection.
+func (r *Registry) dial(ctx context.Context, name string, conf Config) (_ *connection, err error) {
+	var endpoints []string
+	if conf, endpoints, err = conf.withDiscovered(ctx); err != nil {
+		return nil, connectionError(name, -1, OpResolve, err)
+	}
+
+	var dsn = conf.NodeConfigs.DSNs()
+	if conf.DSNProvider != nil {
+		if dsn, err = conf.DSNProvider(ctx, name); err != nil {
+			return nil, connectionError(name, -1, OpResolve, err)
+		}
+	}
+
+	var c = newConnection(name, conf, len(dsn), r.clock, r.logger)
+	c.cache = newQueryCache(name, conf.CacheTTL, r.cacheStore, r.logger)
+
+	var (
+		chain = c.interceptors(r.chain, r.slowQueryLogger)
+		nodes = make([]*sql.DB, 0, len(dsn))
+	)
+
+	for i := range dsn {
+		var (
+			node *sql.DB
+			info = nodeInfo{
+				connection:   name,
+				driver:       conf.Driver,
+				node:         i,
+				role:         nodeRole(i),
+				queryTimeout: conf.QueryTimeout,
+				leaks:        c.leaks,
+				cache:        c.cache,
+			}
+		)
+
+		var raw = dsn[i]
+		if info.session, err = sessionStatements(conf, i); err == nil {
+			dsn[i], err = c.nodeDSN(i, dsn[i])
+		}
+
+		if err == nil {
+			node, err = openNode(info, dsn[i], c.resolver(i), chain)
+		}
+
+		if err != nil {
+			closeNodes(nodes)
+			_ = c.close()
+			return nil, connectionError(name, i, OpOpen, redactError(err, conf.Driver, dsn[i], raw))
+		}
+
+		if b := c.breakers[i]; b != nil {
+			b.node = node
+		}
+
+		var n Node
+		if i < len(conf.NodeConfigs) {
+			n = conf.NodeConfigs[i]
+		}
+
+		n.apply(node, conf)
+
+		nodes = append(nodes, node)
+	}
+
+	if c.db, err = nap.Wrap(nodes...); err != nil {
+		_ = c.close()
+		return nil, connectionError(name, -1, OpOpen, err)
+	}
+
+	var failed []*ConnectionError
+	switch {
+	case conf.ToleratePartialFailure:
+		failed, err = c.pingMaster(ctx)
+	case conf.StrictOpen:
+		err = c.pingAll(ctx)
+	default:
+		err = c.ping(ctx)
 	}
 
-	return db, nil
+	if err != nil {
+		_ = c.close()
+		return nil, err
+	}
+
+	var health = conf.HealthMonitor
+	if conf.ToleratePartialFailure && health.Interval <= 0 {
+		health.Interval = DefaultHealthMonitorInterval
+	}
+
+	if c.monitor = newMonitor(health, nodes, c.evictions("health monitor")); c.monitor != nil {
+		for _, e := range failed {
+			c.monitor.fail(e.Node, e)
+		}
+
+		go c.monitor.run(c.done)
+	}
+
+	if c.lag = newLagMonitor(conf, nodes, c.evictions("replica lag")); c.lag != nil {
+		go c.lag.run(c.done)
+	}
+
+	if conf.KeepaliveInterval > 0 {
+		go c.keepalive(conf.KeepaliveInterval)
+	}
+
+	if c.leaks != nil {
+		go c.leaks.run(c.logger, c.done)
+	}
+
+	if p := newAdaptivePool(name, conf.AdaptivePool, nodes, c.logger); p != nil {
+		go p.run(c.done)
+	}
+
+	if conf.Discovery.enabled() {
+		go r.watch(c, endpoints)
+	}
+
+	if conf.RoleProbe.Interval > 0 {
+		go r.probeRoles(c, nodes)
+	}
+
+	return c, nil
+}
+
+// closeNodes closes the node pools ignoring errors, it is used to release partially opened connection.
+func closeNodes(nodes []*sql.DB) {
+	for _, node := range nodes {
+		_ = node.Close()
+	}
+}
+
+// nodeRole returns role of the connection node by index.
+func nodeRole(i int) string {
+	if i == 0 {
+		return RoleMaster
+	}
+
+	return RoleSlave
+}
+
+// apply implements Option.
+func (f optionFunc) apply(registry *Registry) {
+	f(registry)
 }
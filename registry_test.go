@@ -0,0 +1,203 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	gzSQL "github.com/gozix/sql/v3"
+	"github.com/gozix/sql/v3/sqltest"
+)
+
+func newMockRegistry(t *testing.T, names ...string) *sqltest.MockRegistry {
+	t.Helper()
+
+	var registry, err = sqltest.NewMockRegistry(names...)
+	if err != nil {
+		t.Fatalf("unable create registry : %v", err)
+	}
+
+	t.Cleanup(func() {
+		_ = registry.Close()
+	})
+
+	return registry
+}
+
+func TestRegistryOpen(t *testing.T) {
+	var registry = newMockRegistry(t, "master", "reporting")
+
+	var cases = []struct {
+		name string
+		err  error
+	}{
+		{name: "master"},
+		{name: "reporting"},
+		{name: "unknown", err: gzSQL.ErrUnknownConnection},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var db, err = registry.ConnectionWithName(tc.name)
+			if !errors.Is(err, tc.err) {
+				t.Fatalf("error is %v, want %v", err, tc.err)
+			}
+
+			if tc.err != nil {
+				return
+			}
+
+			registry.Mock(tc.name).ExpectQuery("SELECT 1").WillReturnRows(sqlmock.NewRows([]string{"one"}).AddRow(1))
+
+			var one int
+			if err = db.Master().QueryRowContext(context.Background(), "SELECT 1").Scan(&one); err != nil {
+				t.Fatalf("unable query : %v", err)
+			}
+
+			if again, _ := registry.ConnectionWithName(tc.name); again != db {
+				t.Error("connection is opened twice")
+			}
+
+			if err = registry.Mock(tc.name).ExpectationsWereMet(); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+}
+
+func TestRegistryOpenConcurrently(t *testing.T) {
+	var (
+		registry = newMockRegistry(t)
+		wg       sync.WaitGroup
+		dbs      = make(chan interface{}, 8)
+	)
+
+	for i := 0; i < cap(dbs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			var db, err = registry.Connection()
+			if err != nil {
+				t.Error(err)
+			}
+
+			dbs <- db
+		}()
+	}
+
+	wg.Wait()
+	close(dbs)
+
+	var first = <-dbs
+	for db := range dbs {
+		if db != first {
+			t.Fatal("concurrent opens returned different connections")
+		}
+	}
+}
+
+func TestRegistryClose(t *testing.T) {
+	var registry = newMockRegistry(t, "master", "reporting")
+
+	var master, _ = registry.ConnectionWithName("master")
+	var reporting, _ = registry.ConnectionWithName("reporting")
+
+	if err := registry.CloseWithName("master"); err != nil {
+		t.Fatalf("unable close connection : %v", err)
+	}
+
+	if err := master.Master().Ping(); err == nil {
+		t.Error("closed connection is usable")
+	}
+
+	if err := reporting.Master().Ping(); err != nil {
+		t.Errorf("other connection is closed : %v", err)
+	}
+
+	var reopened, err = registry.ConnectionWithName("master")
+	if err != nil {
+		t.Fatalf("unable reopen connection : %v", err)
+	}
+
+	if reopened == master {
+		t.Error("closed connection is handed out")
+	}
+
+	if err = registry.Close(); err != nil {
+		t.Fatalf("unable close registry : %v", err)
+	}
+
+	if err = reporting.Master().Ping(); err == nil {
+		t.Error("connection is usable after registry close")
+	}
+}
+
+func TestRegistryReconnect(t *testing.T) {
+	var registry = newMockRegistry(t)
+
+	var cases = []struct {
+		name string
+		err  error
+	}{
+		{name: gzSQL.DEFAULT},
+		{name: "unknown", err: gzSQL.ErrUnknownConnection},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var old, _ = registry.ConnectionWithName(tc.name)
+
+			if err := registry.Reconnect(tc.name); !errors.Is(err, tc.err) {
+				t.Fatalf("error is %v, want %v", err, tc.err)
+			}
+
+			if tc.err != nil {
+				return
+			}
+
+			var fresh, err = registry.ConnectionWithName(tc.name)
+			if err != nil {
+				t.Fatalf("unable get reconnected connection : %v", err)
+			}
+
+			if fresh == old {
+				t.Fatal("connection is not replaced")
+			}
+
+			if err = old.Master().Ping(); err == nil {
+				t.Error("replaced connection is not closed")
+			}
+
+			if err = fresh.Master().Ping(); err != nil {
+				t.Errorf("reconnected connection is unusable : %v", err)
+			}
+		})
+	}
+}
+
+func TestRegistryShutdown(t *testing.T) {
+	var registry = newMockRegistry(t)
+
+	if _, err := registry.Connection(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := registry.Shutdown(context.Background()); err != nil {
+		t.Fatalf("unable shutdown : %v", err)
+	}
+
+	if _, err := registry.Connection(); !errors.Is(err, gzSQL.ErrRegistryShutdown) {
+		t.Errorf("error is %v, want %v", err, gzSQL.ErrRegistryShutdown)
+	}
+
+	if err := registry.Reconnect(gzSQL.DEFAULT); !errors.Is(err, gzSQL.ErrRegistryShutdown) {
+		t.Errorf("reconnect error is %v, want %v", err, gzSQL.ErrRegistryShutdown)
+	}
+}
@@ -0,0 +1,169 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// Reload replaces the registry configuration. Connections whose configuration is untouched are
+// left alone, removed and changed connections are no longer handed out and are drained and
+// closed in background, changed and added connections are opened on first use or right away
+// if the registry was created with the WithEagerConnect option. In the latter case nothing is
+// changed when any connection fails to open. Hooks are not compared, so a connection that
+// differs by hooks only is not reopened. Invalid configurations are rejected as a whole.
+func (r *Registry) Reload(conf Configs) (err error) {
+	var shared Config
+	conf, shared = conf.withDefaults(r.defaults, r.pool)
+	if err = conf.Validate(); err != nil {
+		return err
+	}
+
+	for {
+		r.mux.Lock()
+		if r.shutdown {
+			r.mux.Unlock()
+			return ErrRegistryShutdown
+		}
+
+		var (
+			version = r.confVersion
+			pending = r.reloaded(conf)
+			opened  = make(map[string]*connection, len(pending))
+		)
+
+		if !r.eager || len(pending) == 0 {
+			r.applyReload(conf, shared, opened)
+			r.mux.Unlock()
+
+			return nil
+		}
+
+		r.mux.Unlock()
+
+		// the connections are opened with the registry unlocked, so the rest are handed out meanwhile
+		for _, name := range pending {
+			var c *connection
+			if c, err = r.openConfig(context.Background(), name, conf[name]); err != nil {
+				closeConnections(opened)
+				return fmt.Errorf("unable open %s connection : %w", name, err)
+			}
+
+			opened[name] = c
+		}
+
+		r.mux.Lock()
+		switch {
+		case r.shutdown:
+			r.mux.Unlock()
+			closeConnections(opened)
+
+			return ErrRegistryShutdown
+		case r.confVersion != version:
+			// the configuration is changed meanwhile, the reload is repeated against the current one
+			r.mux.Unlock()
+			closeConnections(opened)
+
+			continue
+		}
+
+		r.applyReload(conf, shared, opened)
+		r.mux.Unlock()
+
+		return nil
+	}
+}
+
+// reloaded returns names of the connections opened by the reload in eager mode: the added ones and
+// the ones whose configuration is changed. The mutex must be held.
+func (r *Registry) reloaded(conf Configs) (names []string) {
+	for name, value := range conf {
+		if value.external() {
+			continue
+		}
+
+		if _, ok := r.conns[name]; ok && equalConfigs(r.conf[name], value) {
+			continue
+		}
+
+		names = append(names, name)
+	}
+
+	return names
+}
+
+// applyReload replaces the configuration and installs the connections opened by the reload, the
+// removed and the changed connections are drained in background. The mutex must be held.
+func (r *Registry) applyReload(conf Configs, shared Config, opened map[string]*connection) {
+	for name, value := range r.conf {
+		if next, ok := conf[name]; !ok || !equalConfigs(value, next) {
+			r.touch(name)
+		}
+	}
+
+	for name := range conf {
+		if _, ok := r.conf[name]; !ok {
+			r.touch(name)
+		}
+	}
+
+	for name, c := range r.conns {
+		if value, ok := conf[name]; !ok || !equalConfigs(r.conf[name], value) {
+			delete(r.conns, name)
+			go r.drain(c)
+		}
+	}
+
+	for name, c := range opened {
+		// the connection is opened on first use meanwhile
+		if _, ok := r.conns[name]; ok {
+			_ = c.close()
+			continue
+		}
+
+		r.conns[name] = c
+	}
+
+	r.conf = conf
+	r.shared = shared
+	r.confVersion++
+}
+
+// closeConnections closes the connections ignoring errors, it is used to release the connections
+// opened for the aborted change.
+func closeConnections(conns map[string]*connection) {
+	for _, c := range conns {
+		_ = c.close()
+	}
+}
+
+// drain drains and closes the connection removed from the registry. The connection is closed
+// right away when the registry is closed.
+func (r *Registry) drain(c *connection) {
+	var ctx, cancel = context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-r.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := c.drain(ctx); err != nil {
+		r.logger.Error("unable close drained connection", err, "connection", c.name)
+	}
+}
+
+// equalConfigs reports whether serializable parts of the configurations are equal.
+func equalConfigs(a, b Config) bool {
+	var left, lErr = json.Marshal(a)
+	var right, rErr = json.Marshal(b)
+
+	return lErr == nil && rErr == nil && string(left) == string(right)
+}
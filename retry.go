@@ -0,0 +1,203 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/gozix/sql/v3/sqlerr"
+)
+
+type (
+	// Retry is retry with exponential backoff configuration.
+	Retry struct {
+		// Attempts is total number of attempts, zero or one means no retries.
+		Attempts int `json:"attempts"`
+
+		// InitialDelay is delay before the first retry, it is doubled on every next retry.
+		InitialDelay time.Duration `json:"initial_delay"`
+
+		// MaxDelay limits the delay between retries, zero means no limit.
+		MaxDelay time.Duration `json:"max_delay"`
+
+		// Jitter is fraction of the delay in range [0, 1] randomly added to or subtracted from it.
+		Jitter float64 `json:"jitter"`
+	}
+
+	// RetryableFunc reports whether the failed statement or transaction may succeed when it is repeated.
+	RetryableFunc func(err error) bool
+
+	// RetryPolicy is retry of the statements and the transactions failed with retryable errors.
+	RetryPolicy struct {
+		// Retry is the attempts and the backoff between them.
+		Retry Retry
+
+		// Retryable classifies the errors, nil means every error is retryable.
+		Retryable RetryableFunc
+	}
+
+	// retryPolicyKey is context key of the connection retry policy.
+	retryPolicyKey struct {
+		name string
+	}
+)
+
+// Do invokes fn until it succeeds, attempts are exhausted or the context is done. Once the context is
+// done while waiting for the next attempt, the context error wrapped with the last error is returned.
+func (c Retry) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return c.do(ctx, nil, fn)
+}
+
+// DefaultRetryPolicy returns retry policy of the driver, DefaultTxRetry attempts of the statements
+// failed with the driver serialization failures, deadlocks and lock timeouts, see DriverRetryable.
+func DefaultRetryPolicy(driverName string) RetryPolicy {
+	return RetryPolicy{Retry: DefaultTxRetry, Retryable: DriverRetryable(driverName)}
+}
+
+// DriverRetryable returns classifier of the driver retryable errors: SQLSTATE 40001, 40P01 and 55P03
+// on Postgres, errors 1213 and 1205 and SQLSTATE 40001 on MySQL, sqlerr.Retryable for other drivers.
+func DriverRetryable(driverName string) RetryableFunc {
+	switch driverName {
+	case "postgres", "pgx", "cloudsqlpostgres":
+		return func(err error) bool {
+			var state interface{ SQLState() string }
+			if !errors.As(err, &state) {
+				return false
+			}
+
+			switch state.SQLState() {
+			case "40001", "40P01", "55P03":
+				return true
+			default:
+				return false
+			}
+		}
+	case "mysql":
+		return func(err error) bool {
+			var myErr *mysql.MySQLError
+			if !errors.As(err, &myErr) {
+				return false
+			}
+
+			// ER_LOCK_DEADLOCK and ER_LOCK_WAIT_TIMEOUT
+			return myErr.Number == 1213 || myErr.Number == 1205 || string(myErr.SQLState[:]) == "40001"
+		}
+	default:
+		return sqlerr.Retryable
+	}
+}
+
+// ContextWithRetryPolicy returns context whose transactions of WithTx and statements of ExecWithRetry
+// executed on the connection are retried by the policy instead of the connection one.
+func ContextWithRetryPolicy(ctx context.Context, name string, policy RetryPolicy) context.Context {
+	return context.WithValue(ctx, retryPolicyKey{name: name}, policy)
+}
+
+// RetryPolicy returns retry policy of the connection: TxRetry attempts, DefaultTxRetry ones when they
+// are not configured, of the connection driver retryable errors.
+func (r *Registry) RetryPolicy(name string) (RetryPolicy, error) {
+	r.mux.Lock()
+	var conf, ok = r.conf[name]
+	r.mux.Unlock()
+
+	if !ok {
+		return RetryPolicy{}, ErrUnknownConnection
+	}
+
+	return conf.retryPolicy(context.Background(), name), nil
+}
+
+// ExecWithRetry executes the statement on the connection master node, the statement is retried by
+// the context or the connection retry policy, so it must be safe to repeat. The statement of the
+// ambient transaction is not retried, the whole transaction is.
+func (r *Registry) ExecWithRetry(ctx context.Context, name, query string, args ...interface{}) (result sql.Result, err error) {
+	if tx, ok := TxFromContext(ctx, name); ok {
+		return tx.ExecContext(ctx, query, args...)
+	}
+
+	var c *connection
+	if c, err = r.connection(ctx, name); err != nil {
+		return nil, err
+	}
+
+	err = c.conf.retryPolicy(ctx, name).Do(ctx, func(ctx context.Context) (err error) {
+		result, err = c.db.Master().ExecContext(ctx, query, args...)
+		return err
+	})
+
+	return result, err
+}
+
+// Do invokes fn until it succeeds, attempts are exhausted, the context is done or the error is not
+// retryable.
+func (p RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return p.Retry.do(ctx, p.Retryable, fn)
+}
+
+// retryPolicy returns the retry policy of the context or the connection one.
+func (c Config) retryPolicy(ctx context.Context, name string) RetryPolicy {
+	if policy, ok := ctx.Value(retryPolicyKey{name: name}).(RetryPolicy); ok {
+		return policy
+	}
+
+	var policy = DefaultRetryPolicy(c.Driver)
+	if c.TxRetry.Attempts != 0 {
+		policy.Retry = c.TxRetry
+	}
+
+	return policy
+}
+
+// do invokes fn until it succeeds, attempts are exhausted, the context is done or the error is not
+// retryable. Nil retryable means every error is retryable.
+func (c Retry) do(ctx context.Context, retryable func(err error) bool, fn func(ctx context.Context) error) (err error) {
+	for attempt := 1; ; attempt++ {
+		if err = fn(ctx); err == nil || attempt >= c.Attempts {
+			return err
+		}
+
+		if retryable != nil && !retryable(err) {
+			return err
+		}
+
+		var timer = time.NewTimer(c.delay(attempt))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("unable retry %v : %w", err, ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// delay returns the delay before the retry following the attempt.
+func (c Retry) delay(attempt int) time.Duration {
+	var delay = c.InitialDelay
+	for i := 1; i < attempt && (c.MaxDelay <= 0 || delay < c.MaxDelay); i++ {
+		delay *= 2
+	}
+
+	if c.MaxDelay > 0 && delay > c.MaxDelay {
+		delay = c.MaxDelay
+	}
+
+	if c.Jitter > 0 {
+		var jitter = c.Jitter
+		if jitter > 1 {
+			jitter = 1
+		}
+
+		delay += time.Duration(float64(delay) * jitter * (2*rand.Float64() - 1)) //nolint:gosec
+	}
+
+	return delay
+}
@@ -0,0 +1,132 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// sqliteError is SQLite driver error exposing the extended result code.
+type sqliteError int
+
+// Error implements the error interface.
+func (e sqliteError) Error() string {
+	return fmt.Sprintf("sqlite error %d", int(e))
+}
+
+// Code returns the result code.
+func (e sqliteError) Code() int {
+	return int(e)
+}
+
+// mysqlError returns MySQL error of the number and SQLSTATE.
+func mysqlError(number uint16, state string) error {
+	var err = mysql.MySQLError{Number: number}
+	copy(err.SQLState[:], state)
+
+	return &err
+}
+
+func TestDriverRetryable(t *testing.T) {
+	var cases = []struct {
+		driver string
+		err    error
+		want   bool
+	}{
+		{driver: "pgx", err: &pgconn.PgError{Code: "40001"}, want: true},
+		{driver: "postgres", err: &pgconn.PgError{Code: "40P01"}, want: true},
+		{driver: "cloudsqlpostgres", err: &pgconn.PgError{Code: "55P03"}, want: true},
+		{driver: "pgx", err: fmt.Errorf("commit : %w", &pgconn.PgError{Code: "40001"}), want: true},
+		{driver: "pgx", err: &pgconn.PgError{Code: "23505"}, want: false},
+		{driver: "pgx", err: errors.New("serialization failure"), want: false},
+		{driver: "mysql", err: mysqlError(1213, "40001"), want: true},
+		{driver: "mysql", err: mysqlError(1205, "HY000"), want: true},
+		{driver: "mysql", err: mysqlError(3101, "40001"), want: true},
+		{driver: "mysql", err: mysqlError(1062, "23000"), want: false},
+		{driver: "mysql", err: &pgconn.PgError{Code: "40001"}, want: false},
+		{driver: "sqlite3", err: sqliteError(5), want: true},
+		{driver: "sqlite3", err: errors.New("database is locked"), want: true},
+		{driver: "sqlite3", err: sqliteError(2067), want: false},
+		{driver: "clickhouse", err: errors.New("timeout"), want: false},
+	}
+
+	for _, tc := range cases {
+		if got := DriverRetryable(tc.driver)(tc.err); got != tc.want {
+			t.Errorf("%s retryable of %v is %t, want %t", tc.driver, tc.err, got, tc.want)
+		}
+	}
+}
+
+func TestRetryPolicyDo(t *testing.T) {
+	var (
+		retryable = &pgconn.PgError{Code: "40001"}
+		permanent = &pgconn.PgError{Code: "23505"}
+	)
+
+	var cases = []struct {
+		name     string
+		attempts int
+		errs     []error
+		calls    int
+		err      error
+	}{
+		{name: "success", attempts: 3, errs: []error{nil}, calls: 1},
+		{name: "retried", attempts: 3, errs: []error{retryable, retryable, nil}, calls: 3},
+		{name: "exhausted", attempts: 2, errs: []error{retryable, retryable, nil}, calls: 2, err: retryable},
+		{name: "permanent", attempts: 3, errs: []error{permanent, nil}, calls: 1, err: permanent},
+		{name: "no retries", attempts: 0, errs: []error{retryable, nil}, calls: 1, err: retryable},
+		{name: "canceled", attempts: 3, errs: []error{retryable, nil}, calls: 1, err: context.Canceled},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				policy = DefaultRetryPolicy("pgx")
+				calls  int
+			)
+
+			policy.Retry = Retry{Attempts: tc.attempts, InitialDelay: time.Microsecond}
+			if tc.err == context.Canceled {
+				policy.Retry.InitialDelay = time.Hour
+			}
+
+			var ctx, cancel = context.WithCancel(context.Background())
+			defer cancel()
+
+			var err = policy.Do(ctx, func(context.Context) error {
+				calls++
+				if tc.err == context.Canceled {
+					cancel()
+				}
+
+				return tc.errs[calls-1]
+			})
+
+			if !errors.Is(err, tc.err) || err == nil && tc.err != nil {
+				t.Errorf("error is %v, want %v", err, tc.err)
+			}
+
+			if calls != tc.calls {
+				t.Errorf("called %d times, want %d", calls, tc.calls)
+			}
+		})
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	var retry = Retry{InitialDelay: 10 * time.Millisecond, MaxDelay: 35 * time.Millisecond}
+	for attempt, want := range []time.Duration{10, 20, 35, 35} {
+		if got := retry.delay(attempt + 1); got != want*time.Millisecond {
+			t.Errorf("delay of attempt %d is %s, want %s", attempt+1, got, want*time.Millisecond)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// savepointKey is context key of the connection savepoint depth.
+type savepointKey struct {
+	name string
+}
+
+// WithNestedTx is like WithTx, but when the context carries the connection ambient transaction,
+// fn runs under a savepoint of it instead of joining it. The savepoint is rolled back when fn
+// returns an error, so the outer transaction may handle the error and continue. The savepoint is
+// not rolled back on panic, the panic is propagated to the outer transaction.
+func (r *Registry) WithNestedTx(ctx context.Context, name string, opts *sql.TxOptions, fn TxFunc) error {
+	var tx, ok = TxFromContext(ctx, name)
+	if !ok {
+		return r.WithTx(ctx, name, opts, fn)
+	}
+
+	return runSavepoint(ctx, name, tx, fn)
+}
+
+// runSavepoint runs fn under a new savepoint of the transaction.
+func runSavepoint(ctx context.Context, name string, tx *sql.Tx, fn TxFunc) (err error) {
+	var depth, _ = ctx.Value(savepointKey{name: name}).(int)
+	depth++
+
+	var savepoint = fmt.Sprintf("gozix_sp_%d", depth)
+	if _, err = tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		return err
+	}
+
+	var (
+		parent, _ = ctx.Value(txHooksKey{name: name}).(*txHooks)
+		hooks     txHooks
+		nested    = context.WithValue(ctx, savepointKey{name: name}, depth)
+	)
+
+	if parent != nil {
+		nested = context.WithValue(nested, txHooksKey{name: name}, &hooks)
+	}
+
+	if err = fn(nested, tx); err != nil {
+		if _, rbErr := tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint); rbErr != nil {
+			return fmt.Errorf("unable rollback to savepoint %s : %v : %w", savepoint, rbErr, err)
+		}
+
+		hooks.finish(false)
+
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint); err != nil {
+		hooks.finish(false)
+		return err
+	}
+
+	if parent != nil {
+		hooks.merge(parent)
+	}
+
+	return nil
+}
@@ -0,0 +1,75 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+type (
+	// session tracks the last write of every connection made within the context.
+	session struct {
+		mux    sync.Mutex
+		writes map[string]time.Time
+	}
+
+	// sessionKey is context key of the session.
+	sessionKey struct{}
+
+	// sessionInterceptor records writes made on the master node to the context session.
+	sessionInterceptor struct {
+		clock Clock
+	}
+)
+
+// ContextWithSession returns context tracking writes of the connections with ReadYourWrites enabled.
+// Once the context made a write on such connection, the slave getters called with the context return
+// the master node for the ReadYourWritesWindow, or for the context lifetime if the window is zero.
+func ContextWithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{writes: make(map[string]time.Time)})
+}
+
+// sessionFromContext returns the context session or nil.
+func sessionFromContext(ctx context.Context) *session {
+	var s, _ = ctx.Value(sessionKey{}).(*session)
+	return s
+}
+
+// written records the write on the connection made at the time.
+func (s *session) written(name string, at time.Time) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.writes[name] = at
+}
+
+// pinned reports whether reads of the connection must go to the master node at the time.
+func (s *session) pinned(name string, window time.Duration, now time.Time) bool {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	var at, ok = s.writes[name]
+	if !ok {
+		return false
+	}
+
+	return window <= 0 || now.Sub(at) < window
+}
+
+func (i sessionInterceptor) before(ctx context.Context, _ *QueryEvent) (context.Context, error) {
+	return ctx, nil
+}
+
+func (i sessionInterceptor) after(ctx context.Context, e *QueryEvent) {
+	if e.Err != nil || e.Role != RoleMaster || e.Op != OpExec && e.Op != OpCommit {
+		return
+	}
+
+	if s := sessionFromContext(ctx); s != nil {
+		s.written(e.Connection, i.clock.Now())
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestSessionPinned(t *testing.T) {
+	var (
+		at = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		s  = sessionFromContext(ContextWithSession(context.Background()))
+	)
+
+	s.written("main", at)
+
+	var cases = []struct {
+		name    string
+		conn    string
+		window  time.Duration
+		elapsed time.Duration
+		want    bool
+	}{
+		{name: "no write", conn: "other", window: time.Second},
+		{name: "within window", conn: "main", window: time.Second, elapsed: 999 * time.Millisecond, want: true},
+		{name: "window elapsed", conn: "main", window: time.Second, elapsed: time.Second},
+		{name: "context lifetime", conn: "main", elapsed: time.Hour, want: true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := s.pinned(tc.conn, tc.window, at.Add(tc.elapsed)); got != tc.want {
+				t.Errorf("pinned is %t, want %t", got, tc.want)
+			}
+		})
+	}
+
+	if sessionFromContext(context.Background()) != nil {
+		t.Error("session of plain context")
+	}
+}
+
+func TestRegistryReadYourWrites(t *testing.T) {
+	var db, mock, err = sqlmock.NewWithDSN("gozix_session_master")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	if db, _, err = sqlmock.NewWithDSN("gozix_session_slave"); err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	var (
+		now      = time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+		registry *Registry
+	)
+
+	if registry, err = NewRegistry(Configs{"main": {
+		Driver:               "sqlmock",
+		Nodes:                []string{"gozix_session_master", "gozix_session_slave"},
+		ReadYourWrites:       true,
+		ReadYourWritesWindow: time.Second,
+	}}, WithClock(ClockFunc(func() time.Time { return now }))); err != nil {
+		t.Fatal(err)
+	}
+
+	defer registry.Close()
+
+	var master, _ = registry.Master("main")
+	var (
+		ctx   = ContextWithSession(context.Background())
+		other = ContextWithSession(context.Background())
+	)
+
+	var pinned = func(ctx context.Context) bool {
+		t.Helper()
+
+		var db, err = registry.SlaveWithNameContext(ctx, "main")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return db == master
+	}
+
+	if pinned(ctx) {
+		t.Fatal("reads are pinned before a write")
+	}
+
+	mock.ExpectExec("UPDATE users").WillReturnError(errors.New("deadlock"))
+	mock.ExpectExec("UPDATE users").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if _, err = master.ExecContext(ctx, "UPDATE users SET name = 'alice'"); err == nil {
+		t.Fatal("failed write succeeded")
+	}
+
+	if pinned(ctx) {
+		t.Error("reads are pinned after a failed write")
+	}
+
+	if _, err = master.ExecContext(ctx, "UPDATE users SET name = 'alice'"); err != nil {
+		t.Fatal(err)
+	}
+
+	if !pinned(ctx) {
+		t.Error("reads are not pinned after a write")
+	}
+
+	if pinned(other) || pinned(context.Background()) {
+		t.Error("reads of other contexts are pinned")
+	}
+
+	now = now.Add(time.Second)
+	if pinned(ctx) {
+		t.Error("reads are pinned after the window")
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+
+	"github.com/iqoption/nap"
+)
+
+type (
+	// ShardStrategy maps the shard key to the index of one of n shards.
+	ShardStrategy interface {
+		Shard(key string, n int) (int, error)
+	}
+
+	// ShardStrategyFunc is a function implementing the ShardStrategy interface.
+	ShardStrategyFunc func(key string, n int) (int, error)
+
+	// ShardedRegistry routes shard keys to the registry connections.
+	ShardedRegistry struct {
+		registry *Registry
+		shards   []string
+		strategy ShardStrategy
+	}
+)
+
+var (
+	// ErrNoShards is error triggered when sharded registry is created without shards.
+	ErrNoShards = errors.New("no shards")
+
+	// ErrUnknownShardKey is error triggered when shard strategy can not map the key.
+	ErrUnknownShardKey = errors.New("unknown shard key")
+
+	// ShardStrategyFunc implements ShardStrategy interface.
+	_ ShardStrategy = ShardStrategyFunc(nil)
+)
+
+// NewShardedRegistry is sharded registry constructor. The shards are connection names in the shard
+// index order, the hash strategy is used when the strategy is nil.
+func NewShardedRegistry(registry *Registry, shards []string, strategy ShardStrategy) (*ShardedRegistry, error) {
+	if len(shards) == 0 {
+		return nil, ErrNoShards
+	}
+
+	if strategy == nil {
+		strategy = HashStrategy()
+	}
+
+	return &ShardedRegistry{
+		registry: registry,
+		shards:   append([]string(nil), shards...),
+		strategy: strategy,
+	}, nil
+}
+
+// HashStrategy returns strategy choosing the shard by FNV-1a hash of the key modulo shard count.
+func HashStrategy() ShardStrategy {
+	return ShardStrategyFunc(func(key string, n int) (int, error) {
+		var h = fnv.New32a()
+		_, _ = h.Write([]byte(key))
+
+		return int(h.Sum32() % uint32(n)), nil
+	})
+}
+
+// ModuloStrategy returns strategy choosing the shard by the numeric key modulo shard count.
+func ModuloStrategy() ShardStrategy {
+	return ShardStrategyFunc(func(key string, n int) (int, error) {
+		var value, err = strconv.ParseUint(key, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w %q : %s", ErrUnknownShardKey, key, err)
+		}
+
+		return int(value % uint64(n)), nil
+	})
+}
+
+// LookupStrategy returns strategy choosing the shard index from the table. Keys missing in the
+// table are passed to the fallback strategy, ErrUnknownShardKey is returned if fallback is nil.
+func LookupStrategy(table map[string]int, fallback ShardStrategy) ShardStrategy {
+	return ShardStrategyFunc(func(key string, n int) (int, error) {
+		if idx, ok := table[key]; ok {
+			return idx, nil
+		}
+
+		if fallback == nil {
+			return 0, fmt.Errorf("%w %q", ErrUnknownShardKey, key)
+		}
+
+		return fallback.Shard(key, n)
+	})
+}
+
+// Shard implements the ShardStrategy interface.
+func (f ShardStrategyFunc) Shard(key string, n int) (int, error) {
+	return f(key, n)
+}
+
+// Shards returns connection names of the shards in the shard index order.
+func (s *ShardedRegistry) Shards() []string {
+	return append([]string(nil), s.shards...)
+}
+
+// ShardName returns connection name of the shard the key belongs to.
+func (s *ShardedRegistry) ShardName(key string) (string, error) {
+	var idx, err = s.strategy.Shard(key, len(s.shards))
+	if err != nil {
+		return "", err
+	}
+
+	if idx < 0 || idx >= len(s.shards) {
+		return "", fmt.Errorf("shard index %d of key %q is out of range", idx, key)
+	}
+
+	return s.shards[idx], nil
+}
+
+// Shard returns connection of the shard the key belongs to.
+func (s *ShardedRegistry) Shard(key string) (*nap.DB, error) {
+	return s.ShardContext(context.Background(), key)
+}
+
+// ShardContext returns connection of the shard the key belongs to.
+func (s *ShardedRegistry) ShardContext(ctx context.Context, key string) (*nap.DB, error) {
+	var name, err = s.ShardName(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.registry.ConnectionWithNameContext(ctx, name)
+}
+
+// ShardSlave returns read node of the shard the key belongs to.
+func (s *ShardedRegistry) ShardSlave(key string) (*sql.DB, error) {
+	return s.ShardSlaveContext(context.Background(), key)
+}
+
+// ShardSlaveContext returns read node of the shard the key belongs to.
+func (s *ShardedRegistry) ShardSlaveContext(ctx context.Context, key string) (*sql.DB, error) {
+	var name, err = s.ShardName(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.registry.SlaveWithNameContext(ctx, name)
+}
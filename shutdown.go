@@ -0,0 +1,89 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"time"
+)
+
+const (
+	// DefaultShutdownTimeout is default time the bundle waits for in-flight queries on shutdown.
+	DefaultShutdownTimeout = 30 * time.Second
+
+	// drainInterval is interval of in-use connections polling while registry is drained.
+	drainInterval = 50 * time.Millisecond
+)
+
+// Shutdown stops handing out connections, waits until every in-use connection is returned to
+// the pools and closes them. When the context is done before the pools are drained, the pools
+// are closed anyway and the context error is returned.
+func (r *Registry) Shutdown(ctx context.Context) error {
+	r.mux.Lock()
+	r.shutdown = true
+	r.mux.Unlock()
+
+	var ticker = time.NewTicker(drainInterval)
+	defer ticker.Stop()
+
+	for r.inUse() > 0 {
+		select {
+		case <-ctx.Done():
+			if err := r.Close(); err != nil {
+				return err
+			}
+
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	return r.Close()
+}
+
+// inUse returns the number of connections currently in use across all opened pools.
+func (r *Registry) inUse() (n int) {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	for _, c := range r.conns {
+		n += c.inUse()
+	}
+
+	return n
+}
+
+// inUse returns the number of connections currently in use across the connection and its profiles pools.
+func (c *connection) inUse() (n int) {
+	for _, node := range c.db.Databases() {
+		n += node.Stats().InUse
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	for _, p := range c.profiles {
+		n += p.inUse()
+	}
+
+	return n
+}
+
+// drain waits until every in-use connection is returned to the pools or the context is done,
+// then closes the connection.
+func (c *connection) drain(ctx context.Context) error {
+	var ticker = time.NewTicker(drainInterval)
+	defer ticker.Stop()
+
+	for c.inUse() > 0 {
+		select {
+		case <-ctx.Done():
+			return c.close()
+		case <-ticker.C:
+		}
+	}
+
+	return c.close()
+}
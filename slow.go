@@ -0,0 +1,119 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"time"
+)
+
+type (
+	// SlowQuery describes the statement executed longer than the connection slow query threshold.
+	// Arguments are not reported, only their number, so they never leak to logs.
+	SlowQuery struct {
+		Connection string
+		Node       int
+		Role       string
+		Op         string
+		Query      string
+		NumArgs    int
+		Duration   time.Duration
+		Err        error
+
+		// CorrelationID is the request correlation ID of the statement context, empty if not set.
+		CorrelationID string
+	}
+
+	// SlowQueryLogger reports slow queries.
+	SlowQueryLogger interface {
+		LogSlowQuery(ctx context.Context, q SlowQuery)
+	}
+
+	// SlowQueryLoggerFunc is a function implementing the SlowQueryLogger interface.
+	SlowQueryLoggerFunc func(ctx context.Context, q SlowQuery)
+
+	// slowQueryInterceptor reports statements exceeding the threshold.
+	slowQueryInterceptor struct {
+		threshold time.Duration
+		logger    SlowQueryLogger
+	}
+
+	// registrySlowQueryLogger writes slow queries to the registry logger.
+	registrySlowQueryLogger struct {
+		logger Logger
+	}
+)
+
+// SlowQueryLoggerFunc implements SlowQueryLogger interface.
+var _ SlowQueryLogger = SlowQueryLoggerFunc(nil)
+
+// WithSlowQueryLogger option sets logger of the queries exceeding the connection SlowQueryThreshold,
+// they are written to the registry logger by default.
+func WithSlowQueryLogger(logger SlowQueryLogger) Option {
+	return optionFunc(func(r *Registry) {
+		r.slowQueryLogger = logger
+	})
+}
+
+// LogSlowQuery implements the SlowQueryLogger interface.
+func (f SlowQueryLoggerFunc) LogSlowQuery(ctx context.Context, q SlowQuery) {
+	f(ctx, q)
+}
+
+// newSlowQueryInterceptor returns the slow query interceptor or nil if the threshold is not set.
+func newSlowQueryInterceptor(threshold time.Duration, logger SlowQueryLogger) interceptor {
+	if threshold <= 0 {
+		return nil
+	}
+
+	return &slowQueryInterceptor{threshold: threshold, logger: logger}
+}
+
+func (i *slowQueryInterceptor) before(ctx context.Context, _ *QueryEvent) (context.Context, error) {
+	return ctx, nil
+}
+
+func (i *slowQueryInterceptor) after(ctx context.Context, e *QueryEvent) {
+	if e.Op != OpExec && e.Op != OpQuery || e.Duration < i.threshold {
+		return
+	}
+
+	i.logger.LogSlowQuery(ctx, SlowQuery{
+		Connection: e.Connection,
+		Node:       e.Node,
+		Role:       e.Role,
+		Op:         e.Op,
+		Query:      e.Query,
+		NumArgs:    len(e.Args),
+		Duration:   e.Duration,
+		Err:        e.Err,
+
+		CorrelationID: e.CorrelationID,
+	})
+}
+
+// LogSlowQuery implements the SlowQueryLogger interface.
+func (l registrySlowQueryLogger) LogSlowQuery(_ context.Context, q SlowQuery) {
+	var keyvals = []interface{}{
+		"connection", q.Connection,
+		"node", q.Node,
+		"role", q.Role,
+		"op", q.Op,
+		"duration", q.Duration,
+		"args", q.NumArgs,
+		"query", q.Query,
+	}
+
+	if len(q.CorrelationID) > 0 {
+		keyvals = append(keyvals, "correlation_id", q.CorrelationID)
+	}
+
+	if q.Err != nil {
+		l.logger.Error("slow query", q.Err, keyvals...)
+		return
+	}
+
+	l.logger.Info("slow query", keyvals...)
+}
@@ -5,7 +5,10 @@
 package sql
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/gozix/di"
@@ -17,18 +20,68 @@ import (
 	"github.com/spf13/viper"
 )
 
-// Bundle implements the glue.Bundle interface.
-type Bundle struct{}
+type (
+	// Bundle implements the glue.Bundle interface.
+	Bundle struct {
+		options     []Option
+		definitions []string
+		defineAll   bool
+		source      ConfigSource
+		conf        Configs
+	}
+
+	// BundleOption interface.
+	BundleOption interface {
+		applyBundle(bundle *Bundle)
+	}
+
+	// bundleOptionFunc wraps a func, so it satisfies the BundleOption interface.
+	bundleOptionFunc func(bundle *Bundle)
+)
 
-// BundleName is default definition name.
-const BundleName = "sql"
+const (
+	// BundleName is default definition name.
+	BundleName = "sql"
+
+	// TagConnection is tag prefix of the connection definitions, see ConnectionTag.
+	TagConnection = "sql.connection"
+)
 
 // Bundle implements glue.Bundle interface.
 var _ glue.Bundle = (*Bundle)(nil)
 
-// NewBundle create bundle instance.
-func NewBundle() *Bundle {
-	return new(Bundle)
+// NewBundle create bundle instance. The registry options are passed to the registry constructor.
+func NewBundle(options ...BundleOption) *Bundle {
+	var b = new(Bundle)
+	for _, option := range options {
+		option.applyBundle(b)
+	}
+
+	return b
+}
+
+// WithDefinitions registers every named connection in the container as *nap.DB tagged with
+// ConnectionTag(name), so constructors can depend on the connection directly. Without names every
+// connection of the WithConfigSource configuration is registered, the viper configuration is not
+// available when the container is built, so the names are required otherwise.
+func WithDefinitions(names ...string) BundleOption {
+	return bundleOptionFunc(func(b *Bundle) {
+		b.definitions = append(b.definitions, names...)
+		b.defineAll = b.defineAll || len(names) == 0
+	})
+}
+
+// WithConfigSource bundle option reads the connections configurations from the source instead of the
+// viper sql key. The source is read once when the container is built.
+func WithConfigSource(source ConfigSource) BundleOption {
+	return bundleOptionFunc(func(b *Bundle) {
+		b.source = source
+	})
+}
+
+// ConnectionTag returns tag of the named connection definition, e.g. sql.connection.reporting.
+func ConnectionTag(name string) string {
+	return TagConnection + "." + name
 }
 
 func (b *Bundle) Name() string {
@@ -36,8 +89,63 @@ func (b *Bundle) Name() string {
 }
 
 // Build implements the glue.Bundle interface.
-func (b *Bundle) Build(builder di.Builder) error {
-	return builder.Provide(b.provideRegistry)
+func (b *Bundle) Build(builder di.Builder) (err error) {
+	if err = builder.Provide(b.provideRegistry); err != nil {
+		return err
+	}
+
+	for _, cmd := range []interface{}{provideConnectionsCmd, providePingCmd, provideQueryCmd, provideStatusCmd} {
+		if err = builder.Provide(cmd, glue.AsCliCommand()); err != nil {
+			return err
+		}
+	}
+
+	if b.source != nil {
+		if b.conf, err = b.source.Configs(); err != nil {
+			return err
+		}
+	}
+
+	var names []string
+	if names, err = b.definitionNames(); err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if err = builder.Provide(provideConnection(name), di.Tags{{Name: ConnectionTag(name)}}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// definitionNames returns unique names of the connection definitions, every configured connection is
+// included when the definitions are requested without names.
+func (b *Bundle) definitionNames() ([]string, error) {
+	var names = append([]string(nil), b.definitions...)
+	if b.defineAll {
+		if b.source == nil {
+			return nil, errors.New("connection definitions without names require configuration source")
+		}
+
+		for name := range b.conf {
+			if name != DefaultsName {
+				names = append(names, name)
+			}
+		}
+	}
+
+	sort.Strings(names)
+
+	var unique = names[:0]
+	for i, name := range names {
+		if i == 0 || name != names[i-1] {
+			unique = append(unique, name)
+		}
+	}
+
+	return unique, nil
 }
 
 func (b *Bundle) DependsOn() []string {
@@ -47,64 +155,307 @@ func (b *Bundle) DependsOn() []string {
 }
 
 func (b *Bundle) provideRegistry(cfg *viper.Viper, registry *prometheus.Registry) (_ *Registry, _ func() error, err error) {
-	// use this is hack, not UnmarshalKey
-	// see https://github.com/spf13/viper/issues/188
-	var (
-		keys = cfg.Sub(BundleName).AllKeys()
-		conf = make(Configs, len(keys))
-	)
-
-	for _, key := range keys {
-		var name = strings.Split(key, ".")[0]
-		if _, ok := conf[name]; ok {
-			continue
+	var conf = b.conf
+	if b.source == nil {
+		if conf, err = ViperSource(cfg, BundleName).Configs(); err != nil {
+			return nil, nil, err
 		}
+	}
+
+	var sqlRegistry *Registry
+	if sqlRegistry, err = NewRegistry(conf, b.registryOptions(registry)...); err != nil {
+		return nil, nil, err
+	}
+
+	var closer = func() error {
+		var ctx, cancel = context.WithTimeout(context.Background(), DefaultShutdownTimeout)
+		defer cancel()
+
+		return sqlRegistry.Shutdown(ctx)
+	}
 
+	return sqlRegistry, closer, nil
+}
+
+// provideConnection returns the named connection constructor.
+func provideConnection(name string) func(registry *Registry) (*nap.DB, error) {
+	return func(registry *Registry) (*nap.DB, error) {
+		return registry.ConnectionWithName(name)
+	}
+}
+
+// ViperSource returns source reading configurations from the viper key.
+func ViperSource(cfg *viper.Viper, key string) ConfigSource {
+	return ConfigSourceFunc(func() (_ Configs, err error) {
+		var sub = cfg.Sub(key)
+		if sub == nil {
+			return Configs{}, nil
+		}
+
+		// use this is hack, not UnmarshalKey
+		// see https://github.com/spf13/viper/issues/188
 		var (
-			c      Config
-			suffix = fmt.Sprintf("%s.%s.", BundleName, name)
+			names = sub.AllKeys()
+			conf  = make(Configs, len(names))
 		)
 
-		if cfg.IsSet(suffix + "nodes") {
-			c.Nodes = cfg.GetStringSlice(suffix + "nodes")
-		}
+		for _, name := range names {
+			name = strings.Split(name, ".")[0]
+			if _, ok := conf[name]; ok {
+				continue
+			}
 
-		if cfg.IsSet(suffix + "driver") {
-			c.Driver = cfg.GetString(suffix + "driver")
-		}
+			var (
+				c      Config
+				prefix = fmt.Sprintf("%s.%s.", key, name)
+			)
 
-		if cfg.IsSet(suffix + "max_open_conns") {
-			c.MaxOpenConns = cfg.GetInt(suffix + "max_open_conns")
-		}
+			if cfg.IsSet(prefix + "nodes") {
+				c.Nodes = cfg.GetStringSlice(prefix + "nodes")
+			}
 
-		if cfg.IsSet(suffix + "max_idle_conns") {
-			c.MaxIdleConns = cfg.GetInt(suffix + "max_idle_conns")
-		}
+			if cfg.IsSet(prefix + "node_configs") {
+				if c.NodeConfigs, err = nodesFromValue(cfg.Get(prefix + "node_configs")); err != nil {
+					return nil, fmt.Errorf("invalid %s node configs : %w", name, err)
+				}
+			}
 
-		if cfg.IsSet(suffix + "conn_max_lifetime") {
-			c.ConnMaxLifetime = cfg.GetDuration(suffix + "conn_max_lifetime")
-		}
+			if cfg.IsSet(prefix + "driver") {
+				c.Driver = cfg.GetString(prefix + "driver")
+			}
+
+			if cfg.IsSet(prefix + "backend") {
+				c.Backend = cfg.GetString(prefix + "backend")
+			}
+
+			if cfg.IsSet(prefix + "max_open_conns") {
+				c.MaxOpenConns = cfg.GetInt(prefix + "max_open_conns")
+			}
+
+			if cfg.IsSet(prefix + "max_idle_conns") {
+				c.MaxIdleConns = cfg.GetInt(prefix + "max_idle_conns")
+			}
+
+			if cfg.IsSet(prefix + "conn_max_lifetime") {
+				c.ConnMaxLifetime = cfg.GetDuration(prefix + "conn_max_lifetime")
+			}
+
+			if cfg.IsSet(prefix + "conn_max_idle_time") {
+				c.ConnMaxIdleTime = cfg.GetDuration(prefix + "conn_max_idle_time")
+			}
+
+			unmarshalRetry(cfg, prefix+"open_retry.", &c.OpenRetry)
+			unmarshalRetry(cfg, prefix+"tx_retry.", &c.TxRetry)
 
-		c.AfterOpen = func(name string, db *nap.DB) {
-			for i, dbItem := range db.Databases() {
-				n := fmt.Sprintf("%s_%d", name, i)
-				registry.MustRegister(
-					newPrometheusCollector(n, dbItem),
-				)
+			if cfg.IsSet(prefix + "tx_isolation") {
+				c.TxIsolation = cfg.GetString(prefix + "tx_isolation")
 			}
+
+			if cfg.IsSet(prefix + "tx_read_only") {
+				c.TxReadOnly = cfg.GetBool(prefix + "tx_read_only")
+			}
+
+			if cfg.IsSet(prefix + "read_policy") {
+				c.ReadPolicy = ReadPolicy(cfg.GetString(prefix + "read_policy"))
+			}
+
+			if cfg.IsSet(prefix + "read_weights") {
+				c.ReadWeights = cfg.GetIntSlice(prefix + "read_weights")
+			}
+
+			if cfg.IsSet(prefix + "master_read_percent") {
+				c.MasterReadPercent = cfg.GetInt(prefix + "master_read_percent")
+			}
+
+			if cfg.IsSet(prefix + "stmt_cache_size") {
+				c.StmtCacheSize = cfg.GetInt(prefix + "stmt_cache_size")
+			}
+
+			if cfg.IsSet(prefix + "recent_queries") {
+				c.RecentQueries = cfg.GetInt(prefix + "recent_queries")
+			}
+
+			if cfg.IsSet(prefix + "read_only_slaves") {
+				c.ReadOnlySlaves = cfg.GetBool(prefix + "read_only_slaves")
+			}
+
+			if cfg.IsSet(prefix + "read_your_writes") {
+				c.ReadYourWrites = cfg.GetBool(prefix + "read_your_writes")
+			}
+
+			if cfg.IsSet(prefix + "read_your_writes_window") {
+				c.ReadYourWritesWindow = cfg.GetDuration(prefix + "read_your_writes_window")
+			}
+
+			if cfg.IsSet(prefix + "tolerate_partial_failure") {
+				c.ToleratePartialFailure = cfg.GetBool(prefix + "tolerate_partial_failure")
+			}
+
+			if cfg.IsSet(prefix + "strict_open") {
+				c.StrictOpen = cfg.GetBool(prefix + "strict_open")
+			}
+
+			if cfg.IsSet(prefix + "keepalive_interval") {
+				c.KeepaliveInterval = cfg.GetDuration(prefix + "keepalive_interval")
+			}
+
+			if cfg.IsSet(prefix + "dedicated_conn_acquire_timeout") {
+				c.DedicatedConnAcquireTimeout = cfg.GetDuration(prefix + "dedicated_conn_acquire_timeout")
+			}
+
+			if cfg.IsSet(prefix + "leak_threshold") {
+				c.LeakThreshold = cfg.GetDuration(prefix + "leak_threshold")
+			}
+
+			if cfg.IsSet(prefix + "pooler_mode") {
+				c.PoolerMode = cfg.GetBool(prefix + "pooler_mode")
+			}
+
+			if cfg.IsSet(prefix + "role_probe") {
+				c.RoleProbe = RoleProbe{
+					Interval: cfg.GetDuration(prefix + "role_probe.interval"),
+					Timeout:  cfg.GetDuration(prefix + "role_probe.timeout"),
+					Query:    cfg.GetString(prefix + "role_probe.query"),
+				}
+			}
+
+			if cfg.IsSet(prefix + "discovery") {
+				c.Discovery = Discovery{
+					SRV:      cfg.GetString(prefix + "discovery.srv"),
+					DSN:      cfg.GetString(prefix + "discovery.dsn"),
+					Interval: cfg.GetDuration(prefix + "discovery.interval"),
+				}
+			}
+
+			if cfg.IsSet(prefix + "init_statements") {
+				c.InitStatements = cfg.GetStringSlice(prefix + "init_statements")
+			}
+
+			if cfg.IsSet(prefix + "cache_ttl") {
+				c.CacheTTL = cfg.GetDuration(prefix + "cache_ttl")
+			}
+
+			if cfg.IsSet(prefix + "query_timeout") {
+				c.QueryTimeout = cfg.GetDuration(prefix + "query_timeout")
+			}
+
+			if cfg.IsSet(prefix + "slow_query_threshold") {
+				c.SlowQueryThreshold = cfg.GetDuration(prefix + "slow_query_threshold")
+			}
+
+			if cfg.IsSet(prefix + "tls") {
+				c.TLS = &TLS{
+					CAFile:             cfg.GetString(prefix + "tls.ca_file"),
+					CertFile:           cfg.GetString(prefix + "tls.cert_file"),
+					KeyFile:            cfg.GetString(prefix + "tls.key_file"),
+					ServerName:         cfg.GetString(prefix + "tls.server_name"),
+					InsecureSkipVerify: cfg.GetBool(prefix + "tls.insecure_skip_verify"),
+				}
+			}
+
+			if cfg.IsSet(prefix + "circuit_breaker.threshold") {
+				c.CircuitBreaker.Threshold = cfg.GetInt(prefix + "circuit_breaker.threshold")
+			}
+
+			if cfg.IsSet(prefix + "circuit_breaker.timeout") {
+				c.CircuitBreaker.Timeout = cfg.GetDuration(prefix + "circuit_breaker.timeout")
+			}
+
+			if cfg.IsSet(prefix + "health_monitor") {
+				c.HealthMonitor = HealthMonitor{
+					Interval:         cfg.GetDuration(prefix + "health_monitor.interval"),
+					Timeout:          cfg.GetDuration(prefix + "health_monitor.timeout"),
+					FailureThreshold: cfg.GetInt(prefix + "health_monitor.failure_threshold"),
+					SuccessThreshold: cfg.GetInt(prefix + "health_monitor.success_threshold"),
+				}
+			}
+
+			if cfg.IsSet(prefix + "adaptive_pool") {
+				c.AdaptivePool = AdaptivePool{
+					MinOpenConns: cfg.GetInt(prefix + "adaptive_pool.min_open_conns"),
+					MaxOpenConns: cfg.GetInt(prefix + "adaptive_pool.max_open_conns"),
+					Interval:     cfg.GetDuration(prefix + "adaptive_pool.interval"),
+					TargetWait:   cfg.GetDuration(prefix + "adaptive_pool.target_wait"),
+				}
+			}
+
+			if cfg.IsSet(prefix + "profiles") {
+				c.Profiles = make(Profiles)
+				for profile := range cfg.GetStringMap(prefix + "profiles") {
+					var key = prefix + "profiles." + profile + "."
+					c.Profiles[profile] = Profile{
+						MaxOpenConns:    cfg.GetInt(key + "max_open_conns"),
+						MaxIdleConns:    cfg.GetInt(key + "max_idle_conns"),
+						ConnMaxLifetime: cfg.GetDuration(key + "conn_max_lifetime"),
+						ConnMaxIdleTime: cfg.GetDuration(key + "conn_max_idle_time"),
+					}
+				}
+			}
+
+			if cfg.IsSet(prefix + "concurrency") {
+				c.Concurrency = Concurrency{
+					Limit:      cfg.GetInt(prefix + "concurrency.limit"),
+					BatchLimit: cfg.GetInt(prefix + "concurrency.batch_limit"),
+				}
+			}
+
+			if cfg.IsSet(prefix + "max_replica_lag") {
+				c.MaxReplicaLag = cfg.GetDuration(prefix + "max_replica_lag")
+			}
+
+			if cfg.IsSet(prefix + "replica_lag") {
+				c.ReplicaLag = ReplicaLag{
+					Interval:       cfg.GetDuration(prefix + "replica_lag.interval"),
+					Query:          cfg.GetString(prefix + "replica_lag.query"),
+					HeartbeatTable: cfg.GetString(prefix + "replica_lag.heartbeat_table"),
+				}
+			}
+
+			c.explicit = explicitFields(func(field string) bool {
+				return cfg.IsSet(prefix + field)
+			})
+
+			conf[name] = c
 		}
 
-		conf[name] = c
+		return conf, nil
+	})
+}
+
+// registryOptions returns registry options, the bundle defaults go first so they can be overridden.
+func (b *Bundle) registryOptions(registry *prometheus.Registry) []Option {
+	var options = []Option{
+		WithMetrics(registry, DefaultMetricsInterval),
 	}
 
-	var sqlRegistry *Registry
-	if sqlRegistry, err = NewRegistry(conf); err != nil {
-		return nil, nil, err
+	return append(options, b.options...)
+}
+
+// unmarshalRetry reads retry configuration keys with the prefix.
+func unmarshalRetry(cfg *viper.Viper, prefix string, retry *Retry) {
+	if cfg.IsSet(prefix + "attempts") {
+		retry.Attempts = cfg.GetInt(prefix + "attempts")
 	}
 
-	var closer = func() error {
-		return sqlRegistry.Close()
+	if cfg.IsSet(prefix + "initial_delay") {
+		retry.InitialDelay = cfg.GetDuration(prefix + "initial_delay")
 	}
 
-	return sqlRegistry, closer, nil
+	if cfg.IsSet(prefix + "max_delay") {
+		retry.MaxDelay = cfg.GetDuration(prefix + "max_delay")
+	}
+
+	if cfg.IsSet(prefix + "jitter") {
+		retry.Jitter = cfg.GetFloat64(prefix + "jitter")
+	}
+}
+
+// applyBundle implements BundleOption.
+func (f bundleOptionFunc) applyBundle(bundle *Bundle) {
+	f(bundle)
+}
+
+// applyBundle implements BundleOption.
+func (f optionFunc) applyBundle(bundle *Bundle) {
+	bundle.options = append(bundle.options, f)
 }
@@ -0,0 +1,136 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+// Package sqlerr provide driver agnostic classification of the database errors.
+package sqlerr
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+var (
+	// ErrUniqueViolation is error of the unique or primary key constraint violation.
+	ErrUniqueViolation = errors.New("unique violation")
+
+	// ErrForeignKeyViolation is error of the foreign key constraint violation.
+	ErrForeignKeyViolation = errors.New("foreign key violation")
+
+	// ErrNotNullViolation is error of the not null constraint violation.
+	ErrNotNullViolation = errors.New("not null violation")
+
+	// ErrCheckViolation is error of the check constraint violation.
+	ErrCheckViolation = errors.New("check violation")
+
+	// ErrSerializationFailure is error of the transaction that could not be serialized.
+	ErrSerializationFailure = errors.New("serialization failure")
+
+	// ErrDeadlock is error of the transaction aborted to resolve a deadlock.
+	ErrDeadlock = errors.New("deadlock")
+
+	// ErrLockTimeout is error of the lock that could not be acquired in time.
+	ErrLockTimeout = errors.New("lock timeout")
+)
+
+var (
+	// postgresCodes maps SQLSTATE codes to the errors, SQLSTATE is reported by both pgx and lib/pq.
+	postgresCodes = map[string]error{
+		"23505": ErrUniqueViolation,
+		"23503": ErrForeignKeyViolation,
+		"23502": ErrNotNullViolation,
+		"23514": ErrCheckViolation,
+		"40001": ErrSerializationFailure,
+		"40P01": ErrDeadlock,
+		"55P03": ErrLockTimeout,
+	}
+
+	// mysqlCodes maps MySQL error numbers to the errors.
+	mysqlCodes = map[uint16]error{
+		1062: ErrUniqueViolation,     // ER_DUP_ENTRY
+		1586: ErrUniqueViolation,     // ER_DUP_ENTRY_WITH_KEY_NAME
+		1451: ErrForeignKeyViolation, // ER_ROW_IS_REFERENCED_2
+		1452: ErrForeignKeyViolation, // ER_NO_REFERENCED_ROW_2
+		1048: ErrNotNullViolation,    // ER_BAD_NULL_ERROR
+		3819: ErrCheckViolation,      // ER_CHECK_CONSTRAINT_VIOLATED
+		1213: ErrDeadlock,            // ER_LOCK_DEADLOCK
+		1205: ErrLockTimeout,         // ER_LOCK_WAIT_TIMEOUT
+	}
+
+	// sqliteCodes maps SQLite extended result codes to the errors.
+	sqliteCodes = map[int]error{
+		2067: ErrUniqueViolation,     // SQLITE_CONSTRAINT_UNIQUE
+		1555: ErrUniqueViolation,     // SQLITE_CONSTRAINT_PRIMARYKEY
+		787:  ErrForeignKeyViolation, // SQLITE_CONSTRAINT_FOREIGNKEY
+		1299: ErrNotNullViolation,    // SQLITE_CONSTRAINT_NOTNULL
+		275:  ErrCheckViolation,      // SQLITE_CONSTRAINT_CHECK
+		5:    ErrLockTimeout,         // SQLITE_BUSY
+		6:    ErrLockTimeout,         // SQLITE_LOCKED
+	}
+
+	// sqliteMessages maps SQLite error messages to the errors for drivers not exposing result codes.
+	sqliteMessages = []struct {
+		prefix string
+		err    error
+	}{
+		{prefix: "UNIQUE constraint failed", err: ErrUniqueViolation},
+		{prefix: "FOREIGN KEY constraint failed", err: ErrForeignKeyViolation},
+		{prefix: "NOT NULL constraint failed", err: ErrNotNullViolation},
+		{prefix: "CHECK constraint failed", err: ErrCheckViolation},
+		{prefix: "database is locked", err: ErrLockTimeout},
+	}
+)
+
+// Classify returns the portable error matching the driver error or nil if the error is not recognized.
+// Postgres errors are recognized by SQLSTATE, MySQL ones by the error number and SQLite ones by the
+// extended result code or the message.
+func Classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var state interface{ SQLState() string }
+	if errors.As(err, &state) {
+		return postgresCodes[state.SQLState()]
+	}
+
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		return mysqlCodes[myErr.Number]
+	}
+
+	var code interface{ Code() int }
+	if errors.As(err, &code) {
+		if kind, ok := sqliteCodes[code.Code()]; ok {
+			return kind
+		}
+	}
+
+	var msg = err.Error()
+	for _, item := range sqliteMessages {
+		if strings.HasPrefix(msg, item.prefix) {
+			return item.err
+		}
+	}
+
+	return nil
+}
+
+// Is reports whether the driver error is classified as the target portable error.
+func Is(err, target error) bool {
+	var kind = Classify(err)
+	return kind != nil && kind == target
+}
+
+// Retryable reports whether the transaction failed with the error may succeed when it is repeated,
+// that is it was aborted by serialization failure, deadlock or lock timeout.
+func Retryable(err error) bool {
+	switch Classify(err) {
+	case ErrSerializationFailure, ErrDeadlock, ErrLockTimeout:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,72 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultSQLiteBusyTimeout is time SQLite connections wait for a lock held by another connection.
+const DefaultSQLiteBusyTimeout = 5 * time.Second
+
+// isSQLite reports whether the driver is SQLite one.
+func isSQLite(driverName string) bool {
+	switch driverName {
+	case "sqlite", "sqlite3":
+		return true
+	default:
+		return false
+	}
+}
+
+// isSQLiteMemory reports whether the SQLite DSN is in-memory database living as long as its connection.
+func isSQLiteMemory(dsn string) bool {
+	return dsn == "" || strings.HasPrefix(dsn, ":memory:") || strings.HasPrefix(dsn, "file::memory:") ||
+		strings.Contains(dsn, "mode=memory")
+}
+
+// constrained returns the configuration whose SQLite pools are limited to what the database supports.
+// The master node gets the single writer connection, in-memory databases keep their connections forever
+// as the database is gone with the last one.
+func (c Config) constrained() Config {
+	if !isSQLite(c.Driver) || len(c.NodeConfigs) == 0 {
+		return c
+	}
+
+	var nodes = make(Nodes, len(c.NodeConfigs))
+	copy(nodes, c.NodeConfigs)
+
+	nodes[0].MaxOpenConns = 1
+	for i := range nodes {
+		if isSQLiteMemory(nodes[i].DSN) {
+			if open := inheritInt(nodes[i].MaxOpenConns, c.MaxOpenConns); open > 0 {
+				nodes[i].MaxIdleConns = open
+			}
+
+			nodes[i].ConnMaxLifetime = -1
+			nodes[i].ConnMaxIdleTime = -1
+		}
+	}
+
+	c.NodeConfigs = nodes
+
+	return c
+}
+
+// sqlitePragmas returns statements executed on every new physical SQLite connection of the node, the
+// file database of the master is switched to write-ahead log so the readers don't block the writer.
+func sqlitePragmas(conf Config, node int) []string {
+	var statements = []string{
+		"PRAGMA busy_timeout = " + strconv.FormatInt(DefaultSQLiteBusyTimeout.Milliseconds(), 10),
+	}
+
+	if node == 0 && node < len(conf.NodeConfigs) && !isSQLiteMemory(conf.NodeConfigs[node].DSN) {
+		statements = append(statements, "PRAGMA journal_mode = WAL", "PRAGMA synchronous = NORMAL")
+	}
+
+	return statements
+}
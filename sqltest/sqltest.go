@@ -0,0 +1,87 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+// Package sqltest provide sqlmock backed sql registry for unit tests.
+package sqltest
+
+import (
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	gzSQL "github.com/gozix/sql/v3"
+)
+
+// MockRegistry is registry whose connections are backed by sqlmock.
+type MockRegistry struct {
+	*gzSQL.Registry
+
+	mocks map[string]sqlmock.Sqlmock
+	dbs   []*sql.DB
+}
+
+// sequence makes mock DSNs unique across registries.
+var sequence uint64
+
+// NewMockRegistry returns registry with the named connections, the default connection is created
+// when no names are provided. Every connection has a single node backed by its own sqlmock.
+func NewMockRegistry(names ...string) (_ *MockRegistry, err error) {
+	if len(names) == 0 {
+		names = []string{gzSQL.DEFAULT}
+	}
+
+	var (
+		r = MockRegistry{
+			mocks: make(map[string]sqlmock.Sqlmock, len(names)),
+		}
+		conf = make(gzSQL.Configs, len(names))
+	)
+
+	for _, name := range names {
+		var (
+			dsn  = fmt.Sprintf("gozix_sqltest_%d_%s", atomic.AddUint64(&sequence, 1), name)
+			db   *sql.DB
+			mock sqlmock.Sqlmock
+		)
+
+		if db, mock, err = sqlmock.NewWithDSN(dsn); err != nil {
+			_ = r.Close()
+			return nil, err
+		}
+
+		r.dbs = append(r.dbs, db)
+		r.mocks[name] = mock
+		conf[name] = gzSQL.Config{
+			Nodes:  []string{dsn},
+			Driver: "sqlmock",
+		}
+	}
+
+	if r.Registry, err = gzSQL.NewRegistry(conf); err != nil {
+		_ = r.Close()
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// Mock returns sqlmock of the connection, nil is returned for unknown connection.
+func (r *MockRegistry) Mock(name string) sqlmock.Sqlmock {
+	return r.mocks[name]
+}
+
+// Close closes the registry and the mocks. Close calls are not sqlmock expectations here, so
+// sqlmock errors of unexpected close are ignored.
+func (r *MockRegistry) Close() error {
+	if r.Registry != nil {
+		_ = r.Registry.Close()
+	}
+
+	for _, db := range r.dbs {
+		_ = db.Close()
+	}
+
+	return nil
+}
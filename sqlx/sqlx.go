@@ -0,0 +1,63 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+// Package sqlx provide sqlx access to the sql registry connections.
+package sqlx
+
+import (
+	"context"
+	"database/sql"
+
+	gzSQL "github.com/gozix/sql/v3"
+	"github.com/jmoiron/sqlx"
+)
+
+// Connection is sqlx view of the named registry connection. The returned databases wrap the
+// registry pools, so they follow the registry lifecycle and must not be closed.
+type Connection struct {
+	registry *gzSQL.Registry
+	name     string
+}
+
+// New returns sqlx view of the named registry connection.
+func New(registry *gzSQL.Registry, name string) *Connection {
+	return &Connection{
+		registry: registry,
+		name:     name,
+	}
+}
+
+// Master returns the connection master node.
+func (c *Connection) Master() (*sqlx.DB, error) {
+	return c.MasterContext(context.Background())
+}
+
+// MasterContext returns the connection master node with context.
+func (c *Connection) MasterContext(ctx context.Context) (*sqlx.DB, error) {
+	return c.wrap(c.registry.MasterContext(ctx, c.name))
+}
+
+// Slave returns the connection slave node chosen by the read policy.
+func (c *Connection) Slave() (*sqlx.DB, error) {
+	return c.SlaveContext(context.Background())
+}
+
+// SlaveContext returns the connection slave node chosen by the read policy with context.
+func (c *Connection) SlaveContext(ctx context.Context) (*sqlx.DB, error) {
+	return c.wrap(c.registry.SlaveWithNameContext(ctx, c.name))
+}
+
+// wrap wraps the node with sqlx, the connection driver selects bind placeholders.
+func (c *Connection) wrap(db *sql.DB, err error) (*sqlx.DB, error) {
+	if err != nil {
+		return nil, err
+	}
+
+	var driver string
+	if driver, err = c.registry.DriverWithName(c.name); err != nil {
+		return nil, err
+	}
+
+	return sqlx.NewDb(db, driver), nil
+}
@@ -0,0 +1,65 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import "database/sql"
+
+type (
+	// ConnectionStats is connection pools statistics.
+	ConnectionStats struct {
+		// Open reports whether the connection is opened, nodes are empty for not opened one.
+		Open  bool
+		Nodes []NodeStats
+	}
+
+	// NodeStats is connection node pool statistics.
+	NodeStats struct {
+		sql.DBStats
+
+		// Role is RoleMaster or RoleSlave.
+		Role string
+
+		// Available reports whether the node is in the read rotation.
+		Available bool
+	}
+)
+
+// Stats returns statistics of every configured connection.
+func (r *Registry) Stats() map[string]ConnectionStats {
+	r.mux.Lock()
+	defer r.mux.Unlock()
+
+	var stats = make(map[string]ConnectionStats, len(r.conf))
+	for name := range r.conf {
+		stats[name] = ConnectionStats{}
+	}
+
+	for name, c := range r.conns {
+		stats[name] = c.stats()
+	}
+
+	return stats
+}
+
+// stats returns the connection pools statistics.
+func (c *connection) stats() ConnectionStats {
+	var (
+		nodes = c.db.Databases()
+		stats = ConnectionStats{
+			Open:  true,
+			Nodes: make([]NodeStats, 0, len(nodes)),
+		}
+	)
+
+	for i, node := range nodes {
+		stats.Nodes = append(stats.Nodes, NodeStats{
+			DBStats:   node.Stats(),
+			Role:      nodeRole(i),
+			Available: i == 0 || c.available(i),
+		})
+	}
+
+	return stats
+}
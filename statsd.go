@@ -0,0 +1,223 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultStatsDFlushInterval is interval of the buffered StatsD metrics flush used when the interval is not set.
+const DefaultStatsDFlushInterval = time.Second
+
+// statsdPacketSize is the maximum size of the StatsD packet fitting the Ethernet MTU.
+const statsdPacketSize = 1432
+
+type (
+	// StatsD is configuration of the StatsD metrics exporter.
+	StatsD struct {
+		// Address is UDP host:port of the StatsD server or the Datadog agent.
+		Address string
+
+		// Prefix is prepended to every metric name, e.g. "app.sql.".
+		Prefix string
+
+		// Tags are added to every metric in DogStatsD format, plain StatsD servers don't support them.
+		Tags map[string]string
+
+		// Interval is pool statistics sampling interval, DefaultMetricsInterval by default.
+		Interval time.Duration
+
+		// FlushInterval is interval of the buffered metrics flush, DefaultStatsDFlushInterval by default.
+		FlushInterval time.Duration
+	}
+
+	// statsdExporter sends the pool gauges and the query timings to the StatsD server.
+	statsdExporter struct {
+		conf StatsD
+		tags string
+
+		mux  sync.Mutex
+		conn net.Conn
+		buf  []byte
+	}
+)
+
+// WithStatsD option exports pool gauges of every opened node and timings of the executed statements
+// to the StatsD server, alongside or instead of Prometheus. Metrics are sent over UDP in batches, the
+// send errors are dropped.
+func WithStatsD(conf StatsD) Option {
+	return optionFunc(func(r *Registry) {
+		r.statsd = &statsdExporter{conf: conf, tags: statsdTags(conf.Tags)}
+		r.chain = append(r.chain, r.statsd)
+	})
+}
+
+// open dials the StatsD server.
+func (s *statsdExporter) open() (err error) {
+	s.conn, err = net.Dial("udp", s.conf.Address)
+	return err
+}
+
+// run samples the registry pools and flushes the buffered metrics until done is closed.
+func (s *statsdExporter) run(r *Registry, done <-chan struct{}) {
+	var interval = s.conf.Interval
+	if interval <= 0 {
+		interval = DefaultMetricsInterval
+	}
+
+	var flushInterval = s.conf.FlushInterval
+	if flushInterval <= 0 {
+		flushInterval = DefaultStatsDFlushInterval
+	}
+
+	var (
+		sample = time.NewTicker(interval)
+		flush  = time.NewTicker(flushInterval)
+	)
+
+	defer func() {
+		sample.Stop()
+		flush.Stop()
+
+		s.mux.Lock()
+		s.flush()
+		_ = s.conn.Close()
+		s.mux.Unlock()
+	}()
+
+	s.sample(r)
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-sample.C:
+			s.sample(r)
+		case <-flush.C:
+			s.mux.Lock()
+			s.flush()
+			s.mux.Unlock()
+		}
+	}
+}
+
+// sample sends gauges of the current state of the registry pools.
+func (s *statsdExporter) sample(r *Registry) {
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	for name, stats := range r.Stats() {
+		for i, node := range stats.Nodes {
+			var tags = []string{"connection:" + statsdTag(name), "node:" + strconv.Itoa(i), "role:" + node.Role}
+
+			s.send("pool.max_open_connections", float64(node.MaxOpenConnections), "g", tags)
+			s.send("pool.open_connections", float64(node.OpenConnections), "g", tags)
+			s.send("pool.in_use_connections", float64(node.InUse), "g", tags)
+			s.send("pool.idle_connections", float64(node.Idle), "g", tags)
+			s.send("pool.wait_count", float64(node.WaitCount), "g", tags)
+			s.send("pool.wait_duration", durationMillis(node.WaitDuration), "g", tags)
+		}
+	}
+
+	s.flush()
+}
+
+func (s *statsdExporter) before(ctx context.Context, _ *QueryEvent) (context.Context, error) {
+	return ctx, nil
+}
+
+func (s *statsdExporter) after(_ context.Context, e *QueryEvent) {
+	if e.Op != OpExec && e.Op != OpQuery {
+		return
+	}
+
+	var tags = []string{"connection:" + statsdTag(e.Connection), "role:" + e.Role, "op:" + e.Op}
+
+	s.mux.Lock()
+	defer s.mux.Unlock()
+
+	s.send("query.duration", durationMillis(e.Duration), "ms", tags)
+
+	if e.Err != nil {
+		s.send("query.errors", 1, "c", tags)
+	}
+}
+
+// send buffers the metric, the buffer is flushed when the metric doesn't fit the packet.
+func (s *statsdExporter) send(name string, value float64, kind string, tags []string) {
+	var line = make([]byte, 0, 128)
+	line = append(line, s.conf.Prefix...)
+	line = append(line, name...)
+	line = append(line, ':')
+	line = strconv.AppendFloat(line, value, 'f', -1, 64)
+	line = append(line, '|')
+	line = append(line, kind...)
+
+	if len(tags) > 0 || len(s.tags) > 0 {
+		line = append(line, "|#"...)
+		line = append(line, strings.Join(tags, ",")...)
+
+		if len(tags) > 0 && len(s.tags) > 0 {
+			line = append(line, ',')
+		}
+
+		line = append(line, s.tags...)
+	}
+
+	if len(s.buf) > 0 && len(s.buf)+len(line)+1 > statsdPacketSize {
+		s.flush()
+	}
+
+	if len(s.buf) > 0 {
+		s.buf = append(s.buf, '\n')
+	}
+
+	s.buf = append(s.buf, line...)
+}
+
+// flush sends the buffered metrics.
+func (s *statsdExporter) flush() {
+	if len(s.buf) == 0 || s.conn == nil {
+		return
+	}
+
+	_, _ = s.conn.Write(s.buf)
+	s.buf = s.buf[:0]
+}
+
+// durationMillis returns the duration in fractional milliseconds.
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// statsdTags returns the tags in DogStatsD format sorted by key.
+func statsdTags(tags map[string]string) string {
+	var pairs = make([]string, 0, len(tags))
+	for key, value := range tags {
+		pairs = append(pairs, statsdTag(key)+":"+statsdTag(value))
+	}
+
+	sort.Strings(pairs)
+
+	return strings.Join(pairs, ",")
+}
+
+// statsdTag replaces the characters reserved by the DogStatsD format.
+func statsdTag(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', ',', '#', '\n':
+			return '_'
+		}
+
+		return r
+	}, value)
+}
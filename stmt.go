@@ -0,0 +1,175 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"container/list"
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+)
+
+type (
+	// stmtCache is LRU cache of prepared statements keyed by node and query text.
+	stmtCache struct {
+		mux   sync.Mutex
+		size  int
+		items map[stmtKey]*list.Element
+		order *list.List
+	}
+
+	// stmtKey is prepared statement cache key.
+	stmtKey struct {
+		node  *sql.DB
+		query string
+	}
+
+	// stmtEntry is prepared statement cache entry, the statement is closed once it is evicted and its
+	// last reference is released.
+	stmtEntry struct {
+		key     stmtKey
+		stmt    *sql.Stmt
+		refs    int
+		evicted bool
+	}
+)
+
+// ErrStmtCacheDisabled is error triggered when cached statement is requested for connection without statement cache.
+var ErrStmtCacheDisabled = errors.New("statement cache is disabled")
+
+// newStmtCache returns cache holding up to size statements, nil is returned for non-positive size.
+func newStmtCache(size int) *stmtCache {
+	if size <= 0 {
+		return nil
+	}
+
+	return &stmtCache{
+		size:  size,
+		items: make(map[stmtKey]*list.Element, size),
+		order: list.New(),
+	}
+}
+
+// PrepareMaster returns cached prepared statement of the connection master node and its release func.
+// The statement is owned by the cache, it must not be closed and must not be used once released. The
+// evicted statement is closed when its last user releases it.
+func (r *Registry) PrepareMaster(ctx context.Context, name, query string) (_ *sql.Stmt, release func(), err error) {
+	var c *connection
+	if c, err = r.connection(ctx, name); err != nil {
+		return nil, nil, err
+	}
+
+	if c.stmts == nil {
+		return nil, nil, ErrStmtCacheDisabled
+	}
+
+	return c.prepare(ctx, c.db.Master(), query)
+}
+
+// PrepareSlave returns cached prepared statement of the connection slave node chosen by the read
+// policy and its release func, the statement is owned by the cache like PrepareMaster does.
+func (r *Registry) PrepareSlave(ctx context.Context, name, query string) (_ *sql.Stmt, release func(), err error) {
+	var c *connection
+	if c, err = r.connection(ctx, name); err != nil {
+		return nil, nil, err
+	}
+
+	if c.stmts == nil {
+		return nil, nil, ErrStmtCacheDisabled
+	}
+
+	return c.prepare(ctx, c.read(ctx), query)
+}
+
+// prepare returns cached statement of the connection node and its release func, the error identifies
+// the node.
+func (c *connection) prepare(ctx context.Context, node *sql.DB, query string) (*sql.Stmt, func(), error) {
+	var stmt, release, err = c.stmts.prepare(ctx, node, query)
+	if err != nil {
+		return nil, nil, connectionError(c.name, c.nodeIndex(node), OpPrepare, err)
+	}
+
+	return stmt, release, nil
+}
+
+// prepare returns referenced cached statement or prepares a new one evicting the least recently used,
+// the release func drops the reference.
+func (c *stmtCache) prepare(ctx context.Context, node *sql.DB, query string) (_ *sql.Stmt, _ func(), err error) {
+	var key = stmtKey{node: node, query: query}
+
+	c.mux.Lock()
+	if el, ok := c.items[key]; ok {
+		c.order.MoveToFront(el)
+		defer c.mux.Unlock()
+
+		return c.acquire(el.Value.(*stmtEntry))
+	}
+	c.mux.Unlock()
+
+	var stmt *sql.Stmt
+	if stmt, err = node.PrepareContext(ctx, query); err != nil {
+		return nil, nil, err
+	}
+
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	// concurrent prepare of the same statement won the race
+	if el, ok := c.items[key]; ok {
+		_ = stmt.Close()
+		c.order.MoveToFront(el)
+
+		return c.acquire(el.Value.(*stmtEntry))
+	}
+
+	var entry = stmtEntry{key: key, stmt: stmt}
+	c.items[key] = c.order.PushFront(&entry)
+
+	for c.order.Len() > c.size {
+		c.evict(c.order.Back())
+	}
+
+	return c.acquire(&entry)
+}
+
+// acquire references the entry and returns its statement and release func, the mutex must be held.
+func (c *stmtCache) acquire(entry *stmtEntry) (*sql.Stmt, func(), error) {
+	entry.refs++
+
+	var once sync.Once
+	return entry.stmt, func() {
+		once.Do(func() {
+			c.mux.Lock()
+			defer c.mux.Unlock()
+
+			if entry.refs--; entry.refs == 0 && entry.evicted {
+				_ = entry.stmt.Close()
+			}
+		})
+	}, nil
+}
+
+// evict removes the element from the cache, its statement is closed unless it is referenced. The mutex
+// must be held.
+func (c *stmtCache) evict(el *list.Element) {
+	var entry = c.order.Remove(el).(*stmtEntry)
+	delete(c.items, entry.key)
+
+	entry.evicted = true
+	if entry.refs == 0 {
+		_ = entry.stmt.Close()
+	}
+}
+
+// close evicts every cached statement, the referenced ones are closed once released.
+func (c *stmtCache) close() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	for el := c.order.Front(); el != nil; el = c.order.Front() {
+		c.evict(el)
+	}
+}
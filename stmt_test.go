@@ -0,0 +1,176 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// isStmtClosed reports whether the statement is closed, closed statements fail before reaching the mock.
+func isStmtClosed(stmt *sql.Stmt) bool {
+	var _, err = stmt.Exec()
+	return err != nil && err.Error() == "sql: statement is closed"
+}
+
+func TestStmtCache(t *testing.T) {
+	type step struct {
+		query   string
+		release bool
+	}
+
+	var cases = []struct {
+		name   string
+		size   int
+		steps  []step
+		closed []bool
+	}{
+		{
+			name:   "cached",
+			size:   2,
+			steps:  []step{{query: "q1", release: true}, {query: "q1", release: true}},
+			closed: []bool{false, false},
+		},
+		{
+			name:   "released evicted closed",
+			size:   1,
+			steps:  []step{{query: "q1", release: true}, {query: "q2", release: true}},
+			closed: []bool{true, false},
+		},
+		{
+			name:   "referenced evicted kept open",
+			size:   1,
+			steps:  []step{{query: "q1"}, {query: "q2", release: true}},
+			closed: []bool{false, false},
+		},
+		{
+			name: "least recently used evicted",
+			size: 2,
+			steps: []step{
+				{query: "q1", release: true},
+				{query: "q2", release: true},
+				{query: "q1", release: true},
+				{query: "q3", release: true},
+			},
+			closed: []bool{false, true, false, false},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var db, mock, err = sqlmock.New()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer db.Close()
+
+			mock.MatchExpectationsInOrder(false)
+
+			var (
+				cache = newStmtCache(tc.size)
+				stmts = make([]*sql.Stmt, 0, len(tc.steps))
+			)
+
+			for _, s := range tc.steps {
+				mock.ExpectPrepare(s.query)
+
+				var stmt, release, err = cache.prepare(context.Background(), db, s.query)
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if s.release {
+					release()
+				}
+
+				stmts = append(stmts, stmt)
+			}
+
+			for i, stmt := range stmts {
+				if closed := isStmtClosed(stmt); closed != tc.closed[i] {
+					t.Errorf("statement %d closed is %t, want %t", i, closed, tc.closed[i])
+				}
+			}
+		})
+	}
+}
+
+func TestStmtCacheReleaseEvicted(t *testing.T) {
+	var db, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	mock.ExpectPrepare("q1")
+	mock.ExpectPrepare("q2")
+
+	var cache = newStmtCache(1)
+
+	var stmt, release, _ = cache.prepare(context.Background(), db, "q1")
+	var _, again, _ = cache.prepare(context.Background(), db, "q1")
+
+	if _, other, err := cache.prepare(context.Background(), db, "q2"); err != nil {
+		t.Fatal(err)
+	} else {
+		other()
+	}
+
+	release()
+	release()
+
+	if isStmtClosed(stmt) {
+		t.Fatal("statement closed while referenced")
+	}
+
+	again()
+
+	if !isStmtClosed(stmt) {
+		t.Fatal("evicted statement not closed on last release")
+	}
+}
+
+func TestStmtCacheClose(t *testing.T) {
+	var db, mock, err = sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer db.Close()
+
+	mock.ExpectPrepare("q1")
+	mock.ExpectPrepare("q2")
+
+	var cache = newStmtCache(2)
+
+	var held, release, _ = cache.prepare(context.Background(), db, "q1")
+	var idle, done, _ = cache.prepare(context.Background(), db, "q2")
+	done()
+
+	cache.close()
+
+	if !isStmtClosed(idle) {
+		t.Error("idle statement not closed")
+	}
+
+	if isStmtClosed(held) {
+		t.Error("referenced statement closed")
+	}
+
+	release()
+
+	if !isStmtClosed(held) {
+		t.Error("released statement not closed")
+	}
+
+	if newStmtCache(0) != nil {
+		t.Error("cache of zero size")
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// TLS is connection TLS configuration.
+type TLS struct {
+	CAFile             string `json:"ca_file"`
+	CertFile           string `json:"cert_file"`
+	KeyFile            string `json:"key_file"`
+	ServerName         string `json:"server_name"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+}
+
+// ErrTLSUnsupported is error triggered when TLS is configured for a driver the registry can't configure.
+var ErrTLSUnsupported = errors.New("tls configuration is not supported by driver")
+
+// apply returns the node DSN configured to use TLS.
+func (t *TLS) apply(name, driverName, dsn string) (string, error) {
+	switch driverName {
+	case "mysql":
+		return t.applyMySQL(name, dsn)
+	case "postgres", "pgx", "cloudsqlpostgres":
+		return t.applyPostgres(dsn)
+	case DriverClickHouse:
+		return t.applyClickHouse(dsn)
+	default:
+		return "", fmt.Errorf("%w %s", ErrTLSUnsupported, driverName)
+	}
+}
+
+// config returns crypto/tls configuration.
+func (t *TLS) config() (_ *tls.Config, err error) {
+	var conf = tls.Config{
+		ServerName:         t.ServerName,
+		InsecureSkipVerify: t.InsecureSkipVerify, //nolint:gosec
+	}
+
+	if len(t.CAFile) > 0 {
+		var pem []byte
+		if pem, err = os.ReadFile(t.CAFile); err != nil {
+			return nil, fmt.Errorf("unable read tls ca file : %w", err)
+		}
+
+		conf.RootCAs = x509.NewCertPool()
+		if !conf.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in tls ca file %s", t.CAFile)
+		}
+	}
+
+	if len(t.CertFile) > 0 || len(t.KeyFile) > 0 {
+		var cert tls.Certificate
+		if cert, err = tls.LoadX509KeyPair(t.CertFile, t.KeyFile); err != nil {
+			return nil, fmt.Errorf("unable load tls key pair : %w", err)
+		}
+
+		conf.Certificates = []tls.Certificate{cert}
+	}
+
+	return &conf, nil
+}
+
+// applyMySQL registers the TLS configuration in the mysql driver under the connection name.
+func (t *TLS) applyMySQL(name, dsn string) (_ string, err error) {
+	var conf *tls.Config
+	if conf, err = t.config(); err != nil {
+		return "", err
+	}
+
+	var key = "gozix_sql_" + name
+	if err = mysql.RegisterTLSConfig(key, conf); err != nil {
+		return "", err
+	}
+
+	var cfg *mysql.Config
+	if cfg, err = mysql.ParseDSN(dsn); err != nil {
+		return "", err
+	}
+
+	cfg.TLSConfig = key
+
+	return cfg.FormatDSN(), nil
+}
+
+// applyPostgres adds libpq ssl parameters to the DSN in both URL and keyword/value formats.
+func (t *TLS) applyPostgres(dsn string) (string, error) {
+	if len(t.ServerName) > 0 {
+		return "", fmt.Errorf("%w postgres : server_name can't be set", ErrTLSUnsupported)
+	}
+
+	var params = [][2]string{{"sslmode", t.sslMode()}}
+
+	if len(t.CAFile) > 0 {
+		params = append(params, [2]string{"sslrootcert", t.CAFile})
+	}
+
+	if len(t.CertFile) > 0 {
+		params = append(params, [2]string{"sslcert", t.CertFile})
+	}
+
+	if len(t.KeyFile) > 0 {
+		params = append(params, [2]string{"sslkey", t.KeyFile})
+	}
+
+	return withPostgresParams(dsn, params)
+}
+
+// withPostgresParams returns the URL or key/value postgres DSN with the parameters set.
+func withPostgresParams(dsn string, params [][2]string) (string, error) {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		var u, err = url.Parse(dsn)
+		if err != nil {
+			return "", err
+		}
+
+		var query = u.Query()
+		for _, param := range params {
+			query.Set(param[0], param[1])
+		}
+
+		u.RawQuery = query.Encode()
+
+		return u.String(), nil
+	}
+
+	var b strings.Builder
+	b.WriteString(dsn)
+
+	for _, param := range params {
+		b.WriteString(" " + param[0] + "='" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(param[1]) + "'")
+	}
+
+	return b.String(), nil
+}
+
+// sslMode returns libpq sslmode matching the configuration, the server certificate is verified unless
+// InsecureSkipVerify is set, by the default root certificates when CAFile is empty.
+func (t *TLS) sslMode() string {
+	if t.InsecureSkipVerify {
+		return "require"
+	}
+
+	return "verify-full"
+}
@@ -0,0 +1,104 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.17.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName is instrumentation name of the registry tracer.
+const tracerName = "github.com/gozix/sql/v3"
+
+type (
+	// tracingInterceptor wraps every operation with OpenTelemetry span.
+	tracingInterceptor struct {
+		tracer trace.Tracer
+	}
+
+	// spanKey is context key of the span started by tracingInterceptor.
+	spanKey struct{}
+)
+
+// Attribute keys of the registry spans.
+var (
+	attrConnection = attribute.Key("db.sql.connection")
+	attrNode       = attribute.Key("db.sql.node")
+	attrRole       = attribute.Key("db.sql.role")
+)
+
+// WithTracing option wraps every statement executed via registry connections with OpenTelemetry
+// span. Nil provider means the global tracer provider.
+func WithTracing(provider trace.TracerProvider) Option {
+	return optionFunc(func(r *Registry) {
+		if provider == nil {
+			provider = otel.GetTracerProvider()
+		}
+
+		r.chain = append(r.chain, &tracingInterceptor{
+			tracer: provider.Tracer(tracerName),
+		})
+	})
+}
+
+func (i *tracingInterceptor) before(ctx context.Context, e *QueryEvent) (context.Context, error) {
+	var attrs = []attribute.KeyValue{
+		semconv.DBSystemKey.String(dbSystem(e.Driver)),
+		attrConnection.String(e.Connection),
+		attrNode.Int(e.Node),
+		attrRole.String(e.Role),
+	}
+
+	if len(e.Query) > 0 {
+		attrs = append(attrs, semconv.DBStatementKey.String(e.Query))
+	}
+
+	var span trace.Span
+	ctx, span = i.tracer.Start(
+		ctx,
+		"sql."+e.Op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrs...),
+	)
+
+	return context.WithValue(ctx, spanKey{}, span), nil
+}
+
+func (i *tracingInterceptor) after(ctx context.Context, e *QueryEvent) {
+	var span, ok = ctx.Value(spanKey{}).(trace.Span)
+	if !ok {
+		return
+	}
+
+	if e.Err != nil {
+		span.RecordError(e.Err)
+		span.SetStatus(codes.Error, e.Err.Error())
+	}
+
+	span.End()
+}
+
+// dbSystem returns OpenTelemetry db.system value of the driver.
+func dbSystem(driverName string) string {
+	switch driverName {
+	case "postgres", "pgx", "cloudsqlpostgres":
+		return semconv.DBSystemPostgreSQL.Value.AsString()
+	case "mysql":
+		return semconv.DBSystemMySQL.Value.AsString()
+	case "sqlite", "sqlite3":
+		return semconv.DBSystemSqlite.Value.AsString()
+	case DriverClickHouse:
+		return semconv.DBSystemClickhouse.Value.AsString()
+	case "sqlserver", "mssql":
+		return semconv.DBSystemMSSQL.Value.AsString()
+	default:
+		return semconv.DBSystemOtherSQL.Value.AsString()
+	}
+}
@@ -0,0 +1,204 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"database/sql/driver"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+type (
+	// TwoPhaseFunc is function executed inside the branches of the distributed transaction, the
+	// branches are keyed by connection name.
+	TwoPhaseFunc func(ctx context.Context, branches map[string]Executor) error
+
+	// xaDialect is two-phase commit statements of the driver.
+	xaDialect struct {
+		begin            []string
+		prepare          []string
+		commitPrepared   string
+		rollback         []string
+		rollbackPrepared string
+	}
+
+	// xaBranch is branch of the distributed transaction on the connection master node.
+	xaBranch struct {
+		name     string
+		gid      string
+		dialect  xaDialect
+		conn     *sql.Conn
+		prepared bool
+	}
+)
+
+// WithTwoPhaseTx runs fn inside a distributed transaction spanning master nodes of the named
+// connections, using PREPARE TRANSACTION on postgres and XA on mysql. Every branch is prepared
+// after fn succeeds and then committed, any failure before the commit phase rolls back all of the
+// branches. A failure during the commit phase leaves prepared transactions which must be resolved
+// manually, the error lists their global identifiers, so does the error of the failed rollback.
+// It is experimental.
+func (r *Registry) WithTwoPhaseTx(ctx context.Context, names []string, fn TwoPhaseFunc) (err error) {
+	var branches []*xaBranch
+	defer func() {
+		for _, b := range branches {
+			_ = b.conn.Close()
+		}
+	}()
+
+	var gid string
+	if gid, err = newGID(); err != nil {
+		return err
+	}
+
+	var executors = make(map[string]Executor, len(names))
+	for i, name := range names {
+		if _, ok := executors[name]; ok {
+			return rollbackBranches(ctx, branches, fmt.Errorf("duplicate %s connection", name))
+		}
+
+		var b *xaBranch
+		if b, err = r.beginBranch(ctx, name, fmt.Sprintf("%s_%d", gid, i)); err != nil {
+			return rollbackBranches(ctx, branches, err)
+		}
+
+		branches = append(branches, b)
+		executors[name] = b.conn
+	}
+
+	if err = fn(ctx, executors); err != nil {
+		return rollbackBranches(ctx, branches, err)
+	}
+
+	for _, b := range branches {
+		if err = b.exec(ctx, b.dialect.prepare...); err != nil {
+			return rollbackBranches(ctx, branches, fmt.Errorf("unable prepare %s branch : %w", b.name, err))
+		}
+
+		b.prepared = true
+	}
+
+	var failed []string
+	for _, b := range branches {
+		if commitErr := b.exec(ctx, b.dialect.commitPrepared); commitErr != nil {
+			failed = append(failed, fmt.Sprintf("%s (%s) : %s", b.name, b.gid, commitErr))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("unable commit prepared branches %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
+
+// beginBranch begins the branch on the connection master node.
+func (r *Registry) beginBranch(ctx context.Context, name, gid string) (_ *xaBranch, err error) {
+	var c *connection
+	if c, err = r.connection(ctx, name); err != nil {
+		return nil, err
+	}
+
+	var b = xaBranch{name: name, gid: gid}
+	if b.dialect, err = newXADialect(c.conf.Driver, gid); err != nil {
+		return nil, err
+	}
+
+	if b.conn, err = c.acquire(ctx, c.db.Master()); err != nil {
+		return nil, err
+	}
+
+	if err = b.exec(ctx, b.dialect.begin...); err != nil {
+		_ = b.conn.Close()
+		return nil, fmt.Errorf("unable begin %s branch : %w", name, err)
+	}
+
+	return &b, nil
+}
+
+// exec executes the statements on the branch connection.
+func (b *xaBranch) exec(ctx context.Context, statements ...string) error {
+	for _, statement := range statements {
+		if _, err := b.conn.ExecContext(ctx, statement); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// rollbackBranches rolls back the branches after the cause error and returns it, the branches failed
+// to roll back are listed by the returned error. Their connections are discarded, so the unprepared
+// transactions are aborted with the session, the prepared ones left are rolled back by the database
+// administrator.
+func rollbackBranches(ctx context.Context, branches []*xaBranch, cause error) error {
+	var failed []string
+	for _, b := range branches {
+		var err error
+		if b.prepared {
+			err = b.exec(ctx, b.dialect.rollbackPrepared)
+		} else {
+			err = b.exec(ctx, b.dialect.rollback...)
+		}
+
+		if err != nil {
+			failed = append(failed, fmt.Sprintf("%s (%s) : %s", b.name, b.gid, err))
+			b.discard()
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("%w, unable roll back branches %s", cause, strings.Join(failed, ", "))
+	}
+
+	return cause
+}
+
+// discard removes the branch connection from the pool, it is closed once released.
+func (b *xaBranch) discard() {
+	_ = b.conn.Raw(func(interface{}) error {
+		return driver.ErrBadConn
+	})
+}
+
+// newXADialect returns two-phase commit statements of the driver for the global identifier.
+func newXADialect(driverName, gid string) (xaDialect, error) {
+	var quoted = "'" + gid + "'"
+
+	switch driverName {
+	case "postgres", "pgx", "cloudsqlpostgres":
+		return xaDialect{
+			begin:            []string{"BEGIN"},
+			prepare:          []string{"PREPARE TRANSACTION " + quoted},
+			commitPrepared:   "COMMIT PREPARED " + quoted,
+			rollback:         []string{"ROLLBACK"},
+			rollbackPrepared: "ROLLBACK PREPARED " + quoted,
+		}, nil
+	case "mysql":
+		return xaDialect{
+			begin:            []string{"XA START " + quoted},
+			prepare:          []string{"XA END " + quoted, "XA PREPARE " + quoted},
+			commitPrepared:   "XA COMMIT " + quoted,
+			rollback:         []string{"XA END " + quoted, "XA ROLLBACK " + quoted},
+			rollbackPrepared: "XA ROLLBACK " + quoted,
+		}, nil
+	default:
+		return xaDialect{}, fmt.Errorf("two-phase commit is not supported by %q driver", driverName)
+	}
+}
+
+// newGID returns random global transaction identifier.
+func newGID() (string, error) {
+	var buf = make([]byte, 12)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return "gozix_" + hex.EncodeToString(buf), nil
+}
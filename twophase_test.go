@@ -0,0 +1,223 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// twoPhaseDriver is postgres dialect name the sqlmock driver is registered with.
+const twoPhaseDriver = "cloudsqlpostgres"
+
+func init() {
+	var db, _, err = sqlmock.New()
+	if err != nil {
+		panic(err)
+	}
+
+	sql.Register(twoPhaseDriver, db.Driver())
+	_ = db.Close()
+}
+
+// newTwoPhaseRegistry returns registry of the postgres connections backed by sqlmock.
+func newTwoPhaseRegistry(t *testing.T, prefix string, names ...string) (*Registry, map[string]sqlmock.Sqlmock) {
+	t.Helper()
+
+	var (
+		configs = make(Configs, len(names))
+		mocks   = make(map[string]sqlmock.Sqlmock, len(names))
+	)
+
+	for _, name := range names {
+		var dsn = "gozix_twophase_" + prefix + "_" + name
+		var db, mock, err = sqlmock.NewWithDSN(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		t.Cleanup(func() {
+			_ = db.Close()
+		})
+
+		configs[name] = Config{Driver: twoPhaseDriver, Nodes: []string{dsn}}
+		mocks[name] = mock
+	}
+
+	var registry, err = NewRegistry(configs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		_ = registry.Close()
+	})
+
+	return registry, mocks
+}
+
+func TestWithTwoPhaseTx(t *testing.T) {
+	var (
+		errFn     = errors.New("insufficient funds")
+		errFailed = errors.New("connection reset")
+	)
+
+	var cases = []struct {
+		name   string
+		expect func(orders, billing sqlmock.Sqlmock)
+		fn     error
+		err    error
+		want   string
+	}{
+		{
+			name: "committed",
+			expect: func(orders, billing sqlmock.Sqlmock) {
+				for i, mock := range []sqlmock.Sqlmock{orders, billing} {
+					var gid = fmt.Sprintf(`'gozix_[0-9a-f]{24}_%d'`, i)
+					mock.ExpectExec("BEGIN").WillReturnResult(sqlmock.NewResult(0, 0))
+					mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectExec("PREPARE TRANSACTION " + gid).WillReturnResult(sqlmock.NewResult(0, 0))
+					mock.ExpectExec("COMMIT PREPARED " + gid).WillReturnResult(sqlmock.NewResult(0, 0))
+				}
+			},
+		},
+		{
+			name: "rolled back on function error",
+			fn:   errFn,
+			expect: func(orders, billing sqlmock.Sqlmock) {
+				for _, mock := range []sqlmock.Sqlmock{orders, billing} {
+					mock.ExpectExec("BEGIN").WillReturnResult(sqlmock.NewResult(0, 0))
+					mock.ExpectExec("ROLLBACK").WillReturnResult(sqlmock.NewResult(0, 0))
+				}
+			},
+			err: errFn,
+		},
+		{
+			name: "prepared branch rolled back",
+			expect: func(orders, billing sqlmock.Sqlmock) {
+				for _, mock := range []sqlmock.Sqlmock{orders, billing} {
+					mock.ExpectExec("BEGIN").WillReturnResult(sqlmock.NewResult(0, 0))
+					mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+				}
+
+				orders.ExpectExec("PREPARE TRANSACTION").WillReturnResult(sqlmock.NewResult(0, 0))
+				billing.ExpectExec("PREPARE TRANSACTION").WillReturnError(errFailed)
+				orders.ExpectExec("ROLLBACK PREPARED").WillReturnResult(sqlmock.NewResult(0, 0))
+				billing.ExpectExec("ROLLBACK").WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			err:  errFailed,
+			want: "unable prepare billing branch",
+		},
+		{
+			name: "failed rollback reported",
+			fn:   errFn,
+			expect: func(orders, billing sqlmock.Sqlmock) {
+				for _, mock := range []sqlmock.Sqlmock{orders, billing} {
+					mock.ExpectExec("BEGIN").WillReturnResult(sqlmock.NewResult(0, 0))
+				}
+
+				orders.ExpectExec("ROLLBACK").WillReturnResult(sqlmock.NewResult(0, 0))
+				billing.ExpectExec("ROLLBACK").WillReturnError(errFailed)
+			},
+			err:  errFn,
+			want: "unable roll back branches billing (gozix_",
+		},
+		{
+			name: "failed commit reported",
+			expect: func(orders, billing sqlmock.Sqlmock) {
+				for _, mock := range []sqlmock.Sqlmock{orders, billing} {
+					mock.ExpectExec("BEGIN").WillReturnResult(sqlmock.NewResult(0, 0))
+					mock.ExpectExec("UPDATE").WillReturnResult(sqlmock.NewResult(0, 1))
+					mock.ExpectExec("PREPARE TRANSACTION").WillReturnResult(sqlmock.NewResult(0, 0))
+				}
+
+				orders.ExpectExec("COMMIT PREPARED").WillReturnError(errFailed)
+				billing.ExpectExec("COMMIT PREPARED").WillReturnResult(sqlmock.NewResult(0, 0))
+			},
+			want: "unable commit prepared branches orders (gozix_",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var registry, mocks = newTwoPhaseRegistry(t, strings.ReplaceAll(tc.name, " ", "_"), "orders", "billing")
+			tc.expect(mocks["orders"], mocks["billing"])
+
+			var err = registry.WithTwoPhaseTx(context.Background(), []string{"orders", "billing"},
+				func(ctx context.Context, branches map[string]Executor) error {
+					if tc.fn != nil {
+						return tc.fn
+					}
+
+					for _, name := range []string{"orders", "billing"} {
+						if _, err := branches[name].ExecContext(ctx, "UPDATE accounts SET balance = balance - 1"); err != nil {
+							return err
+						}
+					}
+
+					return nil
+				},
+			)
+
+			if tc.err != nil && !errors.Is(err, tc.err) {
+				t.Errorf("error is %v, want %v", err, tc.err)
+			}
+
+			if tc.want != "" && (err == nil || !strings.Contains(err.Error(), tc.want)) {
+				t.Errorf("error is %v, want %q", err, tc.want)
+			}
+
+			if tc.err == nil && tc.want == "" && err != nil {
+				t.Errorf("error is %v, want committed transaction", err)
+			}
+
+			for name, mock := range mocks {
+				if err = mock.ExpectationsWereMet(); err != nil {
+					t.Errorf("%s branch : %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestWithTwoPhaseTxInvalidBranches(t *testing.T) {
+	var registry, err = NewRegistry(Configs{"mock": {Driver: "sqlmock", Nodes: []string{"gozix_twophase_unsupported"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer registry.Close()
+
+	var db, _, _ = sqlmock.NewWithDSN("gozix_twophase_unsupported")
+	defer db.Close()
+
+	var fn = func(context.Context, map[string]Executor) error {
+		t.Error("function is executed")
+		return nil
+	}
+
+	var cases = []struct {
+		name  string
+		names []string
+		want  string
+	}{
+		{name: "unsupported driver", names: []string{"mock"}, want: `two-phase commit is not supported by "sqlmock" driver`},
+		{name: "unknown connection", names: []string{"missing"}, want: ErrUnknownConnection.Error()},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := registry.WithTwoPhaseTx(context.Background(), tc.names, fn); err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Errorf("error is %v, want %q", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/iqoption/nap"
+)
+
+type (
+	// TxFunc is function executed inside transaction.
+	TxFunc func(ctx context.Context, tx *sql.Tx) error
+
+	// Executor is common interface of connection and transaction, it lets repositories run
+	// statements regardless of whether there is an ambient transaction.
+	Executor interface {
+		ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+		QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+		QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	}
+
+	// txKey is context key of the connection ambient transaction.
+	txKey struct {
+		name string
+	}
+
+	// txHooks are functions invoked once the transaction or the savepoint is finished.
+	txHooks struct {
+		mux      sync.Mutex
+		commit   []func()
+		rollback []func()
+	}
+
+	// txHooksKey is context key of the connection ambient transaction hooks.
+	txHooksKey struct {
+		name string
+	}
+)
+
+var (
+	_ Executor = (*nap.DB)(nil)
+	_ Executor = (*sql.Tx)(nil)
+)
+
+// DefaultTxRetry is transaction retry configuration used when connection has no TxRetry attempts.
+var DefaultTxRetry = Retry{
+	Attempts:     3,
+	InitialDelay: 10 * time.Millisecond,
+	MaxDelay:     time.Second,
+	Jitter:       0.2,
+}
+
+// ContextWithTx returns context carrying the transaction as the connection ambient transaction.
+func ContextWithTx(ctx context.Context, name string, tx *sql.Tx) context.Context {
+	return context.WithValue(ctx, txKey{name: name}, tx)
+}
+
+// TxFromContext returns the connection ambient transaction.
+func TxFromContext(ctx context.Context, name string) (*sql.Tx, bool) {
+	var tx, ok = ctx.Value(txKey{name: name}).(*sql.Tx)
+	return tx, ok
+}
+
+// OnCommit registers fn to be invoked once the connection ambient transaction started by WithTx is
+// committed, e.g. to invalidate application caches. Outside of transaction fn is invoked immediately
+// as the statements are committed already. Functions of a savepoint rolled back are dropped.
+func OnCommit(ctx context.Context, name string, fn func()) {
+	var hooks, ok = ctx.Value(txHooksKey{name: name}).(*txHooks)
+	if !ok {
+		fn()
+		return
+	}
+
+	hooks.mux.Lock()
+	hooks.commit = append(hooks.commit, fn)
+	hooks.mux.Unlock()
+}
+
+// OnRollback registers fn to be invoked once the connection ambient transaction started by WithTx,
+// or the savepoint of WithNestedTx, is rolled back. Outside of transaction fn is never invoked.
+func OnRollback(ctx context.Context, name string, fn func()) {
+	var hooks, ok = ctx.Value(txHooksKey{name: name}).(*txHooks)
+	if !ok {
+		return
+	}
+
+	hooks.mux.Lock()
+	hooks.rollback = append(hooks.rollback, fn)
+	hooks.mux.Unlock()
+}
+
+// ExecutorFromContext returns the connection ambient transaction if there is one, otherwise it
+// returns the connection itself.
+func (r *Registry) ExecutorFromContext(ctx context.Context, name string) (Executor, error) {
+	if tx, ok := TxFromContext(ctx, name); ok {
+		return tx, nil
+	}
+
+	return r.ConnectionWithNameContext(ctx, name)
+}
+
+// WithTx begins transaction on the connection master node, runs fn and commits the transaction,
+// the transaction is rolled back when fn returns an error or panics. Nil opts mean the connection
+// TxIsolation and TxReadOnly defaults. The whole function is retried by the context or the connection
+// retry policy, by default on the driver serialization failures, deadlocks and lock timeouts, see
+// DriverRetryable, so fn must be safe to repeat. The context passed to fn carries the transaction as
+// ambient one, so nested WithTx calls and ExecutorFromContext use it instead of beginning a new
+// transaction.
+func (r *Registry) WithTx(ctx context.Context, name string, opts *sql.TxOptions, fn TxFunc) (err error) {
+	if tx, ok := TxFromContext(ctx, name); ok {
+		return fn(ctx, tx)
+	}
+
+	var c *connection
+	if c, err = r.connection(ctx, name); err != nil {
+		return err
+	}
+
+	var policy = c.conf.retryPolicy(ctx, name)
+
+	if opts == nil {
+		if opts, err = c.conf.txOptions(); err != nil {
+			return err
+		}
+	}
+
+	var attempt int
+	return policy.Do(ctx, func(ctx context.Context) (err error) {
+		if attempt++; attempt > 1 {
+			r.txMetrics.retried(name)
+		}
+
+		var (
+			hooks txHooks
+			start = c.clock.Now()
+		)
+
+		err = c.runTx(context.WithValue(ctx, txHooksKey{name: name}, &hooks), opts, fn)
+		r.txMetrics.observe(name, err, c.clock.Now().Sub(start))
+		hooks.finish(err == nil)
+
+		switch {
+		case err == nil:
+		case policy.Retryable == nil:
+			r.logger.Error("transaction failed", err, "connection", name)
+		case policy.Retryable(err):
+			r.logger.Error("transaction conflict", err, "connection", name)
+		}
+
+		return err
+	})
+}
+
+// txOptions returns the connection default transaction options.
+func (c Config) txOptions() (*sql.TxOptions, error) {
+	var level, err = parseIsolation(c.TxIsolation)
+	if err != nil {
+		return nil, err
+	}
+
+	return &sql.TxOptions{Isolation: level, ReadOnly: c.TxReadOnly}, nil
+}
+
+// parseIsolation returns isolation level by name, e.g. read_committed or serializable.
+func parseIsolation(name string) (sql.IsolationLevel, error) {
+	switch name {
+	case "", "default":
+		return sql.LevelDefault, nil
+	case "read_uncommitted":
+		return sql.LevelReadUncommitted, nil
+	case "read_committed":
+		return sql.LevelReadCommitted, nil
+	case "write_committed":
+		return sql.LevelWriteCommitted, nil
+	case "repeatable_read":
+		return sql.LevelRepeatableRead, nil
+	case "snapshot":
+		return sql.LevelSnapshot, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	case "linearizable":
+		return sql.LevelLinearizable, nil
+	default:
+		return sql.LevelDefault, fmt.Errorf("unknown transaction isolation level %q", name)
+	}
+}
+
+// runTx runs fn inside a single transaction on the master node connection acquired within DedicatedConnAcquireTimeout.
+func (c *connection) runTx(ctx context.Context, opts *sql.TxOptions, fn TxFunc) (err error) {
+	var conn *sql.Conn
+	if conn, err = c.acquire(ctx, c.db.Master()); err != nil {
+		return err
+	}
+
+	defer func() {
+		if closeErr := conn.Close(); err == nil {
+			err = closeErr
+		}
+	}()
+
+	var tx *sql.Tx
+	if tx, err = conn.BeginTx(ctx, opts); err != nil {
+		return err
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err = fn(ContextWithTx(ctx, c.name, tx), tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// merge moves the functions of the hooks to the parent ones.
+func (h *txHooks) merge(parent *txHooks) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	parent.mux.Lock()
+	defer parent.mux.Unlock()
+
+	parent.commit = append(parent.commit, h.commit...)
+	parent.rollback = append(parent.rollback, h.rollback...)
+}
+
+// finish invokes the commit or the rollback functions in order of registration.
+func (h *txHooks) finish(committed bool) {
+	h.mux.Lock()
+	var fns = h.rollback
+	if committed {
+		fns = h.commit
+	}
+
+	h.commit, h.rollback = nil, nil
+	h.mux.Unlock()
+
+	for _, fn := range fns {
+		fn()
+	}
+}
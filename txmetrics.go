@@ -0,0 +1,74 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// txMetrics records duration, outcome and retries of the transactions started by WithTx.
+type txMetrics struct {
+	registerer prometheus.Registerer
+	duration   *prometheus.HistogramVec
+	retries    *prometheus.CounterVec
+}
+
+// WithTxMetrics option records duration histogram of every transaction attempt made by WithTx by
+// connection name and outcome, commit or rollback, so the histogram counts are the commit and the
+// rollback counters, and counter of the attempts retried on conflicts. Nil buckets mean
+// prometheus.DefBuckets.
+func WithTxMetrics(registerer prometheus.Registerer, buckets []float64) Option {
+	return optionFunc(func(r *Registry) {
+		r.txMetrics = &txMetrics{
+			registerer: registerer,
+			duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+				Name:    "tx_duration_seconds",
+				Help:    "The transaction duration",
+				Buckets: buckets,
+			}, []string{"connection", "outcome"}),
+			retries: prometheus.NewCounterVec(prometheus.CounterOpts{
+				Name: "tx_retries_total",
+				Help: "The total number of retried transactions",
+			}, []string{"connection"}),
+		}
+	})
+}
+
+// register registers the metrics in the registerer.
+func (m *txMetrics) register() error {
+	if err := m.registerer.Register(m.duration); err != nil {
+		return err
+	}
+
+	if err := m.registerer.Register(m.retries); err != nil {
+		m.registerer.Unregister(m.duration)
+		return err
+	}
+
+	return nil
+}
+
+// observe records the transaction attempt of the connection. Nil metrics record nothing.
+func (m *txMetrics) observe(name string, err error, duration time.Duration) {
+	if m == nil {
+		return
+	}
+
+	var outcome = "commit"
+	if err != nil {
+		outcome = "rollback"
+	}
+
+	m.duration.WithLabelValues(name, outcome).Observe(duration.Seconds())
+}
+
+// retried records the retried transaction of the connection. Nil metrics record nothing.
+func (m *txMetrics) retried(name string) {
+	if m != nil {
+		m.retries.WithLabelValues(name).Inc()
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrUpsertUnsupported is returned when the driver has no upsert statement.
+var ErrUpsertUnsupported = errors.New("upsert is unsupported by the driver")
+
+// Upsert describes rows inserted or updated by Registry.Upsert. The table and the columns are written
+// to the statement as is, they must be quoted by the caller when needed.
+type Upsert struct {
+	Table   string
+	Columns []string
+	Rows    [][]interface{}
+
+	// Conflict are columns of the unique key identifying the existing rows, they must be inserted
+	// columns. MySQL matches the rows by any unique key of the table, the columns are validated only.
+	Conflict []string
+
+	// Update are columns updated of the existing rows, every non conflict column by default. The
+	// existing rows are left untouched when all inserted columns are conflict ones.
+	Update []string
+}
+
+// Upsert inserts the rows into the connection, or its ambient transaction, updating the existing ones
+// by a single statement of the connection driver: INSERT ... ON CONFLICT DO UPDATE on Postgres and
+// SQLite, INSERT ... ON DUPLICATE KEY UPDATE on MySQL and MERGE on SQL Server.
+func (r *Registry) Upsert(ctx context.Context, name string, upsert Upsert) (_ sql.Result, err error) {
+	var conf Config
+	if conf, err = r.Config(name); err != nil {
+		return nil, err
+	}
+
+	var (
+		query string
+		args  []interface{}
+	)
+
+	if query, args, err = upsert.Statement(conf.Driver); err != nil {
+		return nil, err
+	}
+
+	var db Executor
+	if db, err = r.ExecutorFromContext(ctx, name); err != nil {
+		return nil, err
+	}
+
+	return db.ExecContext(ctx, query, args...)
+}
+
+// Statement returns upsert statement of the driver and its arguments, ErrUpsertUnsupported is returned
+// for drivers other than Postgres, SQLite, MySQL and SQL Server ones.
+func (u Upsert) Statement(driverName string) (_ string, args []interface{}, err error) {
+	if err = u.validate(); err != nil {
+		return "", nil, err
+	}
+
+	var (
+		update = u.updated()
+		query  strings.Builder
+	)
+
+	switch {
+	case driverName == "postgres", driverName == "pgx", driverName == "cloudsqlpostgres", isSQLite(driverName):
+		args = u.insert(&query, driverName)
+		query.WriteString(" ON CONFLICT (" + strings.Join(u.Conflict, ", ") + ")")
+
+		if len(update) == 0 {
+			query.WriteString(" DO NOTHING")
+			break
+		}
+
+		query.WriteString(" DO UPDATE SET ")
+		writeAssignments(&query, update, "excluded.%s")
+	case driverName == "mysql":
+		args = u.insert(&query, driverName)
+		query.WriteString(" ON DUPLICATE KEY UPDATE ")
+
+		// no-op assignment keeps the existing row, unlike INSERT IGNORE it doesn't hide other errors
+		if len(update) == 0 {
+			writeAssignments(&query, u.Conflict[:1], "%s")
+			break
+		}
+
+		writeAssignments(&query, update, "VALUES(%s)")
+	case driverName == "sqlserver", driverName == "mssql":
+		args = u.merge(&query, driverName, update)
+	default:
+		return "", nil, fmt.Errorf("unable build %s upsert : %w", driverName, ErrUpsertUnsupported)
+	}
+
+	return query.String(), args, nil
+}
+
+// validate checks the rows and the columns.
+func (u Upsert) validate() error {
+	if len(u.Columns) == 0 {
+		return errors.New("upsert columns are required")
+	}
+
+	if len(u.Conflict) == 0 {
+		return errors.New("upsert conflict columns are required")
+	}
+
+	if len(u.Rows) == 0 {
+		return errors.New("upsert rows are required")
+	}
+
+	for _, columns := range [][]string{u.Conflict, u.Update} {
+		for _, column := range columns {
+			if !containsString(u.Columns, column) {
+				return fmt.Errorf("upsert column %s is not inserted", column)
+			}
+		}
+	}
+
+	for i, row := range u.Rows {
+		if len(row) != len(u.Columns) {
+			return fmt.Errorf("upsert row %d has %d values of %d columns", i, len(row), len(u.Columns))
+		}
+	}
+
+	return nil
+}
+
+// updated returns columns updated of the existing rows.
+func (u Upsert) updated() []string {
+	if len(u.Update) > 0 {
+		return u.Update
+	}
+
+	var update = make([]string, 0, len(u.Columns))
+	for _, column := range u.Columns {
+		if !containsString(u.Conflict, column) {
+			update = append(update, column)
+		}
+	}
+
+	return update
+}
+
+// insert writes multi-row INSERT statement of the rows and returns its arguments.
+func (u Upsert) insert(query *strings.Builder, driverName string) []interface{} {
+	query.WriteString("INSERT INTO " + u.Table + " (" + strings.Join(u.Columns, ", ") + ") VALUES ")
+	return writeValues(query, driverName, u.Rows)
+}
+
+// merge writes MERGE statement of the rows and returns its arguments.
+func (u Upsert) merge(query *strings.Builder, driverName string, update []string) []interface{} {
+	query.WriteString("MERGE INTO " + u.Table + " AS target USING (VALUES ")
+
+	var args = writeValues(query, driverName, u.Rows)
+
+	query.WriteString(") AS source (" + strings.Join(u.Columns, ", ") + ") ON ")
+	for i, column := range u.Conflict {
+		if i > 0 {
+			query.WriteString(" AND ")
+		}
+
+		query.WriteString("target." + column + " = source." + column)
+	}
+
+	if len(update) > 0 {
+		query.WriteString(" WHEN MATCHED THEN UPDATE SET ")
+		writeAssignments(query, update, "source.%s")
+	}
+
+	query.WriteString(" WHEN NOT MATCHED THEN INSERT (" + strings.Join(u.Columns, ", ") + ") VALUES (")
+	for i, column := range u.Columns {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+
+		query.WriteString("source." + column)
+	}
+
+	// MERGE statement must be terminated by semicolon
+	query.WriteString(");")
+
+	return args
+}
+
+// writeAssignments writes comma separated assignments of the columns to the values formatted by the
+// column name.
+func writeAssignments(query *strings.Builder, columns []string, value string) {
+	for i, column := range columns {
+		if i > 0 {
+			query.WriteString(", ")
+		}
+
+		query.WriteString(column + " = " + fmt.Sprintf(value, column))
+	}
+}
+
+// containsString reports whether the values contain the value.
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,393 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+// Package vault provide HashiCorp Vault dynamic database credentials for the sql registry.
+package vault
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/gozix/sql/v3"
+)
+
+type (
+	// Config is Vault provider configuration.
+	Config struct {
+		// Address is Vault server address, e.g. https://vault:8200.
+		Address string
+
+		// Token is Vault token used to issue and renew credentials.
+		Token string
+
+		// Namespace is Vault Enterprise namespace, optional.
+		Namespace string
+
+		// Mount is database secrets engine mount path, "database" by default.
+		Mount string
+
+		// Role is database secrets engine role name.
+		Role string
+
+		// Client is HTTP client, http.DefaultClient by default.
+		Client *http.Client
+
+		// OnRotate is invoked after the expiring credentials are replaced by new ones and the
+		// connections using them are reconnected, optional.
+		OnRotate func(ctx context.Context) error
+
+		// Logger receives the failed lease renewals and reconnects, nothing is logged by default.
+		Logger sql.Logger
+	}
+
+	// Credentials are dynamic database credentials.
+	Credentials struct {
+		Username  string
+		Password  string
+		LeaseID   string
+		Renewable bool
+		Expire    time.Time
+
+		lease time.Duration
+	}
+
+	// Provider renders node DSN templates with Vault dynamic database credentials.
+	Provider struct {
+		conf      Config
+		templates []*template.Template
+
+		mux   sync.RWMutex
+		creds *Credentials
+		names map[string]bool
+	}
+
+	// nopLogger is sql.Logger discarding every message.
+	nopLogger struct{}
+
+	// secret is Vault secret response.
+	secret struct {
+		LeaseID       string `json:"lease_id"`
+		LeaseDuration int64  `json:"lease_duration"`
+		Renewable     bool   `json:"renewable"`
+		Data          struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		} `json:"data"`
+		Errors []string `json:"errors"`
+	}
+)
+
+// Delays of the failed credentials issue retries, the delay is doubled after every failed attempt.
+const (
+	minRetryDelay = time.Second
+	maxRetryDelay = 30 * time.Second
+)
+
+// ErrNoCredentials is error triggered when Vault response has no credentials.
+var ErrNoCredentials = errors.New("vault response has no credentials")
+
+// Provider.DSN implements sql.DSNProvider.
+var _ sql.DSNProvider = (*Provider)(nil).DSN
+
+// NewProvider is provider constructor. The nodes are text/template DSN templates, the first one
+// is master, with Username and Password fields, e.g.
+// postgres://{{.Username | urlquery}}:{{.Password | urlquery}}@127.0.0.1:5432/app.
+func NewProvider(conf Config, nodes ...string) (_ *Provider, err error) {
+	if len(conf.Mount) == 0 {
+		conf.Mount = "database"
+	}
+
+	if conf.Client == nil {
+		conf.Client = http.DefaultClient
+	}
+
+	if conf.Logger == nil {
+		conf.Logger = nopLogger{}
+	}
+
+	var p = Provider{
+		conf:      conf,
+		templates: make([]*template.Template, 0, len(nodes)),
+		names:     make(map[string]bool),
+	}
+
+	for i, node := range nodes {
+		var tpl *template.Template
+		if tpl, err = template.New(fmt.Sprintf("node_%d", i)).Parse(node); err != nil {
+			return nil, err
+		}
+
+		p.templates = append(p.templates, tpl)
+	}
+
+	return &p, nil
+}
+
+// DSN implements sql.DSNProvider, credentials are issued on first call. The connection is reconnected
+// by Run once the credentials are rotated.
+func (p *Provider) DSN(ctx context.Context, name string) (_ []string, err error) {
+	var creds *Credentials
+	if creds, err = p.Credentials(ctx); err != nil {
+		return nil, err
+	}
+
+	p.mux.Lock()
+	p.names[name] = true
+	p.mux.Unlock()
+
+	var dsn = make([]string, 0, len(p.templates))
+	for _, tpl := range p.templates {
+		var b strings.Builder
+		if err = tpl.Execute(&b, creds); err != nil {
+			return nil, err
+		}
+
+		dsn = append(dsn, b.String())
+	}
+
+	return dsn, nil
+}
+
+// Credentials returns current credentials, they are issued if needed.
+func (p *Provider) Credentials(ctx context.Context) (_ *Credentials, err error) {
+	p.mux.RLock()
+	var creds = p.creds
+	p.mux.RUnlock()
+
+	if creds != nil {
+		return creds, nil
+	}
+
+	p.mux.Lock()
+	defer p.mux.Unlock()
+
+	if p.creds == nil {
+		if p.creds, err = p.issue(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return p.creds, nil
+}
+
+// Run renews the credentials lease until the context is done. When the lease can't be renewed
+// anymore, new credentials are issued before expiration, the registry connections using the provider
+// are reconnected, so the pools drop sockets of the old user, and OnRotate is invoked. Nil registry
+// leaves the reconnect to OnRotate. The failures are logged and the failed issues are retried with
+// backoff, so Run returns nil once the context is done only.
+func (p *Provider) Run(ctx context.Context, registry *sql.Registry) (err error) {
+	for {
+		var creds *Credentials
+		if creds, err = p.issueRetrying(ctx, p.Credentials); err != nil {
+			return nil
+		}
+
+		if creds.lease <= 0 {
+			<-ctx.Done()
+			return nil
+		}
+
+		// act when two thirds of the lease have passed
+		var timer = time.NewTimer(time.Until(creds.Expire) - creds.lease/3)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil
+		case <-timer.C:
+		}
+
+		if creds.Renewable {
+			var renewed *Credentials
+			switch renewed, err = p.renew(ctx, creds); {
+			case err != nil:
+				p.conf.Logger.Error("unable renew vault lease", err, "lease", creds.LeaseID)
+			case renewed.lease >= creds.lease/2:
+				p.mux.Lock()
+				p.creds = renewed
+				p.mux.Unlock()
+
+				continue
+			}
+		}
+
+		var issued *Credentials
+		if issued, err = p.issueRetrying(ctx, p.issue); err != nil {
+			return nil
+		}
+
+		p.mux.Lock()
+		p.creds = issued
+		p.mux.Unlock()
+
+		p.conf.Logger.Info("vault credentials rotated", "lease", issued.LeaseID)
+
+		if registry != nil {
+			p.reconnect(registry)
+		}
+
+		if p.conf.OnRotate != nil {
+			if err = p.conf.OnRotate(ctx); err != nil {
+				p.conf.Logger.Error("vault credentials rotation hook failed", err)
+			}
+		}
+	}
+}
+
+// issueRetrying invokes issue until it succeeds, the failures are logged and retried with backoff. The
+// context error is returned once it is done.
+func (p *Provider) issueRetrying(ctx context.Context, issue func(ctx context.Context) (*Credentials, error)) (
+	*Credentials, error,
+) {
+	var delay = minRetryDelay
+	for {
+		var creds, err = issue(ctx)
+		if err == nil {
+			return creds, nil
+		}
+
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+
+		p.conf.Logger.Error("unable issue vault credentials", err, "delay", delay)
+
+		var timer = time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		if delay *= 2; delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+	}
+}
+
+// reconnect reconnects the registry connections using the provider, the failures are logged since
+// the new physical connections use the new credentials anyway.
+func (p *Provider) reconnect(registry *sql.Registry) {
+	p.mux.RLock()
+	var names = make([]string, 0, len(p.names))
+	for name := range p.names {
+		names = append(names, name)
+	}
+	p.mux.RUnlock()
+
+	for _, name := range names {
+		if err := registry.Reconnect(name); err != nil {
+			p.conf.Logger.Error("unable reconnect rotated vault credentials", err, "connection", name)
+		}
+	}
+}
+
+// issue requests new credentials.
+func (p *Provider) issue(ctx context.Context) (_ *Credentials, err error) {
+	var s *secret
+	if s, err = p.do(ctx, http.MethodGet, fmt.Sprintf("%s/creds/%s", p.conf.Mount, p.conf.Role), nil); err != nil {
+		return nil, err
+	}
+
+	if len(s.Data.Username) == 0 {
+		return nil, ErrNoCredentials
+	}
+
+	var lease = time.Duration(s.LeaseDuration) * time.Second
+
+	return &Credentials{
+		Username:  s.Data.Username,
+		Password:  s.Data.Password,
+		LeaseID:   s.LeaseID,
+		Renewable: s.Renewable,
+		Expire:    time.Now().Add(lease),
+		lease:     lease,
+	}, nil
+}
+
+// renew extends the credentials lease.
+func (p *Provider) renew(ctx context.Context, creds *Credentials) (_ *Credentials, err error) {
+	var body = map[string]interface{}{
+		"lease_id":  creds.LeaseID,
+		"increment": int64(creds.lease / time.Second),
+	}
+
+	var s *secret
+	if s, err = p.do(ctx, http.MethodPut, "sys/leases/renew", body); err != nil {
+		return nil, err
+	}
+
+	var (
+		renewed = *creds
+		lease   = time.Duration(s.LeaseDuration) * time.Second
+	)
+
+	renewed.Renewable = s.Renewable
+	renewed.Expire = time.Now().Add(lease)
+	renewed.lease = lease
+
+	return &renewed, nil
+}
+
+// do sends request to Vault API.
+func (p *Provider) do(ctx context.Context, method, path string, body interface{}) (_ *secret, err error) {
+	var payload []byte
+	if body != nil {
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+
+	var req *http.Request
+	req, err = http.NewRequestWithContext(
+		ctx, method, strings.TrimRight(p.conf.Address, "/")+"/v1/"+path, bytes.NewReader(payload),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("X-Vault-Token", p.conf.Token)
+	if len(p.conf.Namespace) > 0 {
+		req.Header.Set("X-Vault-Namespace", p.conf.Namespace)
+	}
+
+	var resp *http.Response
+	if resp, err = p.conf.Client.Do(req); err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	var s secret
+	if resp.StatusCode >= http.StatusBadRequest {
+		// the error body isn't JSON behind proxies, the status is reported anyway
+		if err = json.NewDecoder(resp.Body).Decode(&s); err != nil || len(s.Errors) == 0 {
+			return nil, fmt.Errorf("vault responded %d", resp.StatusCode)
+		}
+
+		return nil, fmt.Errorf("vault responded %d : %s", resp.StatusCode, strings.Join(s.Errors, "; "))
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(&s); err != nil {
+		return nil, fmt.Errorf("unable decode vault response : %w", err)
+	}
+
+	return &s, nil
+}
+
+// Info implements sql.Logger.
+func (nopLogger) Info(string, ...interface{}) {}
+
+// Error implements sql.Logger.
+func (nopLogger) Error(string, error, ...interface{}) {}
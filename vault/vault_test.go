@@ -0,0 +1,183 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/gozix/sql/v3"
+)
+
+// vaultServer serves the queued responses of the credentials endpoint.
+type vaultServer struct {
+	mux       sync.Mutex
+	responses []func(w http.ResponseWriter)
+	issued    int
+}
+
+// ServeHTTP implements http.Handler.
+func (s *vaultServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/v1/database/creds/app" || r.Header.Get("X-Vault-Token") != "token" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	s.mux.Lock()
+	var respond = s.responses[0]
+	if len(s.responses) > 1 {
+		s.responses = s.responses[1:]
+	}
+	s.issued++
+	s.mux.Unlock()
+
+	respond(w)
+}
+
+// credentials returns response issuing the credentials of the user for a second.
+func credentials(username string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		var s secret
+		s.LeaseID = "lease_" + username
+		s.LeaseDuration = 1
+		s.Data.Username = username
+		s.Data.Password = "password"
+
+		_ = json.NewEncoder(w).Encode(s)
+	}
+}
+
+// status returns response of the status with the body.
+func status(code int, body string) func(w http.ResponseWriter) {
+	return func(w http.ResponseWriter) {
+		w.WriteHeader(code)
+		_, _ = w.Write([]byte(body))
+	}
+}
+
+func newTestProvider(t *testing.T, server *vaultServer, conf Config) *Provider {
+	t.Helper()
+
+	var ts = httptest.NewServer(server)
+	t.Cleanup(ts.Close)
+
+	conf.Address, conf.Token, conf.Role = ts.URL, "token", "app"
+
+	var p, err = NewProvider(conf, "{{.Username}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestProviderErrorStatus(t *testing.T) {
+	var cases = []struct {
+		name    string
+		respond func(w http.ResponseWriter)
+		want    string
+	}{
+		{
+			name:    "json errors",
+			respond: status(http.StatusForbidden, `{"errors":["permission denied"]}`),
+			want:    "vault responded 403 : permission denied",
+		},
+		{name: "html body", respond: status(http.StatusBadGateway, "<html>bad gateway</html>"), want: "vault responded 502"},
+		{name: "empty body", respond: status(http.StatusServiceUnavailable, ""), want: "vault responded 503"},
+		{name: "no credentials", respond: status(http.StatusOK, `{"data":{}}`), want: ErrNoCredentials.Error()},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var p = newTestProvider(t, &vaultServer{responses: []func(w http.ResponseWriter){tc.respond}}, Config{})
+
+			var _, err = p.DSN(context.Background(), "main")
+			if err == nil || err.Error() != tc.want {
+				t.Fatalf("error is %v, want %s", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestProviderRunRotates(t *testing.T) {
+	var (
+		server = vaultServer{responses: []func(w http.ResponseWriter){
+			credentials("gozix_vault_user_1"),
+			status(http.StatusServiceUnavailable, ""),
+			credentials("gozix_vault_user_2"),
+		}}
+		rotated = make(chan struct{}, 1)
+	)
+
+	var p = newTestProvider(t, &server, Config{OnRotate: func(context.Context) error {
+		rotated <- struct{}{}
+		return nil
+	}})
+
+	for _, dsn := range []string{"gozix_vault_user_1", "gozix_vault_user_2"} {
+		var db, _, err = sqlmock.NewWithDSN(dsn)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer db.Close()
+	}
+
+	var registry, err = sql.NewRegistry(sql.Configs{
+		"main": {Driver: "sqlmock", DSNProvider: p.DSN},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer registry.Close()
+
+	var old, _ = registry.ConnectionWithName("main")
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	var stopped = make(chan error)
+	go func() {
+		stopped <- p.Run(ctx, registry)
+	}()
+
+	select {
+	case <-rotated:
+	case <-time.After(10 * time.Second):
+		t.Fatal("credentials are not rotated")
+	}
+
+	cancel()
+	if err = <-stopped; err != nil {
+		t.Errorf("run error is %v", err)
+	}
+
+	var creds, _ = p.Credentials(context.Background())
+	if creds.Username != "gozix_vault_user_2" {
+		t.Errorf("credentials of %s, want rotated ones", creds.Username)
+	}
+
+	server.mux.Lock()
+	var issued = server.issued
+	server.mux.Unlock()
+
+	if issued != 3 {
+		t.Errorf("issued %d times, want failed issue retried", issued)
+	}
+
+	var fresh, _ = registry.ConnectionWithName("main")
+	if fresh == old {
+		t.Fatal("connection is not reconnected")
+	}
+
+	if err = fresh.Master().Ping(); err != nil {
+		t.Errorf("reconnected connection is unusable : %v", err)
+	}
+}
@@ -0,0 +1,34 @@
+// Copyright 2018 Sergey Novichkov. All rights reserved.
+// For the full copyright and license information, please view the LICENSE
+// file that was distributed with this source code.
+
+// Package zap provide zap logging of the sql registry.
+package zap
+
+import (
+	gzSQL "github.com/gozix/sql/v3"
+	"go.uber.org/zap"
+)
+
+// Logger is registry logger writing to zap.
+type Logger struct {
+	logger *zap.SugaredLogger
+}
+
+// Logger implements the registry Logger interface.
+var _ gzSQL.Logger = (*Logger)(nil)
+
+// New returns registry logger writing to the zap logger.
+func New(logger *zap.Logger) *Logger {
+	return &Logger{logger: logger.Sugar()}
+}
+
+// Info implements the registry Logger interface.
+func (l *Logger) Info(msg string, keyvals ...interface{}) {
+	l.logger.Infow(msg, keyvals...)
+}
+
+// Error implements the registry Logger interface.
+func (l *Logger) Error(msg string, err error, keyvals ...interface{}) {
+	l.logger.Errorw(msg, append(keyvals, zap.Error(err))...)
+}